@@ -33,4 +33,16 @@ var (
 	// IfNotPresentAction is the annotation that defines if a Kubernetes resource
 	// should be applied only if it doesn't exist on the cluster.
 	IfNotPresentAction = fmt.Sprintf("action.%s/one-off", GroupVersion.Group)
+
+	// PreservePlacementAction is the annotation that defines if the node placement
+	// of a Kubernetes resource should be kept from the live state on upgrades.
+	PreservePlacementAction = fmt.Sprintf("action.%s/preserve-placement", GroupVersion.Group)
+
+	// DeleteOrderAction is the annotation that holds the integer weight used to order
+	// the deletion of a Kubernetes resource, the higher weights are deleted first.
+	DeleteOrderAction = fmt.Sprintf("action.%s/delete-order", GroupVersion.Group)
+
+	// PreDeleteAction is the instance annotation that holds the shell command
+	// run before the instance is deleted, the deletion is aborted if the command fails.
+	PreDeleteAction = fmt.Sprintf("action.%s/pre-delete", GroupVersion.Group)
 )
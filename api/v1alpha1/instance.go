@@ -70,4 +70,8 @@ type Instance struct {
 	// Images contains the list of container image references.
 	// +optional
 	Images []string `json:"images,omitempty"`
+
+	// ClusterContext is the name of the kubeconfig context used to apply this instance.
+	// +optional
+	ClusterContext string `json:"clusterContext,omitempty"`
 }
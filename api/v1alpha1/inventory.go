@@ -32,4 +32,9 @@ type ResourceRef struct {
 
 	// Version is the API version of the Kubernetes resource object's kind.
 	Version string `json:"v"`
+
+	// Digest is the SHA256 hash of the Kubernetes resource object's
+	// content, as it was last applied.
+	// +optional
+	Digest string `json:"digest,omitempty"`
 }
@@ -20,15 +20,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
 	"cuelang.org/go/cue/cuecontext"
+	"github.com/fluxcd/cli-utils/pkg/object"
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 	"github.com/stefanprodan/timoni/internal/engine"
@@ -51,12 +62,15 @@ The apply command performs the following steps:
 - Merges all the values supplied with '--values' on top of the default values found in the module.
 - Builds the module by passing the instance name, namespace and values.
 - Labels the resulting Kubernetes resources with the instance name and namespace.
+- Skips the resources unchanged since the last apply, unless '--force-reapply' is specified.
 - Applies the Kubernetes resources on the cluster.
-- Creates or updates the instance inventory with the last applied resources IDs (stored in a secret named timoni.<instance_name>).
+- Creates or updates the instance inventory with the last applied resources IDs and content hashes (stored in a secret named timoni.<instance_name>).
 - Recreates the resources annotated with 'action.timoni.sh/force: "enabled"' if they contain changes to immutable fields.
 - Waits for the applied resources to become ready.
 - Deletes the resources which were previously applied but are missing from the current instance.
 - Skips the resources annotated with 'action.timoni.sh/prune: "disabled"' from deletion.
+- Skips the resources not matching the '--prune-selector' from deletion, while keeping them in the inventory.
+- Skips the deletion of all resources if '--prune=false' is specified, while keeping them in the inventory.
 - Waits for the deleted resources to be finalised.
 `,
 	Example: `  # Install a module instance and create the namespace if it doesn't exists
@@ -67,6 +81,64 @@ The apply command performs the following steps:
   --values ./values-1.cue \
   --dry-run --diff
 
+  # Do a dry-run upgrade and print the drift from the last applied state separately from the intended changes
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --three-way
+
+  # Preview an upgrade by printing the diff between the objects built from two module versions
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --values ./values-1.cue \
+  --from-version=1.0.0
+
+  # Do a dry-run upgrade and print the diff of the modified and deleted resources only
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --diff-only=configured,deleted
+
+  # Do a dry-run upgrade and print the diff without the changes made by controllers
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --diff-ignore 'metadata.annotations.*' --diff-ignore /status
+
+  # Do a dry-run upgrade and print the diff as JSON documents
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --diff-output=json | jq .diffs
+
+  # Do a dry-run upgrade in CI and exit with code 2 if the instance is out of sync
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --dry-run --diff-exit-code
+
+  # Do a dry-run upgrade and print only the modified fields of the existing resources
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --diff-ignore-added --diff-ignore-removed
+
+  # Do a dry-run upgrade and write the diff of each modified resource to a separate file
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --diff-dir ./diffs
+
+  # Install or upgrade an instance from a scheduled job, delaying the start by up to 30 seconds
+  timoni apply -n apps app oci://docker.io/org/module -v 1.0.0 \
+  --timeout-jitter=30s
+
+  # Upgrade an instance and apply all resources to correct the drift of the unchanged ones
+  timoni apply -n apps app oci://docker.io/org/module -v 1.0.0 \
+  --force-reapply
+
+  # Upgrade an instance and recreate the Jobs with immutable changes
+  # only after the previous Jobs and their Pods are deleted
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --force --wait-for-delete-before-create
+
+  # Upgrade an instance and keep the resources removed from the module
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --prune=false
+
+  # Upgrade an instance and log the resource version and generation returned by the server for each object
+  timoni apply -n apps app oci://docker.io/org/module -v 1.0.0 \
+  --verbose-apply
+
+  # Do a dry-run upgrade and print the diff including the server-side apply field managers
+  timoni apply -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --show-managed-fields
+
   # Install or upgrade an instance with custom values by merging them in the specified order
   timoni apply -n apps app oci://docker.io/org/module -v 1.0.0 \
   --values ./values-1.cue \
@@ -84,6 +156,30 @@ The apply command performs the following steps:
   timoni apply -n apps app oci://docker.io/org/module \
   --values ./values-1.yaml \
   --values ./values-2.json
+
+  # Install or upgrade an instance and wait for the cert-manager certificates to be issued
+  timoni apply -n apps app oci://docker.io/org/module \
+  --wait-condition=Certificate:Ready=True:5m
+
+  # Install or upgrade an instance and add the module labels to the namespace
+  timoni apply -n apps app oci://docker.io/org/module \
+  --propagate-labels-to-namespace
+
+  # Install a second instance of the same module in a namespace by prefixing the resource names
+  timoni apply -n apps app-canary oci://docker.io/org/module \
+  --name-prefix
+
+  # Install or upgrade an instance and record the deployment provenance in the instance inventory
+  timoni apply -n apps app oci://docker.io/org/module -v 1.0.0 \
+  --inventory-annotation=git.commit=$(git rev-parse HEAD) \
+  --inventory-annotation=ci.pipeline=$CI_PIPELINE_ID
+
+  # Apply the changes computed with 'timoni plan'
+  timoni apply --plan ./plan.json
+
+  # Install or upgrade an instance and save the live state as a baseline for drift detection
+  timoni apply -n apps app oci://docker.io/org/module \
+  --diff-save-baseline ./baseline.yaml
 `,
 	RunE: runApplyCmd,
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -103,46 +199,298 @@ type applyFlags struct {
 	module             string
 	version            flags.Version
 	pkg                flags.Package
+	tags               flags.Tags
 	valuesFiles        []string
+	validateOverlays   string
 	dryrun             bool
 	diff               bool
+	threeWay           bool
+	showManagedFields  bool
+	fromVersion        flags.Version
+	diffOnly           []string
+	diffChanges        []string
+	diffNames          []string
+	diffKinds          []string
+	showSecrets        bool
+	diffContext        int
+	diffSummary        bool
+	diffArchive        string
+	diffShowMerged     bool
+	driftReport        string
+	diffSource         string
+	diffIgnoreAdded    bool
+	diffIgnoreRemoved  bool
+	diffExitCode       bool
+	diffOutput         string
+	diffIgnore         []string
+	diffOwnedFields    bool
+	diffKeepTimoni     bool
+	diffDir            string
+	keepDiffFiles      bool
+	diffTmpDir         string
+	color              string
+	summary            bool
+	atomicNamespace    bool
 	wait               bool
+	waitConditions     []string
+	waitInterval       time.Duration
+	timeoutJitter      time.Duration
+	namePrefix         string
+	propagateLabels    bool
 	force              bool
+	waitDeleteCreate   bool
+	forceReapply       bool
+	verboseApply       bool
 	overwriteOwnership bool
+	baselineFile       string
+	annotations        []string
+	prune              bool
+	pruneSelector      string
+	planFile           string
 	creds              flags.Credentials
 }
 
 var applyArgs applyFlags
 
+// applyDiffFlags are the flags which customize the dry run diff,
+// setting any of them prints the diff without --diff.
+var applyDiffFlags = []string{
+	"three-way",
+	"show-managed-fields",
+	"from-version",
+	"diff-only",
+	"diff-changes",
+	"diff-name",
+	"diff-kind",
+	"diff-context",
+	"diff-summary",
+	"diff-archive",
+	"diff-show-merged",
+	"diff-ignore-added",
+	"diff-ignore-removed",
+	"diff-output",
+	"diff-ignore",
+	"diff-owned-fields",
+	"diff-keep-timoni-metadata",
+	"diff-dir",
+	"keep-diff-files",
+}
+
 func init() {
 	applyCmd.Flags().VarP(&applyArgs.version, applyArgs.version.Type(), applyArgs.version.Shorthand(), applyArgs.version.Description())
 	applyCmd.Flags().VarP(&applyArgs.pkg, applyArgs.pkg.Type(), applyArgs.pkg.Shorthand(), applyArgs.pkg.Description())
+	applyCmd.Flags().Var(&applyArgs.tags, applyArgs.tags.Type(), applyArgs.tags.Description())
 	applyCmd.Flags().StringSliceVarP(&applyArgs.valuesFiles, "values", "f", nil,
 		"The local path to values files (cue, yaml or json format).")
+	applyCmd.Flags().StringVar(&applyArgs.validateOverlays, "validate-overlays", "",
+		"Report the values files that have no effect on the merged values, can be 'warn' or 'fail'.")
+	applyCmd.Flags().Lookup("validate-overlays").NoOptDefVal = validateOverlaysWarn
 	applyCmd.Flags().BoolVar(&applyArgs.force, "force", false,
 		"Recreate immutable Kubernetes resources.")
+	applyCmd.Flags().BoolVar(&applyArgs.waitDeleteCreate, "wait-for-delete-before-create", false,
+		"When recreating resources with immutable field changes, delete them with foreground propagation and wait for their termination before creating the replacements.")
+	applyCmd.Flags().BoolVar(&applyArgs.forceReapply, "force-reapply", false,
+		"Apply all Kubernetes resources, including the ones unchanged since the last apply, to correct any drift of the live state.")
+	applyCmd.Flags().BoolVar(&applyArgs.verboseApply, "verbose-apply", false,
+		"Log the server response for each applied object, including the resource version, generation and whether the object was created or updated by the server.")
 	applyCmd.Flags().BoolVar(&applyArgs.overwriteOwnership, "overwrite-ownership", false,
 		"Overwrite instance ownership, if the instance is owned by a Bundle.")
 	applyCmd.Flags().BoolVar(&applyArgs.dryrun, "dry-run", false,
 		"Perform a server-side apply dry run.")
 	applyCmd.Flags().BoolVar(&applyArgs.diff, "diff", false,
-		"Perform a server-side apply dry run and prints the diff.")
+		"Perform a server-side apply dry run and prints the diff, the flags customizing the diff e.g. --diff-output imply it.")
+	applyCmd.Flags().BoolVar(&applyArgs.threeWay, "three-way", false,
+		"Print the drift of the live state from the last applied state, and the change from the last applied state to the desired state.")
+	applyCmd.Flags().BoolVar(&applyArgs.showManagedFields, "show-managed-fields", false,
+		"Print the diff including the metadata.managedFields of the live and merged objects.")
+	applyCmd.Flags().Var(&applyArgs.fromVersion, "from-version",
+		"Perform a dry run and print the diff between the objects built from the specified module version and the ones built from '--version', using the same values.")
+	applyCmd.Flags().StringSliceVar(&applyArgs.diffOnly, "diff-only", nil,
+		"Report only the resources with the specified actions, can be 'created', 'configured', 'unchanged', 'deleted' or 'skipped'.")
+	applyCmd.Flags().StringSliceVar(&applyArgs.diffChanges, "diff-changes", nil,
+		"Print only the changes of the specified kinds, can be 'additions', 'removals', 'modifications', 'order-changes' or 'all'.")
+	applyCmd.Flags().StringSliceVar(&applyArgs.diffNames, "diff-name", nil,
+		"Compare only the resources with the specified names, the other resources are reported as skipped.")
+	applyCmd.Flags().StringSliceVar(&applyArgs.diffKinds, "diff-kind", nil,
+		"Compare only the resources of the specified kinds e.g. 'Deployment', the other resources are reported as skipped.")
+	applyCmd.Flags().BoolVar(&applyArgs.showSecrets, "show-secrets", false,
+		"Print the values of the Secrets in the diff, by default the values are redacted. Use this flag only for local debugging.")
+	applyCmd.Flags().IntVar(&applyArgs.diffContext, "diff-context", -1,
+		"Print only the specified number of unchanged lines around the changes of multiline text values. "+
+			"When negative, the whole text is printed.")
+	applyCmd.Flags().BoolVar(&applyArgs.diffSummary, "diff-summary", false,
+		"Print the number of added, removed and modified fields of each resource instead of the full diff.")
+	applyCmd.Flags().StringVar(&applyArgs.diffArchive, "diff-archive", "",
+		"Write a copy of the diff without colors to the specified file, while printing it to stdout.")
+	applyCmd.Flags().BoolVar(&applyArgs.diffShowMerged, "diff-show-merged", false,
+		"Print the full merged object of each configured resource after its diff.")
+	applyCmd.Flags().StringVar(&applyArgs.driftReport, "drift-report", "",
+		"Perform a server-side apply dry run and write to the specified file a JSON report listing the resources which have drifted from the desired state, "+
+			"the resources missing from the cluster and the stale ones. The report is written whether the diff is printed or not.")
+	applyCmd.Flags().StringVar(&applyArgs.diffSource, "diff-source", DiffSourceLive,
+		"Perform a dry run and compare the desired state with the specified source, can be 'live' or 'last-applied'. "+
+			"The last applied state is built from the module and values recorded in the instance storage, without querying the live objects.")
+	applyCmd.Flags().BoolVar(&applyArgs.diffIgnoreAdded, "diff-ignore-added", false,
+		"Print the diff without the fields added to the live objects.")
+	applyCmd.Flags().BoolVar(&applyArgs.diffIgnoreRemoved, "diff-ignore-removed", false,
+		"Print the diff without the fields removed from the live objects.")
+	applyCmd.Flags().BoolVar(&applyArgs.diffExitCode, "diff-exit-code", false,
+		"Perform a server-side apply dry run and exit with code 2 if any of the resources would be created, configured or deleted.")
+	applyCmd.Flags().StringVar(&applyArgs.diffOutput, "diff-output", DyffHumanFormat,
+		"Print the diff in the specified format, can be 'human', 'json' or 'markdown'.")
+	applyCmd.Flags().StringArrayVar(&applyArgs.diffIgnore, "diff-ignore", nil,
+		"Ignore the changes of the fields at the specified path, in the dot format e.g. 'metadata.annotations.*' or the JSON pointer format e.g. '/status'. This flag can be repeated.")
+	applyCmd.Flags().BoolVar(&applyArgs.diffOwnedFields, "diff-owned-fields", false,
+		"Print the diff of the fields owned by Timoni, ignoring the fields set by the API server or by other controllers.")
+	applyCmd.Flags().BoolVar(&applyArgs.diffKeepTimoni, "diff-keep-timoni-metadata", false,
+		"Print the changes of the labels and annotations injected by Timoni e.g. 'instance.timoni.sh/name', by default they are ignored.")
+	applyCmd.Flags().StringVar(&applyArgs.diffDir, "diff-dir", "",
+		"Write the diff of each configured resource to a separate file in the specified directory, named '<namespace>_<kind>_<name>.diff'.")
+	applyCmd.Flags().BoolVar(&applyArgs.keepDiffFiles, "keep-diff-files", false,
+		"Keep the live and merged YAML files compared for each configured resource in a temporary directory, for debugging the diff.")
+	applyCmd.Flags().StringVar(&applyArgs.diffTmpDir, "diff-tmp-dir", "",
+		"The directory where the temporary files of the module build and diff are written, including the ones kept with --keep-diff-files. "+
+			"Defaults to the system temporary directory, the directory must be writable.")
+	applyCmd.Flags().StringVar(&applyArgs.color, "color", "",
+		"Colorize the diff, can be 'auto', 'always' or 'never'. When not specified, the DYFF_COLOR environment variable is used and defaults to 'auto'.")
+	applyCmd.Flags().BoolVar(&applyArgs.atomicNamespace, "atomic-namespace", false,
+		"Apply the resources grouped by namespace, and roll back the resources of a namespace if they fail to apply or become ready.")
 	applyCmd.Flags().BoolVar(&applyArgs.wait, "wait", true,
 		"Wait for the applied Kubernetes objects to become ready.")
+	applyCmd.Flags().BoolVar(&applyArgs.summary, "summary", true,
+		"Print the number of resources by action at the end of the apply.")
+	applyCmd.Flags().DurationVar(&applyArgs.waitInterval, "wait-interval", 5*time.Second,
+		"The interval at which the readiness of the applied Kubernetes objects is polled.")
+	applyCmd.Flags().DurationVar(&applyArgs.timeoutJitter, "timeout-jitter", 0,
+		"Delay the start by a random duration up to the specified value and randomize the registry retry backoffs, to spread the load of concurrent applies.")
+	applyCmd.Flags().StringArrayVar(&applyArgs.waitConditions, "wait-condition", nil,
+		"Wait for the objects of the specified kind to reach a status condition, in the format '<kind>:<type>=<status>[:<timeout>]'. This flag can be repeated.")
+	applyCmd.Flags().BoolVar(&applyArgs.propagateLabels, "propagate-labels-to-namespace", false,
+		"Add the labels common to all the instance resources to the namespace. Existing namespace labels are not overwritten.")
+	applyCmd.Flags().StringVar(&applyArgs.namePrefix, "name-prefix", "",
+		"Prefix the names of the generated resources, their references and their app.kubernetes.io/name and instance labels and selectors, when set without a value the instance name is used as prefix.")
+	applyCmd.Flags().Lookup("name-prefix").NoOptDefVal = namePrefixInstance
+	applyCmd.Flags().StringVar(&applyArgs.baselineFile, "diff-save-baseline", "",
+		"Save the live state of the applied Kubernetes objects to the specified file, to be used as a baseline for drift detection.")
+	applyCmd.Flags().StringArrayVar(&applyArgs.annotations, "inventory-annotation", nil,
+		"Annotation in the format key=value stored with the instance inventory e.g. the Git commit or the CI pipeline ID, can be specified multiple times.")
+	applyCmd.Flags().BoolVar(&applyArgs.prune, "prune", true,
+		"Delete the resources which were previously applied but are missing from the current instance, when disabled the resources are kept in the instance inventory.")
+	applyCmd.Flags().StringVar(&applyArgs.pruneSelector, "prune-selector", "",
+		"Label selector e.g. 'app=frontend' which restricts the pruning to the stale resources with matching labels, the other stale resources are kept in the instance inventory.")
+	applyCmd.Flags().StringVar(&applyArgs.planFile, "plan", "",
+		"Apply the changes from the plan file computed with 'timoni plan', failing if the cluster state changed since the plan was computed.")
 	applyCmd.Flags().Var(&applyArgs.creds, applyArgs.creds.Type(), applyArgs.creds.Description())
 	rootCmd.AddCommand(applyCmd)
 }
 
 func runApplyCmd(cmd *cobra.Command, args []string) error {
+	if applyArgs.planFile != "" {
+		if len(args) > 0 {
+			return errors.New("the instance name and module are read from the plan file")
+		}
+		return runApplyPlan(cmd, applyArgs.planFile)
+	}
+
 	if len(args) < 2 {
 		return errors.New("name and module are required")
 	}
 
-	applyArgs.name = args[0]
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	applyArgs.name = name
 	applyArgs.module = args[1]
 
+	if applyArgs.waitInterval <= 0 {
+		return fmt.Errorf("wait interval must be greater than zero")
+	}
+
+	if applyArgs.timeoutJitter < 0 {
+		return fmt.Errorf("timeout jitter must not be negative")
+	}
+
+	if err := validateDiffSource(applyArgs.diffSource); err != nil {
+		return err
+	}
+	lastAppliedSource := applyArgs.diffSource == DiffSourceLastApplied
+	if lastAppliedSource && (applyArgs.fromVersion != "" || applyArgs.threeWay) {
+		return fmt.Errorf("--diff-source=%s cannot be used with --from-version or --three-way", DiffSourceLastApplied)
+	}
+
+	if applyArgs.fromVersion != "" && !strings.HasPrefix(applyArgs.module, apiv1.ArtifactPrefix) {
+		return fmt.Errorf("--from-version requires a module pulled from a container registry")
+	}
+
+	diffActions, err := parseDiffActions(applyArgs.diffOnly)
+	if err != nil {
+		return err
+	}
+
+	diffChanges, err := parseDiffChanges(applyArgs.diffChanges)
+	if err != nil {
+		return err
+	}
+
+	diffIgnorePaths, err := parseDiffIgnorePaths(applyArgs.diffIgnore)
+	if err != nil {
+		return err
+	}
+
+	if applyArgs.diffOutput == "" {
+		applyArgs.diffOutput = DyffHumanFormat
+	}
+	if err := validateDyffFormat(applyArgs.diffOutput); err != nil {
+		return err
+	}
+	if applyArgs.diffSummary && applyArgs.diffOutput == DyffJSONFormat {
+		return fmt.Errorf("diff summary and JSON diff output are mutually exclusive")
+	}
+	if applyArgs.diffShowMerged && applyArgs.diffOutput == DyffJSONFormat {
+		return fmt.Errorf("diff show merged and JSON diff output are mutually exclusive")
+	}
+	if err := validateColorMode(applyArgs.color); err != nil {
+		return err
+	}
+	if err := validateTmpDir(applyArgs.diffTmpDir); err != nil {
+		return err
+	}
+
+	inventoryAnnotations, err := parseInventoryAnnotations(applyArgs.annotations)
+	if err != nil {
+		return err
+	}
+
+	var pruneSelector labels.Selector
+	if applyArgs.pruneSelector != "" {
+		pruneSelector, err = labels.Parse(applyArgs.pruneSelector)
+		if err != nil {
+			return fmt.Errorf("invalid prune selector: %w", err)
+		}
+	}
+
+	var waitConditions []runtime.WaitCondition
+	for _, wc := range applyArgs.waitConditions {
+		cond, err := runtime.ParseWaitCondition(wc)
+		if err != nil {
+			return err
+		}
+		waitConditions = append(waitConditions, cond)
+	}
+
 	log := LoggerInstance(cmd.Context(), applyArgs.name)
 
+	if applyArgs.timeoutJitter > 0 {
+		delay := time.Duration(rand.Int63n(int64(applyArgs.timeoutJitter)))
+		log.Info(fmt.Sprintf("delaying start by %s", delay.Round(time.Millisecond)))
+		select {
+		case <-cmd.Context().Done():
+			return cmd.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+
 	version := applyArgs.version.String()
 	if version == "" {
 		version = apiv1.LatestVersion
@@ -154,7 +502,7 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 		log.Info(fmt.Sprintf("building %s", applyArgs.module))
 	}
 
-	tmpDir, err := os.MkdirTemp("", apiv1.FieldManager)
+	tmpDir, err := os.MkdirTemp(applyArgs.diffTmpDir, apiv1.FieldManager)
 	if err != nil {
 		return err
 	}
@@ -172,6 +520,8 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 		applyArgs.creds.String(),
 		rootArgs.registryInsecure,
 	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
+	fetcher.SetRetryJitter(applyArgs.timeoutJitter > 0)
 	mod, err := fetcher.Fetch()
 	if err != nil {
 		return err
@@ -197,8 +547,13 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 
 	log.Info(fmt.Sprintf("using module %s version %s", mod.Name, mod.Version))
 
+	var valuesCue [][]byte
 	if len(applyArgs.valuesFiles) > 0 {
-		valuesCue, err := convertToCue(cmd, applyArgs.valuesFiles)
+		valuesCue, err = convertToCue(cmd, applyArgs.valuesFiles)
+		if err != nil {
+			return err
+		}
+		err = validateValuesOverlays(log, builder, applyArgs.valuesFiles, valuesCue, applyArgs.validateOverlays)
 		if err != nil {
 			return err
 		}
@@ -215,7 +570,7 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 
 	builder.SetVersionInfo(mod.Version, kubeVersion)
 
-	buildResult, err := builder.Build()
+	buildResult, err := builder.Build(applyArgs.tags...)
 	if err != nil {
 		return describeErr(fetcher.GetModuleRoot(), "build failed", err)
 	}
@@ -235,18 +590,39 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 		objects = append(objects, set.Objects...)
 	}
 
-	rm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if applyArgs.namePrefix != "" {
+		runtime.PrefixNames(objects, namePrefix(applyArgs.namePrefix, applyArgs.name))
+	}
+
+	if err := runtime.ValidateWaitConditions(objects, waitConditions); err != nil {
+		return err
+	}
+
+	warnings := runtime.NewWarningRecorder()
+	rm, err := runtime.NewResourceManagerWithWarnings(kubeconfigArgs, warnings)
 	if err != nil {
 		return err
 	}
+	defer logServerWarnings(log, warnings)
 
 	rm.SetOwnerLabels(objects, applyArgs.name, *kubeconfigArgs.Namespace)
 
 	ctx, cancel := context.WithTimeout(cmd.Context(), rootArgs.timeout)
 	defer cancel()
 
+	preserved, err := runtime.PreservePlacements(ctx, rm, objects)
+	if err != nil {
+		return fmt.Errorf("preserving the node placement failed: %w", err)
+	}
+	for _, obj := range preserved {
+		logJoin(log, obj, "node placement preserved")
+	}
+
 	exists := false
 	sm := runtime.NewStorageManager(rm)
+	if applyArgs.propagateLabels {
+		sm.SetNamespaceLabels(runtime.CommonLabels(objects))
+	}
 	instance, err := sm.Get(ctx, applyArgs.name, *kubeconfigArgs.Namespace)
 	if err == nil {
 		exists = true
@@ -265,6 +641,10 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	im := runtime.NewInstanceManager(applyArgs.name, *kubeconfigArgs.Namespace, finalValues, *mod)
+	im.Instance.Annotations = inventoryAnnotations
+	if kubeContext, err := runtime.KubeContext(kubeconfigArgs); err == nil {
+		im.Instance.ClusterContext = kubeContext
+	}
 
 	if err := im.AddObjects(objects); err != nil {
 		return fmt.Errorf("adding objects to instance failed: %w", err)
@@ -275,11 +655,152 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("getting stale objects failed: %w", err)
 	}
 
-	if applyArgs.dryrun || applyArgs.diff {
-		if !nsExists {
-			log.Info(colorizeJoin(colorizeNamespaceFromArgs(), ssa.CreatedAction, dryRunServer))
+	if pruneSelector != nil && len(staleObjects) > 0 {
+		var skippedObjects []*unstructured.Unstructured
+		staleObjects, skippedObjects, err = runtime.SelectObjectsByLabels(ctx, rm, staleObjects, pruneSelector)
+		if err != nil {
+			return fmt.Errorf("selecting stale objects failed: %w", err)
+		}
+		for _, obj := range skippedObjects {
+			logJoin(log, obj, ssa.SkippedAction, "(prune selector mismatch)")
+		}
+		im.RetainObjects(instance, skippedObjects)
+	}
+
+	if !applyArgs.prune && len(staleObjects) > 0 {
+		for _, obj := range staleObjects {
+			logJoin(log, obj, ssa.SkippedAction, "(prune disabled)")
+		}
+		im.RetainObjects(instance, staleObjects)
+		staleObjects = nil
+	}
+
+	withDiff := applyArgs.diff || lastAppliedSource || slices.ContainsFunc(applyDiffFlags, cmd.Flags().Changed)
+	if applyArgs.dryrun || applyArgs.diffExitCode || applyArgs.driftReport != "" || withDiff {
+		diffOpts := dryRunDiffOptions{
+			withDiff:           withDiff,
+			showManagedFields:  applyArgs.showManagedFields,
+			onlyActions:        diffActions,
+			onlyChanges:        diffChanges,
+			ignoreAdded:        applyArgs.diffIgnoreAdded,
+			ignoreRemoved:      applyArgs.diffIgnoreRemoved,
+			format:             applyArgs.diffOutput,
+			ignorePaths:        diffIgnorePaths,
+			ownedFieldsOnly:    applyArgs.diffOwnedFields,
+			keepTimoniMetadata: applyArgs.diffKeepTimoni,
+			diffDir:            applyArgs.diffDir,
+			color:              applyArgs.color,
+			names:              applyArgs.diffNames,
+			kinds:              applyArgs.diffKinds,
+			showSecrets:        applyArgs.showSecrets,
+			summary:            applyArgs.diffSummary,
+			showMerged:         applyArgs.diffShowMerged,
+			tmpDir:             tmpDir,
+		}
+		if applyArgs.diffContext >= 0 {
+			diffOpts.contextLines = &applyArgs.diffContext
+		}
+		if applyArgs.diffArchive != "" {
+			archive, err := os.Create(applyArgs.diffArchive)
+			if err != nil {
+				return fmt.Errorf("failed to create the diff archive: %w", err)
+			}
+			defer archive.Close()
+			diffOpts.output = NewDiffWriter(rootCmd.OutOrStdout()).AddPlain(applyArgs.diffArchive, archive)
+		}
+
+		if applyArgs.driftReport != "" {
+			if applyArgs.fromVersion != "" || lastAppliedSource {
+				return fmt.Errorf("drift report requires the diff with the live objects, it can't be used with --from-version or --diff-source=%s", DiffSourceLastApplied)
+			}
+			diffOpts.driftReport = newDriftReport(applyArgs.name, *kubeconfigArgs.Namespace)
+		}
+
+		if applyArgs.fromVersion != "" {
+			fromObjects, err := buildModuleVersion(ctx, moduleVersionBuild{
+				description: "from version",
+				name:        applyArgs.name,
+				namespace:   *kubeconfigArgs.Namespace,
+				repository:  applyArgs.module,
+				version:     applyArgs.fromVersion.String(),
+				pkg:         applyArgs.pkg.String(),
+				tags:        applyArgs.tags,
+				values:      valuesCue,
+				creds:       applyArgs.creds.String(),
+				kubeVersion: kubeVersion,
+				dir:         filepath.Join(tmpDir, "from-version"),
+			})
+			if err != nil {
+				return err
+			}
+			if applyArgs.namePrefix != "" {
+				runtime.PrefixNames(fromObjects, namePrefix(applyArgs.namePrefix, applyArgs.name))
+			}
+			rm.SetOwnerLabels(fromObjects, applyArgs.name, *kubeconfigArgs.Namespace)
+
+			changes, err := versionDiff(logr.NewContext(ctx, log), fromObjects, objects, applyArgs.fromVersion.String(), mod.Version, diffOpts)
+			if err != nil {
+				return err
+			}
+			return driftExitCode(applyArgs.diffExitCode, changes)
+		}
+
+		if lastAppliedSource {
+			if !exists {
+				return fmt.Errorf("--diff-source=%s requires an existing instance", DiffSourceLastApplied)
+			}
+			lastApplied, err := buildLastApplied(ctx, instance, applyArgs.pkg.String(), applyArgs.tags, applyArgs.creds.String(), kubeVersion, tmpDir)
+			if err != nil {
+				return err
+			}
+			if applyArgs.namePrefix != "" {
+				runtime.PrefixNames(lastApplied, namePrefix(applyArgs.namePrefix, applyArgs.name))
+			}
+			rm.SetOwnerLabels(lastApplied, applyArgs.name, *kubeconfigArgs.Namespace)
+
+			changes, err := versionDiff(logr.NewContext(ctx, log), lastApplied, objects, "last applied", mod.Version, diffOpts)
+			if err != nil {
+				return err
+			}
+			return driftExitCode(applyArgs.diffExitCode, changes)
+		}
+
+		if !nsExists && diffOpts.showAction(ssa.CreatedAction) {
+			logJoin(log, colorizeNamespaceFromArgs(), ssa.CreatedAction, dryRunServer)
+		}
+		if applyArgs.threeWay && exists {
+			diffOpts.lastApplied, err = buildLastApplied(ctx, instance, applyArgs.pkg.String(), applyArgs.tags, applyArgs.creds.String(), kubeVersion, tmpDir)
+			if err != nil {
+				return err
+			}
+			if applyArgs.namePrefix != "" {
+				runtime.PrefixNames(diffOpts.lastApplied, namePrefix(applyArgs.namePrefix, applyArgs.name))
+			}
+			rm.SetOwnerLabels(diffOpts.lastApplied, applyArgs.name, *kubeconfigArgs.Namespace)
+		}
+
+		if applyArgs.keepDiffFiles {
+			// The directory is not removed when the command exits.
+			diffOpts.keepFilesDir, err = os.MkdirTemp(applyArgs.diffTmpDir, apiv1.FieldManager+"-diff-")
+			if err != nil {
+				return err
+			}
+		}
+
+		changes, err := instanceDryRunDiff(logr.NewContext(ctx, log), rm, objects, staleObjects, nsExists, diffOpts)
+		if err != nil {
+			return err
+		}
+		if diffOpts.driftReport != nil {
+			if err := diffOpts.driftReport.write(applyArgs.driftReport); err != nil {
+				return err
+			}
+			log.Info(fmt.Sprintf("drift report written to %s", colorizeSubject(applyArgs.driftReport)))
+		}
+		if diffOpts.keepFilesDir != "" {
+			log.Info(fmt.Sprintf("diff files saved to %s", colorizeSubject(diffOpts.keepFilesDir)))
 		}
-		return instanceDryRunDiff(logr.NewContext(ctx, log), rm, objects, staleObjects, nsExists, tmpDir, applyArgs.diff)
+		return driftExitCode(applyArgs.diffExitCode, changes)
 	}
 
 	if !exists {
@@ -290,14 +811,14 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 		}
 
 		if !nsExists {
-			log.Info(colorizeJoin(colorizeNamespaceFromArgs(), ssa.CreatedAction))
+			logJoin(log, colorizeNamespaceFromArgs(), ssa.CreatedAction)
 		}
 	} else {
 		log.Info(fmt.Sprintf("upgrading %s in namespace %s", applyArgs.name, *kubeconfigArgs.Namespace))
 	}
 
 	applyOpts := runtime.ApplyOptions(applyArgs.force, rootArgs.timeout)
-	applyOpts.WaitInterval = 5 * time.Second
+	applyOpts.WaitInterval = applyArgs.waitInterval
 
 	waitOptions := ssa.WaitOptions{
 		Interval: applyOpts.WaitInterval,
@@ -305,28 +826,100 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 		FailFast: true,
 	}
 
+	applied := ssa.NewChangeSet()
+	failedNamespaces := make(map[string]error)
 	for _, set := range applySets {
 		if len(applySets) > 1 {
 			log.Info(fmt.Sprintf("applying %s", set.Name))
 		}
 
-		cs, err := rm.ApplyAllStaged(ctx, set.Objects, applyOpts)
-		if err != nil {
-			return err
+		setObjects := set.Objects
+		var skippedObjects []*unstructured.Unstructured
+		if exists && !applyArgs.forceReapply {
+			setObjects, skippedObjects, err = changedObjects(logr.NewContext(ctx, log), rm, instance, set.Objects, applied)
+			if err != nil {
+				return err
+			}
 		}
-		for _, change := range cs.Entries {
-			log.Info(colorizeJoin(change))
+
+		if !applyArgs.atomicNamespace {
+			if err := applyObjects(logr.NewContext(ctx, log), rm, setObjects, skippedObjects, applyOpts, waitOptions, waitConditions, applied); err != nil {
+				return err
+			}
+			continue
 		}
 
-		if applyArgs.wait {
-			spin := StartSpinner(fmt.Sprintf("waiting for %v resource(s) to become ready...", len(set.Objects)))
-			err = rm.Wait(set.Objects, waitOptions)
-			spin.Stop()
+		namespaces, _ := runtime.GroupByNamespace(set.Objects)
+		_, groups := runtime.GroupByNamespace(setObjects)
+		_, skippedGroups := runtime.GroupByNamespace(skippedObjects)
+		for _, ns := range namespaces {
+			if _, failed := failedNamespaces[ns]; failed {
+				log.Info(colorizeJoin("skipping", len(groups[ns]), "resource(s) in failed namespace", colorizeSubject(printOrPass(ns))))
+				continue
+			}
+
+			snapshot, err := runtime.TakeSnapshot(ctx, rm, groups[ns])
+			if err != nil {
+				return err
+			}
+
+			if err := applyObjects(logr.NewContext(ctx, log), rm, groups[ns], skippedGroups[ns], applyOpts, waitOptions, waitConditions, applied); err != nil {
+				log.Error(err, colorizeJoin("rolling back namespace", colorizeSubject(printOrPass(ns))))
+				failedNamespaces[ns] = err
+
+				cs, restoreErr := snapshot.Restore(ctx, rm)
+				for _, change := range cs.Entries {
+					logJoin(log, change)
+				}
+				if restoreErr != nil {
+					return fmt.Errorf("rollback of namespace %s failed: %w", printOrPass(ns), restoreErr)
+				}
+			}
+		}
+	}
+
+	if len(failedNamespaces) > 0 {
+		// The objects applied in the other namespaces are recorded in the inventory,
+		// while the failed namespaces keep the entries of the last apply. The stale
+		// objects are not pruned, so they remain in the inventory too.
+		var failedObjects []*unstructured.Unstructured
+		for _, obj := range objects {
+			if _, failed := failedNamespaces[obj.GetNamespace()]; failed {
+				failedObjects = append(failedObjects, obj)
+			}
+		}
+		im.RemoveObjects(failedObjects)
+
+		retainedObjects := append([]*unstructured.Unstructured{}, staleObjects...)
+		if exists {
+			last := runtime.InstanceManager{Instance: *instance}
+			lastObjects, err := last.ListObjects()
 			if err != nil {
 				return err
 			}
-			log.Info("resources are ready")
+			for _, obj := range lastObjects {
+				if _, failed := failedNamespaces[obj.GetNamespace()]; failed {
+					retainedObjects = append(retainedObjects, obj)
+				}
+			}
 		}
+		im.RetainObjects(instance, retainedObjects)
+
+		if err := sm.Apply(ctx, &im.Instance, true); err != nil {
+			return fmt.Errorf("storing instance failed: %w", err)
+		}
+
+		var namespaces []string
+		for ns := range failedNamespaces {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+
+		var errs []error
+		for _, ns := range namespaces {
+			errs = append(errs, fmt.Errorf("namespace %s: %w", printOrPass(ns), failedNamespaces[ns]))
+		}
+		return fmt.Errorf("apply failed and was rolled back in %v namespace(s): %w", len(failedNamespaces), errors.Join(errs...))
 	}
 
 	if images, err := builder.GetContainerImages(buildResult); err == nil {
@@ -345,14 +938,15 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("pruning objects failed: %w", err)
 		}
 		deletedObjects = runtime.SelectObjectsFromSet(changeSet, ssa.DeletedAction)
+		applied.Append(changeSet.Entries)
 		for _, change := range changeSet.Entries {
-			log.Info(colorizeJoin(change))
+			logJoin(log, change)
 		}
 	}
 
 	if applyArgs.wait {
 		if len(deletedObjects) > 0 {
-			spin := StartSpinner(fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
+			spin := StartSpinnerWithTimer(log, fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
 			err = rm.WaitForTermination(deletedObjects, waitOptions)
 			spin.Stop()
 			if err != nil {
@@ -363,12 +957,269 @@ func runApplyCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if applyArgs.baselineFile != "" {
+		if err := saveBaseline(ctx, rm, objects, applyArgs.baselineFile); err != nil {
+			return fmt.Errorf("saving baseline failed: %w", err)
+		}
+		log.Info(fmt.Sprintf("baseline saved to %s", colorizeSubject(applyArgs.baselineFile)))
+	}
+
+	if applyArgs.summary {
+		log.Info(summarizeChangeSet(applied, 0, false))
+	}
+
 	return nil
 }
 
+// driftExitCode returns an error with the exit code 2 if enabled
+// and the dry run found changes, otherwise it returns nil.
+func driftExitCode(enabled bool, changes int) error {
+	if !enabled || changes == 0 {
+		return nil
+	}
+	return &exitCodeError{
+		code: 2,
+		err:  fmt.Errorf("drift detected, %d resource(s) would be changed", changes),
+	}
+}
+
+// logServerWarnings logs the warnings returned by the API server,
+// such as the deprecated API versions and the admission webhooks notices.
+func logServerWarnings(log logr.Logger, warnings *runtime.WarningRecorder) {
+	for _, warning := range warnings.Flush() {
+		log.Info(colorizeJoin(colorizeWarning("server warning:"), warning))
+	}
+}
+
+// changedObjects returns the objects whose content hash differs from the one
+// recorded in the instance inventory at the last apply, or that are missing
+// from the cluster, along with the unchanged objects which are logged as skipped.
+func changedObjects(ctx context.Context,
+	rm *ssa.ResourceManager,
+	instance *apiv1.Instance,
+	objects []*unstructured.Unstructured,
+	applied *ssa.ChangeSet) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	log := LoggerFrom(ctx)
+	last := runtime.InstanceManager{Instance: *instance}
+
+	var changed, skipped []*unstructured.Unstructured
+	for _, obj := range objects {
+		digest, err := runtime.ObjectDigest(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if last.DigestOf(object.UnstructuredToObjMetadata(obj)) != digest {
+			changed = append(changed, obj)
+			continue
+		}
+
+		live, err := liveMetadata(ctx, rm, obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		if live == nil {
+			changed = append(changed, obj)
+			continue
+		}
+
+		skipped = append(skipped, obj)
+		applied.Add(*runtime.NewChangeSetEntry(obj, ssa.SkippedAction))
+		logJoin(log, obj, ssa.SkippedAction, "(unchanged since last apply)")
+	}
+
+	return changed, skipped, nil
+}
+
+// applyObjects applies the given objects in stages and, if enabled,
+// waits for them and for the skipped objects to become ready and for
+// the custom conditions to be met. The apply results are appended to
+// the given change set.
+func applyObjects(ctx context.Context,
+	rm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	skipped []*unstructured.Unstructured,
+	applyOpts ssa.ApplyOptions,
+	waitOptions ssa.WaitOptions,
+	waitConditions []runtime.WaitCondition,
+	applied *ssa.ChangeSet) error {
+	log := LoggerFrom(ctx)
+
+	if len(objects) == 0 {
+		return waitObjects(ctx, rm, skipped, waitOptions, waitConditions)
+	}
+
+	var resourceVersions map[string]string
+	if applyArgs.verboseApply {
+		resourceVersions = make(map[string]string, len(objects))
+		for _, obj := range objects {
+			live, err := liveMetadata(ctx, rm, obj)
+			if err != nil {
+				return err
+			}
+			if live != nil {
+				resourceVersions[ssa.FmtUnstructured(obj)] = live.GetResourceVersion()
+			}
+		}
+	}
+
+	if applyArgs.waitDeleteCreate {
+		spin := StartSpinnerWithTimer(log, "waiting for the replaced resources to be finalized...")
+		cs, err := runtime.DeleteImmutable(ctx, rm, objects, applyOpts, waitOptions)
+		spin.Stop()
+		if cs != nil {
+			for _, change := range cs.Entries {
+				logJoin(log, change, "(recreate)")
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	cs, err := rm.ApplyAllStaged(ctx, objects, applyOpts)
+	if err != nil {
+		return err
+	}
+	applied.Append(cs.Entries)
+	for _, change := range cs.Entries {
+		logJoin(log, change)
+	}
+
+	if applyArgs.verboseApply {
+		for _, obj := range objects {
+			live, err := liveMetadata(ctx, rm, obj)
+			if err != nil {
+				return err
+			}
+			if live == nil {
+				continue
+			}
+
+			result := "updated"
+			previous, existed := resourceVersions[ssa.FmtUnstructured(obj)]
+			switch {
+			case !existed:
+				result = "created"
+			case previous == live.GetResourceVersion():
+				result = "unchanged"
+			}
+
+			logJoin(log, obj, colorizeInfo("server "+result),
+				fmt.Sprintf("resourceVersion=%s generation=%d uid=%s",
+					live.GetResourceVersion(), live.GetGeneration(), live.GetUID()))
+		}
+	}
+
+	return waitObjects(ctx, rm, append(objects[:len(objects):len(objects)], skipped...), waitOptions, waitConditions)
+}
+
+// waitObjects waits for the given objects to become ready and for
+// the custom conditions to be met, if waiting is enabled.
+func waitObjects(ctx context.Context,
+	rm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	waitOptions ssa.WaitOptions,
+	waitConditions []runtime.WaitCondition) error {
+	log := LoggerFrom(ctx)
+
+	if !applyArgs.wait || len(objects) == 0 {
+		return nil
+	}
+
+	spin := StartSpinner(fmt.Sprintf("waiting for %v resource(s) to become ready...", len(objects)))
+	err := rm.Wait(objects, waitOptions)
+	spin.Stop()
+	if err != nil {
+		return err
+	}
+	log.Info("resources are ready")
+
+	if len(waitConditions) > 0 {
+		spin := StartSpinner(fmt.Sprintf("waiting for %v condition(s) to be met...", len(waitConditions)))
+		err = runtime.WaitForConditions(ctx, rm, objects, waitConditions, waitOptions.Interval, rootArgs.timeout)
+		spin.Stop()
+		if err != nil {
+			return err
+		}
+		log.Info("conditions are met")
+	}
+
+	return nil
+}
+
+// liveMetadata fetches the metadata of the given object from the cluster,
+// it returns nil if the object doesn't exist.
+func liveMetadata(ctx context.Context, rm *ssa.ResourceManager, obj *unstructured.Unstructured) (*metav1.PartialObjectMetadata, error) {
+	live := &metav1.PartialObjectMetadata{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s query failed: %w", ssa.FmtUnstructured(obj), err)
+	}
+	return live, nil
+}
+
+// saveBaseline fetches the live state of the given objects from the cluster
+// and writes it as a multi-doc YAML to the given file.
+func saveBaseline(ctx context.Context, rm *ssa.ResourceManager, objects []*unstructured.Unstructured, file string) error {
+	var sb strings.Builder
+	for _, object := range objects {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(object.GroupVersionKind())
+		if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(object), live); err != nil {
+			return fmt.Errorf("%s query failed: %w", ssa.FmtUnstructured(object), err)
+		}
+		unstructured.RemoveNestedField(live.Object, "metadata", "managedFields")
+
+		data, err := yaml.Marshal(live)
+		if err != nil {
+			return fmt.Errorf("converting objects failed: %w", err)
+		}
+		sb.WriteString("---\n")
+		sb.Write(data)
+	}
+	return os.WriteFile(file, []byte(sb.String()), 0644)
+}
+
 func instanceOwnershipConflicts(instance apiv1.Instance) error {
 	if currentOwnerBundle := instance.Labels[apiv1.BundleNameLabelKey]; currentOwnerBundle != "" {
 		return fmt.Errorf("instance ownership conflict encountered. Apply with \"--overwrite-ownership\" to gain instance ownership. Conflict: instance \"%s\" exists and is managed by bundle \"%s\"", instance.Name, currentOwnerBundle)
 	}
 	return nil
 }
+
+// namePrefixInstance is the value of the --name-prefix flag
+// when set without a value, which selects the instance name as prefix.
+const namePrefixInstance = "@instance"
+
+// namePrefix returns the resource name prefix for the given flag value.
+func namePrefix(flagValue, instanceName string) string {
+	if flagValue == namePrefixInstance {
+		return instanceName
+	}
+	return flagValue
+}
+
+// parseInventoryAnnotations converts the given key=value pairs to a map,
+// returning an error if any of the keys is not a valid annotation name.
+func parseInventoryAnnotations(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	annotations := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid inventory annotation '%s', must be in the format key=value", arg)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid inventory annotation key '%s': %s", key, strings.Join(errs, ", "))
+		}
+		annotations[key] = value
+	}
+	return annotations, nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/runtime"
+)
+
+// readPlan loads the plan computed with 'timoni plan' from the given file.
+func readPlan(file string) (*applyPlan, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan applyPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", file, err)
+	}
+
+	if plan.Kind != planKind || plan.APIVersion != apiv1.GroupVersion.String() {
+		return nil, fmt.Errorf("invalid plan %s: expected kind %s with apiVersion %s",
+			file, planKind, apiv1.GroupVersion.String())
+	}
+
+	if plan.Instance.Name == "" || plan.Instance.Namespace == "" {
+		return nil, fmt.Errorf("invalid plan %s: instance name and namespace are required", file)
+	}
+
+	return &plan, nil
+}
+
+// verifyPlan compares the resourceVersion of the live objects with the ones
+// recorded in the plan, and returns an error for each object changed since
+// the plan was computed, including the planned creates that now exist.
+func verifyPlan(ctx context.Context, rm *ssa.ResourceManager, plan *applyPlan) error {
+	var errs []error
+	for _, change := range plan.Changes {
+		obj := change.object()
+		live, err := liveMetadata(ctx, rm, obj)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case change.Action == ssa.CreatedAction.String() || change.ResourceVersion == "":
+			// A planned create must still be absent, otherwise applying the plan
+			// would silently take over an object it has never seen.
+			if live != nil {
+				errs = append(errs, fmt.Errorf("%s planned for creation already exists", ssa.FmtUnstructured(obj)))
+			}
+		case live == nil:
+			errs = append(errs, fmt.Errorf("%s was deleted", ssa.FmtUnstructured(obj)))
+		case change.ResourceVersion != live.GetResourceVersion():
+			errs = append(errs, fmt.Errorf("%s was modified (resourceVersion %s, planned %s)",
+				ssa.FmtUnstructured(obj), live.GetResourceVersion(), change.ResourceVersion))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("the cluster state changed since the plan was computed: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// runApplyPlan applies the objects and prunes the stale ones, as recorded in the
+// plan file, then stores the planned instance inventory in the cluster.
+func runApplyPlan(cmd *cobra.Command, file string) error {
+	plan, err := readPlan(file)
+	if err != nil {
+		return err
+	}
+
+	name := plan.Instance.Name
+	namespace := plan.Instance.Namespace
+	log := LoggerInstance(cmd.Context(), name)
+
+	warnings := runtime.NewWarningRecorder()
+	rm, err := runtime.NewResourceManagerWithWarnings(kubeconfigArgs, warnings)
+	if err != nil {
+		return err
+	}
+	defer logServerWarnings(log, warnings)
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), rootArgs.timeout)
+	defer cancel()
+
+	sm := runtime.NewStorageManager(rm)
+	instance, err := sm.Get(ctx, name, namespace)
+	exists := err == nil
+
+	if !applyArgs.overwriteOwnership && exists {
+		if err := instanceOwnershipConflicts(*instance); err != nil {
+			return err
+		}
+	}
+
+	if err := verifyPlan(ctx, rm, plan); err != nil {
+		return err
+	}
+
+	log.Info(fmt.Sprintf("applying plan for module %s version %s",
+		plan.Instance.Module.Name, plan.Instance.Module.Version))
+
+	if !exists {
+		log.Info(fmt.Sprintf("installing %s in namespace %s", name, namespace))
+
+		if err := sm.Apply(ctx, &plan.Instance, true); err != nil {
+			return fmt.Errorf("instance init failed: %w", err)
+		}
+	} else {
+		log.Info(fmt.Sprintf("upgrading %s in namespace %s", name, namespace))
+	}
+
+	applyOpts := runtime.ApplyOptions(applyArgs.force, rootArgs.timeout)
+	applyOpts.WaitInterval = applyArgs.waitInterval
+
+	waitOptions := ssa.WaitOptions{
+		Interval: applyOpts.WaitInterval,
+		Timeout:  rootArgs.timeout,
+		FailFast: true,
+	}
+
+	applied := ssa.NewChangeSet()
+	for _, set := range plan.ApplySets {
+		if len(plan.ApplySets) > 1 {
+			log.Info(fmt.Sprintf("applying %s", set.Name))
+		}
+		if err := applyObjects(logr.NewContext(ctx, log), rm, set.Objects, nil, applyOpts, waitOptions, nil, applied); err != nil {
+			return err
+		}
+	}
+
+	if err := sm.Apply(ctx, &plan.Instance, true); err != nil {
+		return fmt.Errorf("storing instance failed: %w", err)
+	}
+
+	var staleObjects []*unstructured.Unstructured
+	for _, change := range plan.Changes {
+		if change.Action == ssa.DeletedAction.String() {
+			staleObjects = append(staleObjects, change.object())
+		}
+	}
+
+	if len(staleObjects) > 0 {
+		deleteOpts := runtime.DeleteOptions(name, namespace)
+		changeSet, err := rm.DeleteAll(ctx, staleObjects, deleteOpts)
+		if err != nil {
+			return fmt.Errorf("pruning objects failed: %w", err)
+		}
+		deletedObjects := runtime.SelectObjectsFromSet(changeSet, ssa.DeletedAction)
+		applied.Append(changeSet.Entries)
+		for _, change := range changeSet.Entries {
+			logJoin(log, change)
+		}
+
+		if applyArgs.wait && len(deletedObjects) > 0 {
+			spin := StartSpinnerWithTimer(log, fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
+			err = rm.WaitForTermination(deletedObjects, waitOptions)
+			spin.Stop()
+			if err != nil {
+				return fmt.Errorf("waiting for termination failed: %w", err)
+			}
+
+			log.Info("all resources are ready")
+		}
+	}
+
+	if applyArgs.summary {
+		log.Info(summarizeChangeSet(applied, 0, false))
+	}
+
+	return nil
+}
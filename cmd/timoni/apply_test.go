@@ -18,6 +18,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -25,6 +27,7 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	cp "github.com/otiai10/copy"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -119,6 +122,26 @@ func TestApply(t *testing.T) {
 		g.Expect(clientCM.Data["server"]).To(ContainSubstring("tcp://example.org"))
 	})
 
+	t.Run("saves the live state as baseline", func(t *testing.T) {
+		g := NewWithT(t)
+		baselineFile := filepath.Join(t.TempDir(), "baseline.yaml")
+
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait --diff-save-baseline=%s",
+			namespace,
+			name,
+			modPath,
+			baselineFile,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		data, err := os.ReadFile(baselineFile)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring(fmt.Sprintf("name: %s-client", name)))
+		g.Expect(string(data)).To(ContainSubstring("resourceVersion"))
+		g.Expect(string(data)).ToNot(ContainSubstring("managedFields"))
+	})
+
 	t.Run("prunes resources removed from instance", func(t *testing.T) {
 		g := NewWithT(t)
 		output, err := executeCommand(fmt.Sprintf(
@@ -161,7 +184,7 @@ func TestApply(t *testing.T) {
 	t.Run("uninstalls instance", func(t *testing.T) {
 		g := NewWithT(t)
 		output, err := executeCommand(fmt.Sprintf(
-			"delete -n %s %s --wait",
+			"delete -n %s %s --wait --yes",
 			namespace,
 			name,
 		))
@@ -317,40 +340,866 @@ func TestApply_Actions(t *testing.T) {
 	})
 }
 
-func TestApply_GlobalResources(t *testing.T) {
+func TestApply_PropagateLabelsToNamespace(t *testing.T) {
 	modPath := "testdata/module"
 	name := rnd("my-instance", 5)
 	namespace := rnd("my-namespace", 5)
-	nsObj := &corev1.Namespace{
+
+	t.Run("merges labels into the existing namespace", func(t *testing.T) {
+		g := NewWithT(t)
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/team":             "platform",
+					"pod-security.kubernetes.io/enforce": "restricted",
+				},
+			},
+		}
+		err := envTestClient.Create(context.Background(), ns)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --propagate-labels-to-namespace --wait=false",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(ns), ns)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(ns.GetLabels()).To(HaveKeyWithValue("pod-security.kubernetes.io/enforce", "restricted"))
+		g.Expect(ns.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/team", "platform"))
+		g.Expect(ns.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/version", "0.0.0-devel"))
+		g.Expect(ns.GetLabels()).ToNot(HaveKey(ContainSubstring("instance.timoni.sh")))
+	})
+}
+
+func TestApply_ThreeWayDiff(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	modURL := fmt.Sprintf("oci://%s/%s", dockerRegistry, rnd("my-mod", 5))
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"mod push %s %s -v 1.0.0",
+		modPath,
+		modURL,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -v 1.0.0 -p main --wait",
+		namespace,
+		name,
+		modURL,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Introduce drift by changing the live state
+	serverCM := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("%s-ns", name),
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
 		},
 	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+	g.Expect(err).ToNot(HaveOccurred())
+	serverCM.Data["port"] = "8080"
+	err = envTestClient.Update(context.Background(), serverCM)
+	g.Expect(err).ToNot(HaveOccurred())
 
-	t.Run("creates instance with global objects", func(t *testing.T) {
+	t.Run("prints drift and change separately", func(t *testing.T) {
 		g := NewWithT(t)
-		output, err := executeCommandWithIn(fmt.Sprintf(
-			"apply -n %s %s %s -f- -p main --wait --timeout=10s",
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -v 1.0.0 -p main -f %s --three-way",
 			namespace,
 			name,
-			modPath,
-		), strings.NewReader("values: ns: enabled: true"))
+			modURL,
+			modPath+"-values/example.com.cue",
+		))
 		g.Expect(err).ToNot(HaveOccurred())
 		t.Log("\n", output)
 
-		ns := nsObj.DeepCopy()
-		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(ns), ns)
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("# drift (last-applied -> live) ConfigMap/%s/%s-server", namespace, name)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("# change (last-applied -> desired) ConfigMap/%s/%s-client", namespace, name)))
+		g.Expect(output).ToNot(ContainSubstring(fmt.Sprintf("# drift (last-applied -> live) ConfigMap/%s/%s-client", namespace, name)))
+	})
+
+	t.Run("diffs against the last applied state", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -v 1.0.0 -p main -f %s --diff-source=last-applied",
+			namespace,
+			name,
+			modURL,
+			modPath+"-values/example.com.cue",
+		))
 		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server configured (last applied -> 1.0.0)", namespace, name)))
+		g.Expect(output).To(ContainSubstring("example.com"))
+		// The drift of the live state is not reported
+		g.Expect(output).ToNot(ContainSubstring("8080"))
 	})
 
-	t.Run("uninstalls instance", func(t *testing.T) {
+	t.Run("fails for unknown diff source", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -v 1.0.0 -p main --diff-source=cluster",
+			namespace,
+			name,
+			modURL,
+		))
+		g.Expect(err).To(MatchError(ContainSubstring("unsupported diff source 'cluster'")))
+	})
+}
+
+func TestApply_ShowManagedFields(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	t.Run("hides managed fields by default", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --diff",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/example.com.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).ToNot(ContainSubstring("managedFields"))
+	})
+
+	t.Run("prints managed fields in diff", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --show-managed-fields",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/example.com.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+		g.Expect(output).To(ContainSubstring("managedFields"))
+	})
+}
+
+func TestApply_SkipUnchanged(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	serverCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
+		},
+	}
+
+	t.Run("skips unchanged objects", func(t *testing.T) {
 		g := NewWithT(t)
 		output, err := executeCommand(fmt.Sprintf(
-			"delete -n %s %s --wait=false",
+			"apply -n %s %s %s -p main --wait",
 			namespace,
 			name,
+			modPath,
 		))
 		g.Expect(err).ToNot(HaveOccurred())
 		t.Log("\n", output)
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server skipped", namespace, name)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client skipped", namespace, name)))
+		g.Expect(output).To(ContainSubstring("resources are ready"))
+	})
+
+	t.Run("reapplies missing objects", func(t *testing.T) {
+		g := NewWithT(t)
+		err := envTestClient.Delete(context.Background(), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server created", namespace, name)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client skipped", namespace, name)))
+	})
+
+	t.Run("reapplies unchanged objects with force", func(t *testing.T) {
+		g := NewWithT(t)
+		err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+		serverCM.Data["port"] = "8080"
+		err = envTestClient.Update(context.Background(), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait --force-reapply",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server configured", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(serverCM.Data["port"]).ToNot(BeEquivalentTo("8080"))
+	})
+}
+
+func TestApply_PruneSelector(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	clientCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-client", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(clientCM), clientCM)
+	g.Expect(err).ToNot(HaveOccurred())
+	clientCM.Labels["tier"] = "frontend"
+	err = envTestClient.Update(context.Background(), clientCM)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	t.Run("skips stale objects not matching the selector", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f testdata/module-values/server-only.cue --prune-selector tier=backend",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client skipped", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(clientCM), clientCM)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("prunes stale objects matching the selector", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f testdata/module-values/server-only.cue --prune-selector tier=frontend",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client deleted", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(clientCM), clientCM)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("fails for invalid selector", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --prune-selector 'tier in frontend'",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("invalid prune selector"))
+	})
+}
+
+func TestApply_PruneDisabled(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	clientCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-client", name),
+			Namespace: namespace,
+		},
+	}
+
+	t.Run("skips stale objects on dry run", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --dry-run --prune=false",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/server-only.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client skipped (prune disabled)", namespace, name)))
+		g.Expect(output).ToNot(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client deleted", namespace, name)))
+	})
+
+	t.Run("keeps stale objects in the inventory", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --wait --prune=false",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/server-only.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(clientCM), clientCM)
+		g.Expect(err).ToNot(HaveOccurred())
 	})
+
+	t.Run("prunes the kept objects when enabled", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --wait",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/server-only.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client deleted", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(clientCM), clientCM)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
+
+func TestApply_VerboseApply(t *testing.T) {
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	t.Run("logs the created objects", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait --verbose-apply",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server server created resourceVersion=", namespace, name)))
+	})
+
+	t.Run("logs the updated and unchanged objects", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --wait --verbose-apply --force-reapply",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/client-only.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		clientCM := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-client", name),
+				Namespace: namespace,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(clientCM), clientCM)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client server unchanged resourceVersion=%s",
+			namespace, name, clientCM.GetResourceVersion())))
+	})
+}
+
+func TestApply_FromVersion(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	modURL := fmt.Sprintf("oci://%s/%s", dockerRegistry, rnd("my-mod", 5))
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"mod push %s %s -v 1.0.0",
+		modPath,
+		modURL,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Change the default domain in the next module version
+	modV2 := filepath.Join(t.TempDir(), "module")
+	g.Expect(cp.Copy(modPath, modV2)).To(Succeed())
+	configFile := filepath.Join(modV2, "templates", "config.cue")
+	config, err := os.ReadFile(configFile)
+	g.Expect(err).ToNot(HaveOccurred())
+	config = []byte(strings.Replace(string(config), `*"example.internal"`, `*"example.org"`, 1))
+	g.Expect(os.WriteFile(configFile, config, os.ModePerm)).To(Succeed())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"mod push %s %s -v 2.0.0",
+		modV2,
+		modURL,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	t.Run("prints the diff between versions", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -v 2.0.0 -p main --from-version=1.0.0",
+			namespace,
+			name,
+			modURL,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server configured (1.0.0 -> 2.0.0)", namespace, name)))
+		g.Expect(output).To(ContainSubstring("example.org"))
+
+		// The dry run doesn't create the instance
+		_, err = executeCommand(fmt.Sprintf("status -n %s %s", namespace, name))
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("fails for local modules", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --from-version=1.0.0",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("requires a module pulled from a container registry"))
+	})
+}
+
+func TestApply_DiffOnly(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	t.Run("reports only the deleted objects", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --diff-only=deleted",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/server-only.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client deleted", namespace, name)))
+		g.Expect(output).ToNot(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server", namespace, name)))
+	})
+}
+
+func TestApply_DiffFilter(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommandWithIn(fmt.Sprintf(
+		"apply -n %s %s %s -f- -p main --wait",
+		namespace,
+		name,
+		modPath,
+	), strings.NewReader("values: ns: enabled: true"))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommandWithIn(fmt.Sprintf(
+		"apply -n %s %s %s -f- -p main --diff-kind configmap --diff-name %s-server",
+		namespace,
+		name,
+		modPath,
+		name,
+	), strings.NewReader("values: {ns: enabled: true, client: enabled: false}"))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server", namespace, name)))
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("Namespace/%s-ns skipped (diff filter)", name)))
+	g.Expect(output).ToNot(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client deleted", namespace, name)))
+}
+
+func TestApply_DiffExitCode(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	t.Run("exits with zero when in sync", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --dry-run --diff-exit-code",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("exits with code 2 on drift", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main -f %s --dry-run --diff-exit-code",
+			namespace,
+			name,
+			modPath,
+			modPath+"-values/server-only.cue",
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("drift detected, 1 resource(s) would be changed"))
+
+		var exitErr *exitCodeError
+		g.Expect(errors.As(err, &exitErr)).To(BeTrue())
+		g.Expect(exitErr.code).To(Equal(2))
+	})
+}
+
+func TestApply_DriftReport(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	serverCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
+		},
+	}
+	g.Expect(envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)).To(Succeed())
+	serverCM.Data["hostname"] = "drifted.internal"
+	g.Expect(envTestClient.Update(context.Background(), serverCM)).To(Succeed())
+
+	clientCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-client", name),
+			Namespace: namespace,
+		},
+	}
+	g.Expect(envTestClient.Delete(context.Background(), clientCM)).To(Succeed())
+
+	reportFile := filepath.Join(t.TempDir(), "drift.json")
+	output, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --drift-report %s",
+		namespace,
+		name,
+		modPath,
+		reportFile,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring("drift report written to " + reportFile))
+
+	data, err := os.ReadFile(reportFile)
+	g.Expect(err).ToNot(HaveOccurred())
+	var report driftReport
+	g.Expect(json.Unmarshal(data, &report)).To(Succeed())
+	g.Expect(report.Name).To(Equal(name))
+	g.Expect(report.Namespace).To(Equal(namespace))
+	g.Expect(report.Drifted).To(BeTrue())
+	g.Expect(report.DriftedObjects).To(Equal([]string{fmt.Sprintf("ConfigMap/%s/%s-server", namespace, name)}))
+	g.Expect(report.MissingObjects).To(Equal([]string{fmt.Sprintf("ConfigMap/%s/%s-client", namespace, name)}))
+	g.Expect(report.StaleObjects).To(BeEmpty())
+
+	// The drift report performs a dry run.
+	g.Expect(envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)).To(Succeed())
+	g.Expect(serverCM.Data["hostname"]).To(Equal("drifted.internal"))
+}
+
+func TestApply_DiffTmpDir(t *testing.T) {
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	t.Run("fails fast for a missing directory", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --dry-run --diff-tmp-dir %s",
+			namespace,
+			name,
+			modPath,
+			filepath.Join(t.TempDir(), "missing"),
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("is not writable, use --diff-tmp-dir"))
+	})
+
+	t.Run("removes the temporary files", func(t *testing.T) {
+		g := NewWithT(t)
+		tmpDir := t.TempDir()
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --diff --diff-tmp-dir %s",
+			namespace,
+			name,
+			modPath,
+			tmpDir,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		entries, err := os.ReadDir(tmpDir)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(entries).To(BeEmpty())
+	})
+
+	t.Run("keeps the diff files in the directory", func(t *testing.T) {
+		g := NewWithT(t)
+		tmpDir := t.TempDir()
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --keep-diff-files --diff-tmp-dir %s",
+			namespace,
+			name,
+			modPath,
+			tmpDir,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		entries, err := os.ReadDir(tmpDir)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(entries).To(HaveLen(1))
+		g.Expect(entries[0].Name()).To(HavePrefix(apiv1.FieldManager + "-diff-"))
+	})
+}
+
+func TestApply_TimeoutJitter(t *testing.T) {
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	t.Run("delays the start", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --timeout-jitter=100ms",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("delaying start by"))
+	})
+
+	t.Run("fails with negative jitter", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --timeout-jitter=-1s",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("timeout jitter must not be negative"))
+	})
+}
+
+func TestApply_AtomicNamespace(t *testing.T) {
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	t.Run("rolls back the failed namespace", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --atomic-namespace --wait --wait-condition=ConfigMap:Ready=True:2s",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("rolled back in 1 namespace(s)"))
+		t.Log("\n", output)
+
+		clientCM := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-client", name),
+				Namespace: namespace,
+			},
+		}
+		g.Eventually(func() bool {
+			err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(clientCM), clientCM)
+			return apierrors.IsNotFound(err)
+		}, "10s").Should(BeTrue())
+	})
+
+	t.Run("records only the applied namespaces in the inventory", func(t *testing.T) {
+		g := NewWithT(t)
+		storage := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("timoni.%s", name),
+				Namespace: namespace,
+			},
+		}
+		err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		var inst apiv1.Instance
+		g.Expect(json.Unmarshal(storage.Data["instance"], &inst)).To(Succeed())
+		g.Expect(inst.Inventory.Entries).To(BeEmpty())
+	})
+}
+
+func TestApply_GlobalResources(t *testing.T) {
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+	nsObj := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-ns", name),
+		},
+	}
+
+	t.Run("creates instance with global objects", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommandWithIn(fmt.Sprintf(
+			"apply -n %s %s %s -f- -p main --wait --timeout=10s",
+			namespace,
+			name,
+			modPath,
+		), strings.NewReader("values: ns: enabled: true"))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		ns := nsObj.DeepCopy()
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(ns), ns)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("uninstalls instance", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --wait=false --yes",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+	})
+}
+
+func TestApply_InventoryAnnotations(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait=false --inventory-annotation=git.commit=abc",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait=false",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "timoni." + name,
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(storage.GetAnnotations()).To(HaveKeyWithValue("git.commit", "abc"))
+
+	output, err := executeCommand(fmt.Sprintf("inspect annotations -n %s %s", namespace, name))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring("git.commit: abc"))
+}
+
+func Test_parseInventoryAnnotations(t *testing.T) {
+	g := NewWithT(t)
+
+	annotations, err := parseInventoryAnnotations([]string{"git.commit=abc", "ci.example.com/url=https://ci/1?a=b"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(annotations).To(Equal(map[string]string{
+		"git.commit":         "abc",
+		"ci.example.com/url": "https://ci/1?a=b",
+	}))
+
+	_, err = parseInventoryAnnotations([]string{"commit"})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("must be in the format key=value"))
+
+	_, err = parseInventoryAnnotations([]string{"-bad=value"})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("invalid inventory annotation key"))
 }
@@ -132,7 +132,9 @@ func pullArtifactCmdRun(cmd *cobra.Command, args []string) error {
 	defer cancel()
 
 	opts := oci.Options(ctx, pullArtifactArgs.creds.String(), rootArgs.registryInsecure)
-	err := oci.PullArtifact(ociURL, pullArtifactArgs.output, pullArtifactArgs.contentType, opts)
+	err := oci.Retry(ctx, pullRetryOptions(), func() error {
+		return oci.PullArtifact(ociURL, pullArtifactArgs.output, pullArtifactArgs.contentType, opts)
+	})
 	if err != nil {
 		return err
 	}
@@ -25,6 +25,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
 	"cuelang.org/go/cue/ast"
@@ -32,6 +35,8 @@ import (
 	"cuelang.org/go/cue/format"
 	cuejson "cuelang.org/go/encoding/json"
 	cueyaml "cuelang.org/go/encoding/yaml"
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/yaml"
@@ -39,6 +44,7 @@ import (
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 	"github.com/stefanprodan/timoni/internal/engine"
 	"github.com/stefanprodan/timoni/internal/flags"
+	"github.com/stefanprodan/timoni/internal/runtime"
 )
 
 var buildCmd = &cobra.Command{
@@ -52,6 +58,37 @@ var buildCmd = &cobra.Command{
   timoni build app ./path/to/module \
   --values ./values-1.cue \
   --values ./values-2.cue
+
+  # Build an instance and fail if any of the values files has no effect
+  timoni build app ./path/to/module \
+  --values ./values-1.cue \
+  --values ./values-2.cue \
+  --validate-overlays=fail
+
+  # Build an instance and print the objects as a JSON array
+  timoni build app ./path/to/module --output json-array
+
+  # Build an instance and print one JSON object per line
+  timoni build app ./path/to/module --output jsonl | jq -r .metadata.name
+
+  # Build an instance and preserve the CUE field comments in the YAML output
+  timoni build app ./path/to/module --comments
+
+  # Build an instance by injecting values into the CUE @tag attributes
+  timoni build app ./path/to/module --tag env=staging --tag region=eu-west-1
+
+  # Build an instance by substituting the ${VAR} placeholders in the YAML values
+  # with environment variables, failing if any of the variables is undefined
+  DOMAIN=example.com timoni build app ./path/to/module \
+  --values ./values.yaml \
+  --expand-env-strict
+
+  # Build an instance and print the objects in the order declared by the module
+  timoni build app ./path/to/module --sort=none
+
+  # Build an instance and fail if any of the Pod specs
+  # violates the restricted Pod Security Standard
+  timoni build app ./path/to/module --psa=restricted
 `,
 	RunE: runBuildCmd,
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -67,13 +104,23 @@ var buildCmd = &cobra.Command{
 }
 
 type buildFlags struct {
-	name        string
-	module      string
-	version     flags.Version
-	pkg         flags.Package
-	valuesFiles []string
-	output      string
-	creds       flags.Credentials
+	name             string
+	module           string
+	version          flags.Version
+	pkg              flags.Package
+	tags             flags.Tags
+	valuesFiles      []string
+	validateOverlays string
+	expandEnv        bool
+	expandEnvStrict  bool
+	expandEnvCue     bool
+	output           string
+	comments         bool
+	namePrefix       string
+	psa              string
+	failOnEmpty      bool
+	sort             string
+	creds            flags.Credentials
 }
 
 var buildArgs buildFlags
@@ -81,10 +128,31 @@ var buildArgs buildFlags
 func init() {
 	buildCmd.Flags().VarP(&buildArgs.version, buildArgs.version.Type(), buildArgs.version.Shorthand(), buildArgs.version.Description())
 	buildCmd.Flags().VarP(&buildArgs.pkg, buildArgs.pkg.Type(), buildArgs.pkg.Shorthand(), buildArgs.pkg.Description())
+	buildCmd.Flags().Var(&buildArgs.tags, buildArgs.tags.Type(), buildArgs.tags.Description())
 	buildCmd.Flags().StringSliceVarP(&buildArgs.valuesFiles, "values", "f", nil,
 		"The local path to values files (cue, yaml or json format).")
+	buildCmd.Flags().StringVar(&buildArgs.validateOverlays, "validate-overlays", "",
+		"Report the values files that have no effect on the merged values, can be 'warn' or 'fail'.")
+	buildCmd.Flags().Lookup("validate-overlays").NoOptDefVal = validateOverlaysWarn
+	buildCmd.Flags().BoolVar(&buildArgs.expandEnv, "expand-env", false,
+		"Substitute the ${VAR} placeholders in the YAML and JSON values files with environment variables, undefined variables are replaced with empty strings.")
+	buildCmd.Flags().BoolVar(&buildArgs.expandEnvStrict, "expand-env-strict", false,
+		"Substitute the ${VAR} placeholders in the values files with environment variables and fail if any of the variables is undefined.")
+	buildCmd.Flags().BoolVar(&buildArgs.expandEnvCue, "expand-env-cue", false,
+		"Substitute the environment variables in the CUE values files too, requires --expand-env or --expand-env-strict.")
 	buildCmd.Flags().StringVarP(&buildArgs.output, "output", "o", "yaml",
-		"The format in which the Kubernetes objects should be printed, can be 'yaml' or 'json'.")
+		"The format in which the Kubernetes objects should be printed, can be 'yaml', 'json', 'json-array' or 'jsonl'.")
+	buildCmd.Flags().BoolVar(&buildArgs.comments, "comments", false,
+		"Preserve the CUE field comments as YAML comments in the output.")
+	buildCmd.Flags().StringVar(&buildArgs.namePrefix, "name-prefix", "",
+		"Prefix the names of the generated resources, their references and their app.kubernetes.io/name and instance labels and selectors, when set without a value the instance name is used as prefix.")
+	buildCmd.Flags().Lookup("name-prefix").NoOptDefVal = namePrefixInstance
+	buildCmd.Flags().StringVar(&buildArgs.psa, "psa", "",
+		"Check the Pod specs against the Pod Security Standard level and fail on violations, can be 'privileged', 'baseline' or 'restricted'.")
+	buildCmd.Flags().BoolVar(&buildArgs.failOnEmpty, "fail-on-empty", false,
+		"Fail the build if the module renders no Kubernetes objects.")
+	buildCmd.Flags().StringVar(&buildArgs.sort, "sort", buildSortSSA,
+		"The order in which the Kubernetes objects are printed, can be 'ssa' for the apply order, 'kind' for alphabetical by kind, or 'none' for the order declared by the module.")
 	buildCmd.Flags().Var(&buildArgs.creds, buildArgs.creds.Type(), buildArgs.creds.Description())
 
 	rootCmd.AddCommand(buildCmd)
@@ -95,9 +163,23 @@ func runBuildCmd(cmd *cobra.Command, args []string) error {
 		return errors.New("name and module are required")
 	}
 
-	buildArgs.name = args[0]
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	buildArgs.name = name
 	buildArgs.module = args[1]
 
+	if buildArgs.psa != "" {
+		if err := runtime.ValidatePodSecurityLevel(buildArgs.psa); err != nil {
+			return err
+		}
+	}
+
+	if err := sortObjects(nil, buildArgs.sort); err != nil {
+		return err
+	}
+
 	version := buildArgs.version.String()
 	if version == "" {
 		version = apiv1.LatestVersion
@@ -123,6 +205,7 @@ func runBuildCmd(cmd *cobra.Command, args []string) error {
 		buildArgs.creds.String(),
 		rootArgs.registryInsecure,
 	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
 	mod, err := fetcher.Fetch()
 	if err != nil {
 		return err
@@ -146,7 +229,15 @@ func runBuildCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	if len(buildArgs.valuesFiles) > 0 {
-		valuesCue, err := convertToCue(cmd, buildArgs.valuesFiles)
+		valuesCue, err := convertToCueWithEnv(cmd, buildArgs.valuesFiles, valuesEnvExpansion{
+			enabled: buildArgs.expandEnv || buildArgs.expandEnvStrict,
+			strict:  buildArgs.expandEnvStrict,
+			cue:     buildArgs.expandEnvCue,
+		})
+		if err != nil {
+			return err
+		}
+		err = validateValuesOverlays(LoggerFrom(cmd.Context()), builder, buildArgs.valuesFiles, valuesCue, buildArgs.validateOverlays)
 		if err != nil {
 			return err
 		}
@@ -156,7 +247,7 @@ func runBuildCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	buildResult, err := builder.Build()
+	buildResult, err := builder.Build(buildArgs.tags...)
 	if err != nil {
 		return describeErr(fetcher.GetModuleRoot(), "build failed", err)
 	}
@@ -180,11 +271,49 @@ func runBuildCmd(cmd *cobra.Command, args []string) error {
 		objects = append(objects, set.Objects...)
 	}
 
+	if buildArgs.failOnEmpty && len(objects) == 0 {
+		return fmt.Errorf("build failed, the module rendered no objects")
+	}
+
+	if err := sortObjects(objects, buildArgs.sort); err != nil {
+		return err
+	}
+
+	if buildArgs.psa != "" {
+		if err := checkPodSecurity(LoggerFrom(cmd.Context()), objects, buildArgs.psa); err != nil {
+			return err
+		}
+	}
+
+	comments := make(map[string]engine.FieldComments)
+	if buildArgs.comments {
+		comments, err = builder.GetApplySetsComments(buildResult)
+		if err != nil {
+			return fmt.Errorf("failed to extract comments: %w", err)
+		}
+	}
+
+	if buildArgs.namePrefix != "" {
+		keys := make([]string, len(objects))
+		for i, obj := range objects {
+			keys[i] = ssa.FmtUnstructured(obj)
+		}
+		runtime.PrefixNames(objects, namePrefix(buildArgs.namePrefix, buildArgs.name))
+		prefixedComments := make(map[string]engine.FieldComments, len(comments))
+		for i, obj := range objects {
+			prefixedComments[ssa.FmtUnstructured(obj)] = comments[keys[i]]
+		}
+		comments = prefixedComments
+	}
+
 	switch buildArgs.output {
 	case "yaml":
 		var sb strings.Builder
 		for _, obj := range objects {
 			data, err := yaml.Marshal(obj)
+			if buildArgs.comments {
+				data, err = engine.EncodeYAMLWithComments(obj, comments[ssa.FmtUnstructured(obj)])
+			}
 			if err != nil {
 				return fmt.Errorf("converting objects failed: %w", err)
 			}
@@ -210,12 +339,144 @@ func runBuildCmd(cmd *cobra.Command, args []string) error {
 		}
 		_, err = cmd.OutOrStdout().Write(b)
 		return err
+	case "json-array":
+		// An empty result is printed as [] instead of null.
+		if objects == nil {
+			objects = []*unstructured.Unstructured{}
+		}
+		b, err := json.MarshalIndent(objects, "", "    ")
+		if err != nil {
+			return fmt.Errorf("converting objects failed: %w", err)
+		}
+		_, err = cmd.OutOrStdout().Write(append(b, '\n'))
+		return err
+	case "jsonl":
+		var sb strings.Builder
+		for _, obj := range objects {
+			data, err := json.Marshal(obj)
+			if err != nil {
+				return fmt.Errorf("converting objects failed: %w", err)
+			}
+			sb.Write(data)
+			sb.WriteString("\n")
+		}
+		_, err = cmd.OutOrStdout().Write([]byte(sb.String()))
+		return err
+	default:
+		return fmt.Errorf("unknown --output=%s, can be yaml, json, json-array or jsonl", buildArgs.output)
+	}
+}
+
+const (
+	buildSortSSA  = "ssa"
+	buildSortKind = "kind"
+	buildSortNone = "none"
+)
+
+// sortObjects sorts the objects in place according to the given mode.
+// The 'ssa' mode sorts the objects in the order they are applied on the cluster,
+// the 'kind' mode sorts them alphabetically by kind, namespace and name,
+// and the 'none' mode keeps the order declared by the module.
+func sortObjects(objects []*unstructured.Unstructured, mode string) error {
+	switch mode {
+	case buildSortSSA:
+		sort.Stable(ssa.SortableUnstructureds(objects))
+	case buildSortKind:
+		sort.SliceStable(objects, func(i, j int) bool {
+			if objects[i].GetKind() != objects[j].GetKind() {
+				return objects[i].GetKind() < objects[j].GetKind()
+			}
+			if objects[i].GetNamespace() != objects[j].GetNamespace() {
+				return objects[i].GetNamespace() < objects[j].GetNamespace()
+			}
+			return objects[i].GetName() < objects[j].GetName()
+		})
+	case buildSortNone:
 	default:
-		return fmt.Errorf("unknown --output=%s, can be yaml or json", buildArgs.output)
+		return fmt.Errorf("unsupported sort mode '%s', can be '%s', '%s' or '%s'",
+			mode, buildSortSSA, buildSortKind, buildSortNone)
+	}
+	return nil
+}
+
+const (
+	validateOverlaysWarn = "warn"
+	validateOverlaysFail = "fail"
+)
+
+// validateValuesOverlays reports the values files that have no effect on the
+// merged values. In warn mode the findings are logged, in fail mode an error is returned.
+func validateValuesOverlays(log logr.Logger, builder *engine.ModuleBuilder, paths []string, overlays [][]byte, mode string) error {
+	switch mode {
+	case "":
+		return nil
+	case validateOverlaysWarn, validateOverlaysFail:
+	default:
+		return fmt.Errorf("unsupported overlays validation mode '%s', can be '%s' or '%s'",
+			mode, validateOverlaysWarn, validateOverlaysFail)
+	}
+
+	ineffective, err := builder.IneffectiveValuesFiles(overlays)
+	if err != nil {
+		return fmt.Errorf("validating values overlays failed: %w", err)
+	}
+
+	var sources []string
+	for _, i := range ineffective {
+		source := paths[i]
+		if source == "-" {
+			source = "stdin"
+		}
+		sources = append(sources, source)
+		if mode == validateOverlaysWarn {
+			log.Info(fmt.Sprintf("%s %s", colorizeSubject(source),
+				colorizeWarning("has no effect on the merged values")))
+		}
+	}
+
+	if mode == validateOverlaysFail && len(sources) > 0 {
+		return fmt.Errorf("values files with no effect on the merged values: %s", strings.Join(sources, ", "))
 	}
+
+	return nil
 }
 
 func convertToCue(cmd *cobra.Command, paths []string) ([][]byte, error) {
+	return convertToCueWithEnv(cmd, paths, valuesEnvExpansion{})
+}
+
+// valuesEnvExpansion configures the substitution of environment variables
+// in the raw content of the values files, before parsing.
+type valuesEnvExpansion struct {
+	// enabled turns on the substitution for the YAML and JSON values files.
+	enabled bool
+	// strict fails the substitution if a variable is undefined.
+	strict bool
+	// cue turns on the substitution for the CUE values files and stdin.
+	cue bool
+}
+
+var envPlaceholderRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} placeholders with the values of the
+// environment variables, in strict mode it errors on undefined variables.
+func expandEnv(data []byte, strict bool) ([]byte, error) {
+	var undefined []string
+	result := envPlaceholderRegexp.ReplaceAllFunc(data, func(match []byte) []byte {
+		key := string(envPlaceholderRegexp.FindSubmatch(match)[1])
+		value, ok := os.LookupEnv(key)
+		if !ok && !slices.Contains(undefined, key) {
+			undefined = append(undefined, key)
+		}
+		return []byte(value)
+	})
+	if strict && len(undefined) > 0 {
+		return nil, fmt.Errorf("undefined environment variables: %s", strings.Join(undefined, ", "))
+	}
+	return result, nil
+}
+
+func convertToCueWithEnv(cmd *cobra.Command, paths []string, env valuesEnvExpansion) ([][]byte, error) {
 	valuesCue := make([][]byte, len(paths))
 	for i, path := range paths {
 		var (
@@ -239,6 +500,13 @@ func convertToCue(cmd *cobra.Command, paths []string) ([][]byte, error) {
 			return nil, fmt.Errorf("could not read values file at %s: %w", path, err)
 		}
 
+		if env.enabled && (ext != ".cue" || env.cue) {
+			bs, err = expandEnv(bs, env.strict)
+			if err != nil {
+				return nil, fmt.Errorf("could not expand the values file at %s: %w", path, err)
+			}
+		}
+
 		var node ast.Node
 
 		switch ext {
@@ -267,3 +535,25 @@ func convertToCue(cmd *cobra.Command, paths []string) ([][]byte, error) {
 	}
 	return valuesCue, nil
 }
+
+// checkPodSecurity logs the Pod Security Standard violations of the
+// given objects and returns an error if any violations are found.
+func checkPodSecurity(log logr.Logger, objects []*unstructured.Unstructured, level string) error {
+	var count int
+	for _, obj := range objects {
+		violations, err := runtime.CheckPodSecurity(obj, level)
+		if err != nil {
+			return err
+		}
+		for _, v := range violations {
+			log.Info(fmt.Sprintf("%s %s %s", colorizeSubject(v.Object),
+				colorizeWarning(v.Rule), v.Message))
+			count++
+		}
+	}
+
+	if count > 0 {
+		return fmt.Errorf("found %d violation(s) of the %s Pod Security Standard", count, level)
+	}
+	return nil
+}
@@ -17,12 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/fluxcd/pkg/ssa"
 	. "github.com/onsi/gomega"
+	cp "github.com/otiai10/copy"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -72,6 +76,84 @@ func TestBuild(t *testing.T) {
 		g.Expect(len(objects)).To(BeEquivalentTo(2))
 	})
 
+	t.Run("builds module and outputs JSON array", func(t *testing.T) {
+		g := NewWithT(t)
+		name := rnd("my-instance", 5)
+		namespace := rnd("my-namespace", 5)
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n %s %s %s -p main -o json-array",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		var objects []*unstructured.Unstructured
+		err = json.Unmarshal([]byte(output), &objects)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(len(objects)).To(BeEquivalentTo(2))
+		for _, o := range objects {
+			g.Expect(o.GetNamespace()).To(BeEquivalentTo(namespace))
+		}
+	})
+
+	t.Run("builds module and outputs JSON lines", func(t *testing.T) {
+		g := NewWithT(t)
+		name := rnd("my-instance", 5)
+		namespace := rnd("my-namespace", 5)
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n %s %s %s -p main -o jsonl",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		g.Expect(len(lines)).To(BeEquivalentTo(2))
+		for _, line := range lines {
+			o := &unstructured.Unstructured{}
+			err = json.Unmarshal([]byte(line), o)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(o.GetKind()).To(BeEquivalentTo("ConfigMap"))
+		}
+	})
+
+	t.Run("builds module with name prefix", func(t *testing.T) {
+		g := NewWithT(t)
+		name := rnd("my-instance", 5)
+		namespace := rnd("my-namespace", 5)
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n %s %s %s -p main -o yaml --name-prefix=canary",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		objects, err := ssa.ReadObjects(strings.NewReader(output))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(len(objects)).To(BeEquivalentTo(2))
+		for _, o := range objects {
+			g.Expect(o.GetName()).To(HavePrefix("canary-" + name))
+		}
+
+		output, err = executeCommand(fmt.Sprintf(
+			"build -n %s %s %s -p main -o yaml --name-prefix",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// The names already starting with the instance name are not prefixed twice
+		objects, err = ssa.ReadObjects(strings.NewReader(output))
+		g.Expect(err).ToNot(HaveOccurred())
+		for _, o := range objects {
+			g.Expect(o.GetName()).ToNot(HavePrefix(name + "-" + name))
+		}
+	})
+
 	t.Run("builds module with custom values", func(t *testing.T) {
 		g := NewWithT(t)
 		name := rnd("my-instance", 5)
@@ -229,3 +311,294 @@ func TestBuild(t *testing.T) {
 		g.Expect(err.Error()).To(ContainSubstring("timoni.kubeMinorVersion: invalid value"))
 	})
 }
+
+func TestBuildWithTags(t *testing.T) {
+	g := NewWithT(t)
+	modPath := filepath.Join(t.TempDir(), "module")
+	g.Expect(cp.Copy("testdata/module", modPath)).To(Succeed())
+
+	tagValues := `package main
+
+values: domain: string @tag(domain)
+`
+	g.Expect(os.WriteFile(filepath.Join(modPath, "tag_values.cue"), []byte(tagValues), 0644)).To(Succeed())
+
+	t.Run("builds module with injected tag", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --tag domain=example.tag",
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("tcp://example.tag:9090"))
+	})
+
+	t.Run("fails with reserved tag", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --tag namespace=test",
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("the 'namespace' tag is reserved"))
+	})
+
+	t.Run("fails with invalid tag", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --tag 1domain=example.tag",
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("invalid tag"))
+	})
+
+	t.Run("fails with unknown tag", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --tag domain=example.tag --tag unknown=test",
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring(`no tag for "unknown"`))
+	})
+}
+
+func TestBuildValidateOverlays(t *testing.T) {
+	modPath := "testdata/module"
+	valuesPath := "testdata/module-values"
+
+	t.Run("warns about overridden values files", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml -f %s -f %s --validate-overlays",
+			modPath,
+			valuesPath+"/example.io.cue",
+			valuesPath+"/example.com.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("example.io.cue has no effect on the merged values"))
+		g.Expect(output).ToNot(ContainSubstring("example.com.cue has no effect"))
+	})
+
+	t.Run("fails on overridden values files", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml -f %s -f %s --validate-overlays=fail",
+			modPath,
+			valuesPath+"/example.io.cue",
+			valuesPath+"/example.com.cue",
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring(valuesPath + "/example.io.cue"))
+	})
+
+	t.Run("passes with effective values files", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml -f %s -f %s --validate-overlays=fail",
+			modPath,
+			valuesPath+"/example.com.cue",
+			valuesPath+"/example.io.cue",
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
+func TestBuildExpandEnv(t *testing.T) {
+	modPath := "testdata/module"
+	tmpDir := t.TempDir()
+
+	yamlValues := filepath.Join(tmpDir, "values.yaml")
+	err := os.WriteFile(yamlValues, []byte("values:\n  domain: \"${TIMONI_TEST_DOMAIN}\"\n"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cueValues := filepath.Join(tmpDir, "values.cue")
+	err = os.WriteFile(cueValues, []byte("values: domain: \"${TIMONI_TEST_DOMAIN}\"\n"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("substitutes env vars in YAML values", func(t *testing.T) {
+		g := NewWithT(t)
+		t.Setenv("TIMONI_TEST_DOMAIN", "env.example.com")
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml -f %s --expand-env",
+			modPath,
+			yamlValues,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("hostname: env.example.com"))
+	})
+
+	t.Run("fails on undefined env vars in strict mode", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml -f %s --expand-env-strict",
+			modPath,
+			yamlValues,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("undefined environment variables: TIMONI_TEST_DOMAIN"))
+	})
+
+	t.Run("skips CUE values unless enabled", func(t *testing.T) {
+		g := NewWithT(t)
+		t.Setenv("TIMONI_TEST_DOMAIN", "env.example.com")
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml -f %s --expand-env",
+			modPath,
+			cueValues,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("hostname: ${TIMONI_TEST_DOMAIN}"))
+
+		output, err = executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml -f %s --expand-env --expand-env-cue",
+			modPath,
+			cueValues,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("hostname: env.example.com"))
+	})
+}
+
+func TestBuildPodSecurity(t *testing.T) {
+	modPath := "testdata/module"
+
+	t.Run("passes objects without Pod specs", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --psa=restricted",
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("kind: ConfigMap"))
+	})
+
+	t.Run("fails for unknown level", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --psa=strict",
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unsupported Pod Security Standard level 'strict'"))
+	})
+}
+
+func TestBuildFailOnEmpty(t *testing.T) {
+	g := NewWithT(t)
+	emptyModPath := filepath.Join(t.TempDir(), "module")
+	g.Expect(cp.Copy("testdata/module", emptyModPath)).To(Succeed())
+
+	timoniFile := filepath.Join(emptyModPath, "timoni.cue")
+	data, err := os.ReadFile(timoniFile)
+	g.Expect(err).ToNot(HaveOccurred())
+	data = []byte(strings.Replace(string(data), "apply: all: [for obj in instance.objects {obj}]", "apply: all: []", 1))
+	g.Expect(os.WriteFile(timoniFile, data, 0644)).To(Succeed())
+
+	t.Run("succeeds for empty build by default", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml",
+			emptyModPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("prints an empty JSON array for empty build", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o json-array",
+			emptyModPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(Equal("[]\n"))
+	})
+
+	t.Run("fails for empty build", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --fail-on-empty",
+			emptyModPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("rendered no objects"))
+	})
+
+	t.Run("succeeds for non-empty build", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(
+			"build -n default test testdata/module -p main -o yaml --fail-on-empty",
+		)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("kind: ConfigMap"))
+	})
+}
+
+func TestBuildSort(t *testing.T) {
+	newObject := func(kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	names := func(objects []*unstructured.Unstructured) []string {
+		var result []string
+		for _, obj := range objects {
+			result = append(result, obj.GetKind()+"/"+obj.GetName())
+		}
+		return result
+	}
+
+	tests := []struct {
+		mode     string
+		expected []string
+	}{
+		{
+			mode:     buildSortNone,
+			expected: []string{"Service/app", "ServiceAccount/app", "ConfigMap/b", "ConfigMap/a", "Namespace/default"},
+		},
+		{
+			mode:     buildSortSSA,
+			expected: []string{"Namespace/default", "ServiceAccount/app", "ConfigMap/a", "ConfigMap/b", "Service/app"},
+		},
+		{
+			mode:     buildSortKind,
+			expected: []string{"ConfigMap/a", "ConfigMap/b", "Namespace/default", "Service/app", "ServiceAccount/app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			g := NewWithT(t)
+			objects := []*unstructured.Unstructured{
+				newObject("Service", "app"),
+				newObject("ServiceAccount", "app"),
+				newObject("ConfigMap", "b"),
+				newObject("ConfigMap", "a"),
+				newObject("Namespace", "default"),
+			}
+
+			err := sortObjects(objects, tt.mode)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(names(objects)).To(Equal(tt.expected))
+		})
+	}
+
+	t.Run("fails for unknown mode", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"build -n default test %s -p main -o yaml --sort=name",
+			"testdata/module",
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unsupported sort mode 'name'"))
+	})
+}
@@ -242,6 +242,16 @@ func runBundleApplyCmd(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+// bundleInstanceDiffHeader returns the header printed before the diff
+// of each bundle instance, so that the output can be grouped by instance.
+func bundleInstanceDiffHeader(instance *engine.BundleInstance) string {
+	header := fmt.Sprintf("# bundle: %s instance: %s namespace: %s", instance.Bundle, instance.Name, instance.Namespace)
+	if instance.Cluster != "" && instance.Cluster != apiv1.RuntimeDefaultName {
+		header = fmt.Sprintf("%s cluster: %s", header, instance.Cluster)
+	}
+	return header
+}
+
 func fetchBundleInstanceModule(ctx context.Context, instance *engine.BundleInstance, rootDir string) error {
 	modDir := path.Join(rootDir, instance.Name)
 	if err := os.MkdirAll(modDir, os.ModePerm); err != nil {
@@ -262,6 +272,7 @@ func fetchBundleInstanceModule(ctx context.Context, instance *engine.BundleInsta
 		bundleApplyArgs.creds.String(),
 		rootArgs.registryInsecure,
 	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
 	mod, err := fetcher.Fetch()
 	if err != nil {
 		return err
@@ -346,6 +357,9 @@ func applyBundleInstance(ctx context.Context, cuectx *cue.Context, instance *eng
 	}
 
 	im := runtime.NewInstanceManager(instance.Name, instance.Namespace, finalValues, instance.Module)
+	if kubeContext, err := runtime.KubeContext(kubeconfigArgs); err == nil {
+		im.Instance.ClusterContext = kubeContext
+	}
 
 	if im.Instance.Labels == nil {
 		im.Instance.Labels = make(map[string]string)
@@ -363,17 +377,19 @@ func applyBundleInstance(ctx context.Context, cuectx *cue.Context, instance *eng
 
 	if bundleApplyArgs.dryrun || bundleApplyArgs.diff {
 		if !nsExists {
-			log.Info(colorizeJoin(colorizeSubject("Namespace/"+instance.Namespace),
-				ssa.CreatedAction, dryRunServer))
+			logJoin(log, colorizeSubject("Namespace/"+instance.Namespace),
+				ssa.CreatedAction, dryRunServer)
+		}
+		if bundleApplyArgs.diff {
+			fmt.Fprintln(rootCmd.OutOrStdout(), bundleInstanceDiffHeader(instance))
 		}
-		if err := instanceDryRunDiff(
+		if _, err := instanceDryRunDiff(
 			logr.NewContext(ctx, log),
 			rm,
 			objects,
 			staleObjects,
 			nsExists,
-			rootDir,
-			bundleApplyArgs.diff,
+			dryRunDiffOptions{withDiff: bundleApplyArgs.diff, tmpDir: rootDir},
 		); err != nil {
 			return err
 		}
@@ -391,7 +407,7 @@ func applyBundleInstance(ctx context.Context, cuectx *cue.Context, instance *eng
 		}
 
 		if !nsExists {
-			log.Info(colorizeJoin(colorizeSubject("Namespace/"+instance.Namespace), ssa.CreatedAction))
+			logJoin(log, colorizeSubject("Namespace/"+instance.Namespace), ssa.CreatedAction)
 		}
 	} else {
 		log.Info(fmt.Sprintf("upgrading %s in namespace %s",
@@ -417,7 +433,7 @@ func applyBundleInstance(ctx context.Context, cuectx *cue.Context, instance *eng
 			return err
 		}
 		for _, change := range cs.Entries {
-			log.Info(colorizeJoin(change))
+			logJoin(log, change)
 		}
 
 		if bundleApplyArgs.wait {
@@ -448,13 +464,13 @@ func applyBundleInstance(ctx context.Context, cuectx *cue.Context, instance *eng
 		}
 		deletedObjects = runtime.SelectObjectsFromSet(changeSet, ssa.DeletedAction)
 		for _, change := range changeSet.Entries {
-			log.Info(colorizeJoin(change))
+			logJoin(log, change)
 		}
 	}
 
 	if bundleApplyArgs.wait {
 		if len(deletedObjects) > 0 {
-			spin := StartSpinner(fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
+			spin := StartSpinnerWithTimer(log, fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
 			err = rm.WaitForTermination(deletedObjects, waitOptions)
 			spin.Stop()
 			if err != nil {
@@ -126,6 +126,19 @@ bundle: {
 		}
 	})
 
+	t.Run("prints diff grouped by instance", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"bundle apply -f %s -p main --dry-run --diff",
+			bundlePath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("# bundle: %s instance: frontend namespace: %s", bundleName, namespace)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("# bundle: %s instance: backend namespace: %s", bundleName, namespace)))
+	})
+
 	t.Run("fails to create instances from completely overlapping bundle", func(t *testing.T) {
 		anotherBundleName := "my-other-bundle"
 		anotherBundleData := fmt.Sprintf(`
@@ -172,7 +172,7 @@ func deleteBundleInstance(ctx context.Context, instance *engine.BundleInstance,
 
 	if dryrun {
 		for _, object := range objects {
-			log.Info(colorizeJoin(object, ssa.DeletedAction, dryRunClient))
+			logJoin(log, object, ssa.DeletedAction, dryRunClient)
 		}
 		return nil
 	}
@@ -188,7 +188,7 @@ func deleteBundleInstance(ctx context.Context, instance *engine.BundleInstance,
 			continue
 		}
 		cs.Add(*change)
-		log.Info(colorizeJoin(change))
+		logJoin(log, change)
 	}
 
 	if hasErrors {
@@ -203,7 +203,7 @@ func deleteBundleInstance(ctx context.Context, instance *engine.BundleInstance,
 	if wait && len(deletedObjects) > 0 {
 		waitOpts := ssa.DefaultWaitOptions()
 		waitOpts.Timeout = rootArgs.timeout
-		spin := StartSpinner(fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
+		spin := StartSpinnerWithTimer(log, fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
 		err = sm.WaitForTermination(deletedObjects, waitOpts)
 		spin.Stop()
 		if err != nil {
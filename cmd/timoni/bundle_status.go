@@ -153,7 +153,7 @@ func runBundleStatusCmd(cmd *cobra.Command, args []string) error {
 					failed = true
 					continue
 				}
-				log.Info(colorizeJoin(obj, res.Status, "-", res.Message))
+				logJoin(log, obj, res.Status, "-", res.Message)
 			}
 		}
 	}
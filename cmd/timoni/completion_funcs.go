@@ -18,16 +18,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/stefanprodan/timoni/internal/runtime"
 )
 
-// completeNamespaceList completes a Cobra argument or flag with
+// completeInstanceList completes a Cobra argument or flag with
 // a Timoni instance, based on the current context in ~/.kube/config,
 // and the current namespace set via --namespace.
+// If the argument contains a slash, the instances are listed across
+// all namespaces in the format '<namespace>/<name>'.
 func completeInstanceList(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if strings.Contains(toComplete, "/") {
+		return completeInstanceListAllNamespaces(toComplete)
+	}
+
 	instances, err := listInstancesFromFlags()
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
@@ -41,6 +48,32 @@ func completeInstanceList(cmd *cobra.Command, args []string, toComplete string)
 	return completions, cobra.ShellCompDirectiveNoFileComp
 }
 
+// completeInstanceListAllNamespaces completes a Cobra argument with
+// a Timoni instance from any namespace in the format '<namespace>/<name>'.
+func completeInstanceListAllNamespaces(toComplete string) ([]string, cobra.ShellCompDirective) {
+	rm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	instances, err := runtime.NewStorageManager(rm).List(ctx, "", "")
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var completions []string
+	for _, inst := range instances {
+		ref := fmt.Sprintf("%s/%s", inst.Namespace, inst.Name)
+		if strings.HasPrefix(ref, toComplete) {
+			completions = append(completions, ref)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
 // completeNamespaceList completes a Cobra argument or flag with
 // a Kubernetes namespace, based on the current context in ~/.kube/config
 func completeNamespaceList(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -17,14 +17,36 @@ limitations under the License.
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	goruntime "runtime"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fluxcd/pkg/ssa"
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 	"github.com/stefanprodan/timoni/internal/runtime"
 )
 
@@ -35,8 +57,59 @@ var deleteCmd = &cobra.Command{
 	Example: `  # Uninstall the app module from the default namespace
   timoni -n default delete app
 
-  # Do a dry-run uninstall and print the changes
+  # Uninstall the app module without asking for confirmation e.g. in CI
+  timoni -n default delete app --yes
+
+  # Do a dry-run uninstall and print the resources that would be deleted
   timoni delete --dry-run app
+
+  # Do a dry-run uninstall and list the inventory without querying the resources
+  timoni delete --dry-run=client app
+
+  # Uninstall the app module and give the pods 60 seconds to terminate gracefully
+  timoni -n default delete app --grace-period=60
+
+  # Remove the app instance from Timoni and keep its resources in the cluster
+  timoni -n default delete app --orphan
+
+  # Uninstall the app module and remove the finalizers of the resources stuck in terminating
+  timoni -n default delete app --force-delete --timeout=2m
+
+  # Uninstall the app module and print the deleted resources as JSON
+  timoni -n default delete app --output=json | jq '.objects[].name'
+
+  # Delete only the workloads of the app instance and keep its configuration
+  timoni -n default delete app --kind Deployment --kind StatefulSet
+
+  # Save the inventory of the app instance to a file before uninstalling it
+  timoni -n default delete app --export=app-inventory.yaml
+
+  # Ask for confirmation only if the instance storage has the environment=production label
+  timoni -n default delete app --confirm=protected
+
+  # Print the number of resources that would be deleted
+  timoni -n default delete app --count-only
+
+  # Uninstall the app module and wait for the finalization without failing on timeout
+  timoni -n default delete app --wait --timeout=2m --no-wait-timeout-error
+
+  # Uninstall the app module only if it wasn't applied again since its digest was read with 'timoni status'
+  timoni -n default delete app --expect-digest=sha256:<hex> --yes
+
+  # Uninstall the app instances from all namespaces e.g. of a per-tenant deployment
+  timoni delete app --all-namespaces --yes
+
+  # Uninstall the app module, succeeding if it was already uninstalled e.g. by a retried CI job
+  timoni -n default delete app --ignore-not-found --wait --yes
+
+  # Uninstall the app module only if the pre-delete check succeeds
+  timoni -n default delete app --pre-delete-cmd='./scripts/no-active-sessions.sh'
+
+  # Uninstall the app module and run the pre-delete hook recorded in its inventory annotations
+  timoni -n default delete app --run-hooks
+
+  # Uninstall all the instances with the env=preview label from the apps namespace
+  timoni -n apps delete --selector env=preview
 `,
 	RunE: runDeleteCmd,
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -50,29 +123,394 @@ var deleteCmd = &cobra.Command{
 }
 
 type deleteFlags struct {
-	name   string
-	dryrun bool
-	wait   bool
+	name        string
+	selector    string
+	dryrun      string
+	orphan      bool
+	wait        bool
+	gracePeriod int64
+	propagation string
+	concurrency int
+	forceDelete bool
+	retries     int
+	kinds       []string
+	output      string
+	yes         bool
+	summary     bool
+	export      string
+
+	includeClusterResources bool
+	objectTimeout           time.Duration
+	preDeleteCmd            string
+	runHooks                bool
+	ignoreNotFound          bool
+	allNamespaces           bool
+	confirm                 string
+	protectLabel            string
+	noWaitTimeoutError      bool
+	countOnly               bool
+	expectDigest            string
 }
 
 var deleteArgs deleteFlags
 
+const (
+	deleteDryRunServer = "server"
+	deleteDryRunClient = "client"
+)
+
+const (
+	// deleteConfirmAlways asks for confirmation before deleting any instance.
+	deleteConfirmAlways = "always"
+
+	// deleteConfirmProtected asks for confirmation only before deleting
+	// the instances whose storage carries the protected label.
+	deleteConfirmProtected = "protected"
+
+	// defaultProtectLabel is the storage label marking the protected instances.
+	defaultProtectLabel = "environment=production"
+)
+
+// parseProtectLabel splits the given key=value pair of the --protect-label flag.
+func parseProtectLabel(label string) (string, string, error) {
+	key, value, ok := strings.Cut(label, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid protect label '%s', must be in the format key=value", label)
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid protect label key '%s': %s", key, strings.Join(errs, ", "))
+	}
+	return key, value, nil
+}
+
+// requiresConfirmation returns true if the deletion of the given instance must be
+// confirmed interactively. In the protected mode, only the instances carrying
+// the protected label in their storage are confirmed.
+func requiresConfirmation(inst *apiv1.Instance) bool {
+	if deleteArgs.yes {
+		return false
+	}
+	if deleteArgs.confirm != deleteConfirmProtected {
+		return true
+	}
+	key, value, err := parseProtectLabel(deleteArgs.protectLabel)
+	if err != nil {
+		return true
+	}
+	return inst.GetLabels()[key] == value
+}
+
+// instanceKubeContext returns the kubeconfig context recorded in the instance storage,
+// if it differs from the context in use. The context set with --kube-context takes
+// precedence over the recorded one, and the context in use is kept when the recorded
+// one is not defined in the kubeconfig e.g. when deleting from another machine.
+func instanceKubeContext(log logr.Logger, inst *apiv1.Instance) (string, bool) {
+	if inst.ClusterContext == "" {
+		return "", false
+	}
+	current, err := runtime.KubeContext(kubeconfigArgs)
+	if err != nil || current == inst.ClusterContext {
+		return "", false
+	}
+	if *kubeconfigArgs.Context != "" {
+		log.Info(colorizeJoin(colorizeWarning("warning:"),
+			fmt.Sprintf("the instance was applied with the context %s, using --kube-context %s",
+				inst.ClusterContext, current)))
+		return "", false
+	}
+	if exists, err := runtime.KubeContextExists(kubeconfigArgs, inst.ClusterContext); err != nil || !exists {
+		log.Info(colorizeJoin(colorizeWarning("warning:"),
+			fmt.Sprintf("the context %s the instance was applied with is not in the kubeconfig, using the current context %s",
+				inst.ClusterContext, current)))
+		return "", false
+	}
+	log.Info(fmt.Sprintf("using the kube context %s the instance was applied with", colorizeSubject(inst.ClusterContext)))
+	return inst.ClusterContext, true
+}
+
+// confirmReader reads the answers to the delete confirmation prompts. A single reader
+// is created per command, so that the answers typed ahead for the next instances
+// are not discarded with the buffer of a previous prompt.
+type confirmReader struct {
+	*bufio.Reader
+
+	// interactive is false when the input is a file which is not a terminal.
+	interactive bool
+}
+
+// newConfirmReader returns a confirmReader of the given input.
+func newConfirmReader(in io.Reader) *confirmReader {
+	interactive := true
+	if f, ok := in.(*os.File); ok && !isTerminal(f) {
+		interactive = false
+	}
+	return &confirmReader{Reader: bufio.NewReader(in), interactive: interactive}
+}
+
+// confirmDelete asks for the instance name to be typed in before deleting its resources,
+// or before deleting its storage in orphan mode.
+// The pre-delete hook, if any, is printed so that the command is reviewed before it runs.
+// When the input is not a terminal, the confirmation is refused to avoid scripts
+// waiting for input, and the deletion must be confirmed with --yes.
+func confirmDelete(in *confirmReader, out io.Writer, inst *apiv1.Instance, count int, hook string) error {
+	if !in.interactive {
+		return fmt.Errorf("deleting instance %s requires confirmation, use --yes in non-interactive sessions", inst.Name)
+	}
+
+	if hook != "" {
+		fmt.Fprintf(out, "The pre-delete hook will run: %s\n", hook)
+	}
+	if deleteArgs.orphan {
+		fmt.Fprintf(out, "This will delete the instance storage and orphan %v resources in namespace %s, type the instance name to confirm: ", count, inst.Namespace)
+	} else {
+		fmt.Fprintf(out, "This will delete %v resources in namespace %s, type the instance name to confirm: ", count, inst.Namespace)
+	}
+	answer, err := in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading the confirmation failed: %w", err)
+	}
+	if strings.TrimSpace(answer) != inst.Name {
+		return fmt.Errorf("delete aborted, the confirmation does not match the instance name %s", inst.Name)
+	}
+	return nil
+}
+
+// deletePropagationPolicies maps the values of the --propagation flag
+// to the Kubernetes deletion propagation policies.
+var deletePropagationPolicies = map[string]metav1.DeletionPropagation{
+	"background": metav1.DeletePropagationBackground,
+	"foreground": metav1.DeletePropagationForeground,
+	"orphan":     metav1.DeletePropagationOrphan,
+}
+
+// deletePropagationPolicy returns the deletion propagation policy of the given flag value.
+func deletePropagationPolicy(value string) (metav1.DeletionPropagation, error) {
+	policy, ok := deletePropagationPolicies[strings.ToLower(value)]
+	if !ok {
+		return "", fmt.Errorf("unsupported propagation policy '%s', can be 'background', 'foreground' or 'orphan'", value)
+	}
+	return policy, nil
+}
+
+// crdRemovalInterval is the interval at which the discovery API is
+// polled while waiting for the CustomResourceDefinition APIs removal.
+const crdRemovalInterval = 2 * time.Second
+
+// goneAction marks the objects which are listed in the instance
+// inventory but are no longer present in the cluster.
+const goneAction = runtime.AlreadyDeletedAction
+
+// preservedAction marks the cluster-scoped objects which are kept in the
+// cluster when the instance is deleted with --include-cluster-resources=false.
+const preservedAction ssa.Action = "preserved"
+
+// orphanedAction marks the objects which are kept in the cluster
+// when the instance is deleted with the orphan option.
+const orphanedAction ssa.Action = "orphaned"
+
 func init() {
-	deleteCmd.Flags().BoolVar(&deleteArgs.dryrun, "dry-run", false,
-		"Perform a server-side delete dry run.")
+	deleteCmd.Flags().StringVarP(&deleteArgs.selector, "selector", "l", "",
+		"Label selector e.g. 'env=preview' which selects the instances to delete by the labels of their storage, cannot be used with an instance name.")
+	deleteCmd.Flags().StringVar(&deleteArgs.dryrun, "dry-run", "",
+		"Perform a delete dry run, can be 'server' or 'client'. The server dry run queries the cluster "+
+			"to report the resources that were already deleted, the client dry run lists the inventory without any cluster calls.")
+	deleteCmd.Flags().Lookup("dry-run").NoOptDefVal = deleteDryRunServer
+	deleteCmd.Flags().BoolVar(&deleteArgs.orphan, "orphan", false,
+		"Remove the instance storage without deleting the resources from the cluster.")
 	deleteCmd.Flags().BoolVar(&deleteArgs.wait, "wait", true,
 		"Wait for the deleted Kubernetes objects to be finalized.")
+	deleteCmd.Flags().Int64Var(&deleteArgs.gracePeriod, "grace-period", -1,
+		"The period of time in seconds given to the pods to terminate gracefully, "+
+			"a negative value uses the default set in the pod spec and zero means immediate deletion.")
+	deleteCmd.Flags().StringVar(&deleteArgs.propagation, "propagation", "background",
+		"The deletion propagation policy, can be 'background', 'foreground' or 'orphan'. "+
+			"With foreground propagation, the resources are finalized only after their dependents e.g. the pods of a deployment are deleted, "+
+			"while orphan keeps the dependents in the cluster.")
+	deleteCmd.Flags().DurationVar(&deleteArgs.objectTimeout, "object-timeout", 0,
+		"The time to wait for the termination of each resource, the resources which exceed it are reported by name. "+
+			"Defaults to the global timeout, which is the limit for the whole wait.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.forceDelete, "force-delete", false,
+		"Remove the finalizers of the resources which are not finalized within the timeout, then wait once more for their deletion. "+
+			"This may leave behind the external resources managed by the finalizers.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.includeClusterResources, "include-cluster-resources", true,
+		"Delete the cluster-scoped resources e.g. ClusterRoles and CRDs. When set to false, the cluster-scoped resources "+
+			"are preserved and the instance is kept in the cluster with the preserved resources in its inventory.")
+	deleteCmd.Flags().StringArrayVar(&deleteArgs.kinds, "kind", nil,
+		"Delete only the resources of the specified kind e.g. 'Deployment', the other resources are kept in the instance inventory. This flag can be repeated.")
+	deleteCmd.Flags().IntVar(&deleteArgs.retries, "delete-retries", 3,
+		"The number of times the deletion of a resource is retried on transient API server errors, with exponential backoff.")
+	deleteCmd.Flags().IntVar(&deleteArgs.concurrency, "concurrency", 0,
+		"The number of resources deleted in parallel, defaults to the number of CPUs. "+
+			"The resources are deleted concurrently only within the same kind group, the groups are deleted in reverse apply order.")
+	deleteCmd.Flags().BoolVarP(&deleteArgs.yes, "yes", "y", false,
+		"Delete the resources without asking for confirmation, required when the command runs in a non-interactive session.")
+	deleteCmd.Flags().StringVar(&deleteArgs.confirm, "confirm", deleteConfirmAlways,
+		"When to ask for confirmation, can be 'always' or 'protected'. "+
+			"In the protected mode, only the instances whose storage carries the label set with --protect-label are confirmed, the others are deleted right away.")
+	deleteCmd.Flags().StringVar(&deleteArgs.protectLabel, "protect-label", defaultProtectLabel,
+		"The storage label in the format key=value marking the instances which are always confirmed before deletion, unless --yes is set.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.summary, "summary", true,
+		"Print the number of resources by action at the end of the deletion.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.noWaitTimeoutError, "no-wait-timeout-error", false,
+		"Log a warning and exit successfully when the resources are not finalized within the wait timeout, the deletion errors still fail the command.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.countOnly, "count-only", false,
+		"Print only the number of resources that would be deleted, taking into account the --kind and --include-cluster-resources flags, without deleting anything.")
+	deleteCmd.Flags().StringVar(&deleteArgs.expectDigest, "expect-digest", "",
+		"Abort with the exit code 3 if the instance digest, as printed by 'timoni status', differs from the specified one e.g. when the instance was applied again after it was read.")
+	deleteCmd.Flags().BoolVarP(&deleteArgs.allNamespaces, "all-namespaces", "A", false,
+		"Delete the instances with the specified name from all namespaces, requires --yes unless in dry run mode.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.ignoreNotFound, "ignore-not-found", false,
+		"Exit successfully without deleting anything if the instance storage is not found in the cluster.")
+	deleteCmd.Flags().StringVar(&deleteArgs.preDeleteCmd, "pre-delete-cmd", "",
+		"Shell command run before deleting the instance, the deletion is aborted if the command exits with a non-zero code. "+
+			"Overrides the command set with the '"+apiv1.PreDeleteAction+"' inventory annotation, the hook is not run in dry run mode.")
+	deleteCmd.Flags().BoolVar(&deleteArgs.runHooks, "run-hooks", false,
+		"Run the pre-delete hook set with the '"+apiv1.PreDeleteAction+"' inventory annotation, by default the annotation is ignored "+
+			"as it can be set by anyone with write access to the instance storage.")
+	deleteCmd.Flags().StringVar(&deleteArgs.export, "export", "",
+		"Write the instance inventory as multi-doc YAML to the given file, or to stdout with '-', before deleting the resources. "+
+			"The inventory is exported in dry run mode too, this flag cannot be used with a selector.")
+	deleteCmd.Flags().StringVarP(&deleteArgs.output, "output", "o", "",
+		"The format in which the deletion summary should be printed instead of the logs, can be 'json'. "+
+			"When deleting by selector, the summaries of the instances are printed as a JSON list.")
 	rootCmd.AddCommand(deleteCmd)
 }
 
+// deleteSummary holds the result of an instance deletion, printed with --output=json.
+type deleteSummary struct {
+	Name      string               `json:"name"`
+	Namespace string               `json:"namespace"`
+	Objects   []deleteSummaryEntry `json:"objects"`
+}
+
+// deleteSummaryEntry holds the deletion result of an instance object.
+type deleteSummaryEntry struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Action    string `json:"action"`
+	Error     string `json:"error,omitempty"`
+}
+
+// add records the deletion result of the given object.
+func (s *deleteSummary) add(object *unstructured.Unstructured, action ssa.Action, err error) {
+	entry := deleteSummaryEntry{
+		Kind:      object.GetKind(),
+		Name:      object.GetName(),
+		Namespace: object.GetNamespace(),
+		Action:    action.String(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.Objects = append(s.Objects, entry)
+}
+
+// changeSet returns the recorded deletion results as a change set,
+// along with the number of objects which failed to be deleted.
+func (s *deleteSummary) changeSet() (*ssa.ChangeSet, int) {
+	cs := ssa.NewChangeSet()
+	failed := 0
+	for _, entry := range s.Objects {
+		if entry.Error != "" {
+			failed++
+			continue
+		}
+		cs.Add(ssa.ChangeSetEntry{
+			Subject: entry.Kind + "/" + entry.Namespace + "/" + entry.Name,
+			Action:  ssa.Action(entry.Action),
+		})
+	}
+	return cs, failed
+}
+
+// printDeleteSummaries writes the deletion summaries to stdout in the format
+// specified with --output, it's a no-op for the default log output.
+func printDeleteSummaries(cmd *cobra.Command, summaries []*deleteSummary) error {
+	if deleteArgs.output != "json" {
+		return nil
+	}
+
+	var v any = summaries
+	if deleteArgs.selector == "" && !deleteArgs.allNamespaces && len(summaries) == 1 {
+		v = summaries[0]
+	}
+	marshalled, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("summary JSON conversion failed: %w", err)
+	}
+	marshalled = append(marshalled, "\n"...)
+	cmd.OutOrStdout().Write(marshalled)
+	return nil
+}
+
 func runDeleteCmd(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("name is required")
+	switch {
+	case len(args) < 1 && deleteArgs.selector == "":
+		return fmt.Errorf("name or selector is required")
+	case len(args) > 0 && deleteArgs.selector != "":
+		return fmt.Errorf("name and selector are mutually exclusive")
+	case len(deleteArgs.kinds) > 0 && deleteArgs.orphan:
+		return fmt.Errorf("kind and orphan are mutually exclusive")
+	case deleteArgs.export != "" && deleteArgs.selector != "":
+		return fmt.Errorf("export and selector are mutually exclusive")
+	case deleteArgs.export == "-" && deleteArgs.output != "":
+		return fmt.Errorf("export to stdout and output are mutually exclusive")
+	case deleteArgs.allNamespaces && deleteArgs.selector != "":
+		return fmt.Errorf("all-namespaces and selector are mutually exclusive")
+	case deleteArgs.allNamespaces && deleteArgs.export != "":
+		return fmt.Errorf("export and all-namespaces are mutually exclusive")
+	case deleteArgs.allNamespaces && !deleteArgs.yes && deleteArgs.dryrun == "" && !deleteArgs.countOnly:
+		return fmt.Errorf("deleting instances from all namespaces requires --yes")
+	case deleteArgs.countOnly && (deleteArgs.orphan || deleteArgs.export != "" || deleteArgs.output != ""):
+		return fmt.Errorf("count-only can't be used with orphan, export or output")
+	case deleteArgs.expectDigest != "" && (deleteArgs.allNamespaces || deleteArgs.selector != ""):
+		return fmt.Errorf("expect-digest can't be used with all-namespaces or selector")
+	}
+
+	if _, err := deletePropagationPolicy(deleteArgs.propagation); err != nil {
+		return err
+	}
+
+	switch deleteArgs.confirm {
+	case deleteConfirmAlways, deleteConfirmProtected:
+	default:
+		return fmt.Errorf("unsupported confirm mode '%s', can be '%s' or '%s'",
+			deleteArgs.confirm, deleteConfirmAlways, deleteConfirmProtected)
+	}
+	if _, _, err := parseProtectLabel(deleteArgs.protectLabel); err != nil {
+		return err
+	}
+
+	// The boolean values are kept for backward compatibility with the
+	// --dry-run flag which was a boolean before the client mode was added.
+	switch deleteArgs.dryrun {
+	case "true":
+		deleteArgs.dryrun = deleteDryRunServer
+	case "false":
+		deleteArgs.dryrun = ""
+	}
+
+	switch deleteArgs.dryrun {
+	case "", deleteDryRunServer, deleteDryRunClient:
+	default:
+		return fmt.Errorf("unsupported dry run mode '%s', can be '%s' or '%s'",
+			deleteArgs.dryrun, deleteDryRunServer, deleteDryRunClient)
 	}
 
-	deleteArgs.name = args[0]
+	switch deleteArgs.output {
+	case "":
+		if deleteArgs.countOnly {
+			// Discard the logs to print only the number of resources.
+			cmd.SetContext(logr.NewContext(cmd.Context(), logr.Discard()))
+		}
+	case "json":
+		// Discard the logs to keep the output a valid JSON document.
+		cmd.SetContext(logr.NewContext(cmd.Context(), logr.Discard()))
+	default:
+		return fmt.Errorf("unsupported output format '%s'", deleteArgs.output)
+	}
 
-	log := LoggerInstance(cmd.Context(), deleteArgs.name)
 	sm, err := runtime.NewResourceManager(kubeconfigArgs)
 	if err != nil {
 		return err
@@ -82,61 +520,639 @@ func runDeleteCmd(cmd *cobra.Command, args []string) error {
 	defer cancel()
 
 	iStorage := runtime.NewStorageManager(sm)
-	inst, err := iStorage.Get(ctx, deleteArgs.name, *kubeconfigArgs.Namespace)
+	var restGetter genericclioptions.RESTClientGetter = kubeconfigArgs
+
+	var instances []*apiv1.Instance
+	if deleteArgs.selector != "" {
+		selector, err := labels.Parse(deleteArgs.selector)
+		if err != nil {
+			return fmt.Errorf("invalid selector: %w", err)
+		}
+
+		instances, err = iStorage.ListBySelector(ctx, *kubeconfigArgs.Namespace, selector)
+		if err != nil {
+			return err
+		}
+		if len(instances) == 0 {
+			return fmt.Errorf("no instances found in namespace %s matching selector '%s'",
+				*kubeconfigArgs.Namespace, deleteArgs.selector)
+		}
+
+		names := make([]string, len(instances))
+		for i, inst := range instances {
+			names[i] = inst.Name
+		}
+		LoggerFrom(cmd.Context()).Info(fmt.Sprintf("found %v instance(s) matching selector '%s': %s",
+			len(instances), deleteArgs.selector, colorizeSubject(strings.Join(names, ", "))))
+	} else if deleteArgs.allNamespaces {
+		if strings.Contains(args[0], "/") {
+			return fmt.Errorf("invalid instance '%s', must be a name without namespace when deleting from all namespaces", args[0])
+		}
+		deleteArgs.name = args[0]
+
+		instances, err = iStorage.ListByName(ctx, deleteArgs.name)
+		if err != nil {
+			return err
+		}
+		if len(instances) == 0 {
+			if deleteArgs.ignoreNotFound {
+				LoggerInstance(cmd.Context(), deleteArgs.name).Info("instance not found in any namespace, nothing to delete")
+				if deleteArgs.countOnly {
+					fmt.Fprintln(cmd.OutOrStdout(), 0)
+					return nil
+				}
+				return printDeleteSummaries(cmd, []*deleteSummary{})
+			}
+			return fmt.Errorf("no instances named %s found in any namespace", deleteArgs.name)
+		}
+
+		names := make([]string, len(instances))
+		for i, inst := range instances {
+			names[i] = inst.Namespace + "/" + inst.Name
+		}
+		LoggerFrom(cmd.Context()).Info(fmt.Sprintf("found %v instance(s) named %s: %s",
+			len(instances), deleteArgs.name, colorizeSubject(strings.Join(names, ", "))))
+	} else {
+		name, err := instanceNameFromArg(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		deleteArgs.name = name
+
+		inst, err := iStorage.Get(ctx, deleteArgs.name, *kubeconfigArgs.Namespace)
+		if err != nil {
+			if deleteArgs.ignoreNotFound && apierrors.IsNotFound(err) {
+				LoggerInstance(cmd.Context(), deleteArgs.name).Info("instance not found, nothing to delete")
+				if deleteArgs.countOnly {
+					fmt.Fprintln(cmd.OutOrStdout(), 0)
+					return nil
+				}
+				return printDeleteSummaries(cmd, []*deleteSummary{{
+					Name:      deleteArgs.name,
+					Namespace: *kubeconfigArgs.Namespace,
+					Objects:   []deleteSummaryEntry{},
+				}})
+			}
+			return err
+		}
+
+		// The instance is deleted from the cluster it was applied to,
+		// when the kubeconfig context recorded in its storage differs.
+		if kubeContext, ok := instanceKubeContext(LoggerInstance(cmd.Context(), inst.Name), inst); ok {
+			contextArgs, err := runtime.WithKubeContext(kubeconfigArgs, kubeContext)
+			if err != nil {
+				return err
+			}
+			sm, err = runtime.NewResourceManager(contextArgs)
+			if err != nil {
+				return err
+			}
+			restGetter = contextArgs
+			iStorage = runtime.NewStorageManager(sm)
+			inst, err = iStorage.Get(ctx, deleteArgs.name, *kubeconfigArgs.Namespace)
+			if err != nil {
+				return fmt.Errorf("instance not found in context %s: %w", kubeContext, err)
+			}
+		}
+
+		if deleteArgs.expectDigest != "" {
+			if err := checkInstanceDigest(inst, deleteArgs.expectDigest); err != nil {
+				return err
+			}
+		}
+
+		instances = append(instances, inst)
+	}
+
+	if deleteArgs.countOnly {
+		count := 0
+		for _, inst := range instances {
+			objects, err := selectDeletableObjects(sm, inst)
+			if err != nil {
+				return err
+			}
+			count += len(objects)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), count)
+		return nil
+	}
+
+	confirmIn := newConfirmReader(rootCmd.InOrStdin())
+	hasErrors := false
+	var deletedObjects []*unstructured.Unstructured
+	var summaries []*deleteSummary
+	for _, inst := range instances {
+		log := LoggerInstance(cmd.Context(), inst.Name)
+		summary := &deleteSummary{Name: inst.Name, Namespace: inst.Namespace, Objects: []deleteSummaryEntry{}}
+		summaries = append(summaries, summary)
+		deleted, ok, err := deleteInstance(logr.NewContext(ctx, log), sm, iStorage, inst, summary, confirmIn)
+		if err != nil {
+			return err
+		}
+		if deleteArgs.summary {
+			cs, failed := summary.changeSet()
+			log.Info(summarizeChangeSet(cs, failed, deleteArgs.dryrun != ""))
+		}
+		hasErrors = hasErrors || !ok
+		deletedObjects = append(deletedObjects, deleted...)
+	}
+
+	if hasErrors {
+		if err := printDeleteSummaries(cmd, summaries); err != nil {
+			return err
+		}
+		os.Exit(1)
+	}
+
+	if deleteArgs.wait && len(deletedObjects) > 0 {
+		log := LoggerFrom(cmd.Context())
+		if len(instances) == 1 {
+			log = LoggerInstance(cmd.Context(), instances[0].Name)
+		}
+
+		waitOpts := ssa.DefaultWaitOptions()
+		waitOpts.Timeout = rootArgs.timeout
+		err = waitForTermination(log, sm, deletedObjects, waitOpts)
+		if err != nil && deleteArgs.forceDelete {
+			// Only the objects which exceeded their deadline are force deleted.
+			stuck := deletedObjects
+			var timeoutErr *runtime.TerminationTimeoutError
+			if errors.As(err, &timeoutErr) {
+				stuck = timeoutErr.Objects
+			}
+			err = forceDeleteObjects(log, sm, stuck, waitOpts)
+		}
+		var timeoutErr *runtime.TerminationTimeoutError
+		if deleteArgs.noWaitTimeoutError && errors.As(err, &timeoutErr) {
+			log.Info(colorizeJoin(colorizeWarning("warning:"), timeoutErr.Error()+", the finalization continues in the background"))
+			return printDeleteSummaries(cmd, summaries)
+		}
+		if err != nil {
+			return err
+		}
+
+		if crds := runtime.SelectCRDs(deletedObjects); len(crds) > 0 {
+			log.Info(fmt.Sprintf("waiting for the API of %v CustomResourceDefinition(s) to be removed", len(crds)))
+			if err := waitForCRDRemoval(restGetter, crds); err != nil {
+				return err
+			}
+		}
+		log.Info("all resources have been deleted")
+	}
+
+	return printDeleteSummaries(cmd, summaries)
+}
+
+// checkInstanceDigest returns an error with the exit code 3 if the digest of the given
+// instance differs from the expected one, so that the callers can tell apart an instance
+// applied again since they read it, read it again and retry. The expected digest
+// can be specified without the 'sha256:' prefix.
+func checkInstanceDigest(inst *apiv1.Instance, expected string) error {
+	if !strings.Contains(expected, ":") {
+		expected = "sha256:" + expected
+	}
+	digest := (&runtime.InstanceManager{Instance: *inst}).Digest()
+	if digest == expected {
+		return nil
+	}
+	return &exitCodeError{
+		code: 3,
+		err: fmt.Errorf("instance digest mismatch, expected %s but found %s, the instance was changed since it was read",
+			expected, digest),
+	}
+}
+
+// selectDeletableObjects returns the objects of the given instance which would be deleted,
+// the objects not matching --kind and the cluster-scoped ones preserved with
+// --include-cluster-resources=false are excluded.
+func selectDeletableObjects(sm *ssa.ResourceManager, inst *apiv1.Instance) ([]*unstructured.Unstructured, error) {
+	iManager := runtime.InstanceManager{Instance: *inst}
+	objects, err := iManager.ListObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deleteArgs.kinds) > 0 {
+		objects = selectObjectsByKind(objects, deleteArgs.kinds)
+	}
+
+	if !deleteArgs.includeClusterResources {
+		objects, _, err = runtime.SplitByScope(sm.Client(), objects)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+// deleteDryRunAction returns the action that the deletion of the given object would
+// result in, based on the live object: deleted, skipped if the object is excluded
+// by the delete options, or gone if the object is no longer present in the cluster.
+func deleteDryRunAction(ctx context.Context,
+	sm *ssa.ResourceManager,
+	object *unstructured.Unstructured,
+	opts ssa.DeleteOptions) (ssa.Action, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(object.GroupVersionKind())
+	if err := sm.Client().Get(ctx, client.ObjectKeyFromObject(object), live); err != nil {
+		if apierrors.IsNotFound(err) {
+			return goneAction, nil
+		}
+		return ssa.UnknownAction, fmt.Errorf("%s query failed: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	if !labels.SelectorFromSet(opts.Inclusions).Matches(labels.Set(live.GetLabels())) ||
+		ssa.AnyInMetadata(live, opts.Exclusions) {
+		return ssa.SkippedAction, nil
+	}
+	return ssa.DeletedAction, nil
+}
+
+// forceDeleteObjects removes the finalizers of the given objects which are stuck
+// in terminating, then waits once more for them to be deleted.
+func forceDeleteObjects(log logr.Logger,
+	sm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	waitOpts ssa.WaitOptions) error {
+	// The command context has expired while waiting, the finalizers are
+	// removed within a new timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	patched, err := runtime.RemoveFinalizers(ctx, sm.Client(), objects)
+	for _, object := range patched {
+		logJoin(log, object, colorizeWarning("finalizers removed"))
+	}
 	if err != nil {
 		return err
 	}
 
+	return waitForTermination(log, sm, objects, waitOpts)
+}
+
+// terminationProgressInterval is the interval at which the objects
+// not yet finalized are listed while waiting for their deletion.
+const terminationProgressInterval = 5 * time.Second
+
+// waitForCRDRemoval waits for the APIs of the given CustomResourceDefinitions
+// to be removed from the discovery, after the CRD objects were finalized.
+func waitForCRDRemoval(rcg genericclioptions.RESTClientGetter, crds []*unstructured.Unstructured) error {
+	cfg, err := rcg.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfig failed: %w", err)
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("initialising discovery client failed: %w", err)
+	}
+
+	spin := StartSpinner("waiting for the CustomResourceDefinition APIs to be removed...")
+	defer spin.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+	return runtime.WaitForCRDRemoval(ctx, dc, crds, crdRemovalInterval)
+}
+
+// waitForTermination waits for the given objects to be deleted from the cluster.
+// While waiting, the spinner is periodically updated with the objects still terminating.
+// Each object is waited for at most --object-timeout, within the overall wait timeout.
+func waitForTermination(log logr.Logger,
+	sm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	waitOpts ssa.WaitOptions) error {
+	spin := StartSpinnerWithTimer(log, terminationProgress(objects))
+	defer spin.Stop()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(terminationProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), terminationProgressInterval)
+				remaining, err := runtime.SelectExistingObjects(ctx, sm.Client(), objects)
+				cancel()
+				if err == nil && len(remaining) > 0 {
+					UpdateSpinner(spin, terminationProgress(remaining))
+				}
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitOpts.Timeout)
+	defer cancel()
+	return runtime.WaitForTermination(ctx, sm.Client(), objects, waitOpts.Interval, deleteArgs.objectTimeout)
+}
+
+// terminationProgress returns the spinner message listing the first
+// few objects which are not yet finalized.
+func terminationProgress(objects []*unstructured.Unstructured) string {
+	const maxSubjects = 3
+	var subjects []string
+	for i, obj := range objects {
+		if i == maxSubjects {
+			subjects = append(subjects, fmt.Sprintf("and %v more", len(objects)-maxSubjects))
+			break
+		}
+		subjects = append(subjects, ssa.FmtUnstructured(obj))
+	}
+	return fmt.Sprintf("waiting for %v resource(s) to be finalized: %s",
+		len(objects), strings.Join(subjects, ", "))
+}
+
+// deleteInstance deletes the objects from the inventory of the given instance in reverse
+// apply order, or in the order set with the delete order annotation, then removes the
+// instance storage. The objects of the same kind group are deleted concurrently.
+// It returns the deleted objects, and false if any of the objects failed to be deleted,
+// in which case the storage is kept. The result of each object is recorded in the given summary.
+// When kinds are specified, only the objects of these kinds are deleted and removed
+// from the inventory, the storage is kept with the remaining objects.
+// In dry-run mode, the objects are listed without being deleted, the server dry run
+// queries the cluster to report the objects that are already gone. In orphan mode, only
+// the instance storage is removed and the objects are kept in the cluster.
+// The confirmation, when required, is read from the given reader before any change.
+func deleteInstance(ctx context.Context,
+	sm *ssa.ResourceManager,
+	iStorage *runtime.StorageManager,
+	inst *apiv1.Instance,
+	summary *deleteSummary,
+	confirmIn *confirmReader) ([]*unstructured.Unstructured, bool, error) {
+	log := LoggerFrom(ctx)
+
 	iManager := runtime.InstanceManager{Instance: *inst}
 	objects, err := iManager.ListObjects()
 	if err != nil {
-		return err
+		return nil, false, err
+	}
+
+	if deleteArgs.export != "" {
+		if err := exportInventory(deleteArgs.export, objects); err != nil {
+			return nil, false, err
+		}
+		if deleteArgs.export != "-" {
+			log.Info(fmt.Sprintf("inventory exported to %s", deleteArgs.export))
+		}
 	}
 
 	sort.Sort(sort.Reverse(ssa.SortableUnstructureds(objects)))
 
-	if deleteArgs.dryrun {
+	if len(deleteArgs.kinds) > 0 {
+		objects = selectObjectsByKind(objects, deleteArgs.kinds)
+		if len(objects) == 0 {
+			log.Info(fmt.Sprintf("no resources found of kind %s", strings.Join(deleteArgs.kinds, ", ")))
+			return nil, true, nil
+		}
+		log.Info(colorizeWarning(fmt.Sprintf("deleting only the resources of kind %s, the instance will be partially managed",
+			strings.Join(deleteArgs.kinds, ", "))))
+	}
+
+	hook := preDeleteHook(inst)
+	if hook == "" && inst.GetAnnotations()[apiv1.PreDeleteAction] != "" {
+		logJoin(log, colorizeWarning("warning:"),
+			fmt.Sprintf("skipping the pre-delete hook set with the %s annotation, use --run-hooks to run it", apiv1.PreDeleteAction))
+	}
+	if hook != "" && deleteArgs.dryrun != "" {
+		log.Info(fmt.Sprintf("pre-delete hook would run: %s", hook))
+	}
+
+	var preserved []*unstructured.Unstructured
+	if !deleteArgs.includeClusterResources && !deleteArgs.orphan {
+		objects, preserved, err = runtime.SplitByScope(sm.Client(), objects)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, object := range preserved {
+			summary.add(object, preservedAction, nil)
+			logJoin(log, object, preservedAction, "(cluster-scoped)")
+		}
+		if len(preserved) > 0 {
+			log.Info(colorizeWarning(fmt.Sprintf("preserving %v cluster-scoped resource(s), the instance will be partially managed",
+				len(preserved))))
+		}
+	}
+
+	concurrency := deleteArgs.concurrency
+	if concurrency < 1 {
+		concurrency = goruntime.NumCPU()
+	}
+
+	propagation, err := deletePropagationPolicy(deleteArgs.propagation)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The deletion, including the orphan mode, is confirmed before any change.
+	if deleteArgs.dryrun == "" && requiresConfirmation(inst) {
+		if deleteArgs.confirm == deleteConfirmProtected {
+			log.Info(fmt.Sprintf("instance is protected by the label %s", colorizeSubject(deleteArgs.protectLabel)))
+		}
+		if err := confirmDelete(confirmIn, rootCmd.ErrOrStderr(), inst, len(objects), hook); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if deleteArgs.orphan {
 		for _, object := range objects {
-			log.Info(colorizeJoin(object, ssa.DeletedAction, dryRunClient))
+			summary.add(object, orphanedAction, nil)
+			if deleteArgs.dryrun != "" {
+				logJoin(log, object, orphanedAction, dryRunClient)
+			} else {
+				logJoin(log, object, orphanedAction)
+			}
+		}
+		if deleteArgs.dryrun != "" {
+			return nil, true, nil
+		}
+		if hook != "" {
+			if err := runPreDeleteHook(ctx, log, inst, hook); err != nil {
+				return nil, false, err
+			}
+		}
+		return nil, true, iStorage.Delete(ctx, inst.Name, inst.Namespace)
+	}
+
+	// The client dry run lists the objects in reverse apply order,
+	// as the delete order weights are read from the cluster.
+	if deleteArgs.dryrun != deleteDryRunClient {
+		if err := runtime.SortForDeletion(ctx, sm.Client(), objects); err != nil {
+			return nil, false, err
 		}
-		return nil
+	}
+
+	switch deleteArgs.dryrun {
+	case deleteDryRunClient:
+		for _, object := range objects {
+			summary.add(object, ssa.DeletedAction, nil)
+			logJoin(log, object, ssa.DeletedAction, dryRunClient)
+		}
+		return nil, true, nil
+	case deleteDryRunServer:
+		hasErrors := false
+		deleteOpts := runtime.DeleteOptions(inst.Name, inst.Namespace)
+		for _, object := range objects {
+			action, err := deleteDryRunAction(ctx, sm, object, deleteOpts)
+			summary.add(object, action, err)
+			if err != nil {
+				log.Error(err, "dry run failed")
+				hasErrors = true
+				continue
+			}
+			logJoin(log, object, action, dryRunServer)
+		}
+		return nil, !hasErrors, nil
+	}
+
+	if hook != "" {
+		if err := runPreDeleteHook(ctx, log, inst, hook); err != nil {
+			return nil, false, err
+		}
+	}
+
+	retryOpts := runtime.RetryOptions{
+		Retries: deleteArgs.retries,
+		Backoff: time.Second,
 	}
 
 	log.Info(fmt.Sprintf("deleting %v resource(s)...", len(objects)))
 	hasErrors := false
 	cs := ssa.NewChangeSet()
-	for _, object := range objects {
-		deleteOpts := runtime.DeleteOptions(deleteArgs.name, *kubeconfigArgs.Namespace)
-		change, err := sm.Delete(ctx, object, deleteOpts)
-		if err != nil {
-			log.Error(err, "deletion failed")
-			hasErrors = true
-			continue
+	var mu sync.Mutex
+	for _, stage := range runtime.DeleteStages(objects) {
+		var wg sync.WaitGroup
+		workers := make(chan struct{}, concurrency)
+		for _, object := range stage {
+			wg.Add(1)
+			workers <- struct{}{}
+			go func(object *unstructured.Unstructured) {
+				defer wg.Done()
+				defer func() { <-workers }()
+
+				deleteOpts := runtime.DeleteOptions(inst.Name, inst.Namespace)
+				deleteOpts.PropagationPolicy = propagation
+				change, err := runtime.DeleteWithRetry(ctx, object, retryOpts, func() (*ssa.ChangeSetEntry, error) {
+					return runtime.DeleteWithGracePeriod(ctx, sm, object, deleteOpts, deleteArgs.gracePeriod)
+				})
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					summary.add(object, ssa.UnknownAction, err)
+					log.Error(err, "deletion failed")
+					hasErrors = true
+					return
+				}
+				summary.add(object, change.Action, nil)
+				cs.Add(*change)
+				logJoin(log, change)
+			}(object)
 		}
-		cs.Add(*change)
-		log.Info(colorizeJoin(change))
+		wg.Wait()
 	}
 
 	if hasErrors {
-		os.Exit(1)
+		return nil, false, nil
+	}
+
+	// The objects already deleted are removed from the inventory, but are not waited for.
+	deleted := runtime.SelectObjectsFromSet(cs, ssa.DeletedAction)
+	if len(deleteArgs.kinds) > 0 || len(preserved) > 0 {
+		iManager.RemoveObjects(append(runtime.SelectObjectsFromSet(cs, goneAction), deleted...))
+		if err := iStorage.Apply(ctx, &iManager.Instance, false); err != nil {
+			return nil, false, fmt.Errorf("instance inventory update failed: %w", err)
+		}
+		return deleted, true, nil
 	}
 
 	if err := iStorage.Delete(ctx, inst.Name, inst.Namespace); err != nil {
-		return err
+		return nil, false, err
 	}
 
-	deletedObjects := runtime.SelectObjectsFromSet(cs, ssa.DeletedAction)
-	if deleteArgs.wait && len(deletedObjects) > 0 {
-		waitOpts := ssa.DefaultWaitOptions()
-		waitOpts.Timeout = rootArgs.timeout
-		spin := StartSpinner(fmt.Sprintf("waiting for %v resource(s) to be finalized...", len(deletedObjects)))
-		err = sm.WaitForTermination(deletedObjects, waitOpts)
-		spin.Stop()
+	return deleted, true, nil
+}
+
+// preDeleteHook returns the command set with --pre-delete-cmd, or the one
+// recorded in the instance annotations when the hooks are enabled with --run-hooks.
+// The annotation is read from the cluster, running it unconditionally would allow
+// anyone with write access to the instance storage to run commands on this machine.
+func preDeleteHook(inst *apiv1.Instance) string {
+	if deleteArgs.preDeleteCmd != "" {
+		return deleteArgs.preDeleteCmd
+	}
+	if !deleteArgs.runHooks {
+		return ""
+	}
+	return inst.GetAnnotations()[apiv1.PreDeleteAction]
+}
+
+// runPreDeleteHook runs the given shell command and logs its output line by line.
+// The instance name and namespace are passed to the command as the TIMONI_INSTANCE_NAME
+// and TIMONI_INSTANCE_NAMESPACE environment variables.
+func runPreDeleteHook(ctx context.Context, log logr.Logger, inst *apiv1.Instance, command string) error {
+	log.Info(fmt.Sprintf("running pre-delete hook: %s", command))
+
+	var stdout, stderr bytes.Buffer
+	hookCmd := exec.CommandContext(ctx, "sh", "-c", command)
+	hookCmd.Env = append(os.Environ(),
+		"TIMONI_INSTANCE_NAME="+inst.Name,
+		"TIMONI_INSTANCE_NAMESPACE="+inst.Namespace)
+	hookCmd.Stdout = &stdout
+	hookCmd.Stderr = &stderr
+	err := hookCmd.Run()
+
+	for _, output := range []*bytes.Buffer{&stdout, &stderr} {
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			log.Info(colorizeJoin(colorizeInfo("pre-delete:"), scanner.Text()))
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("pre-delete hook failed, the instance was not deleted: %w", err)
+	}
+	return nil
+}
+
+// exportInventory writes the given objects as multi-doc YAML to the given file,
+// or to stdout if the path is '-'. The objects are sorted in apply order.
+func exportInventory(path string, objects []*unstructured.Unstructured) error {
+	sorted := slices.Clone(objects)
+	sort.Sort(ssa.SortableUnstructureds(sorted))
+
+	var sb strings.Builder
+	for _, obj := range sorted {
+		data, err := yaml.Marshal(obj)
 		if err != nil {
-			return err
+			return fmt.Errorf("converting objects failed: %w", err)
 		}
-		log.Info("all resources have been deleted")
+		sb.WriteString("---\n")
+		sb.Write(data)
 	}
 
+	if path == "-" {
+		_, err := rootCmd.OutOrStdout().Write([]byte(sb.String()))
+		return err
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("inventory export failed: %w", err)
+	}
 	return nil
 }
+
+// selectObjectsByKind returns the objects matching any of the given kinds, case-insensitive.
+func selectObjectsByKind(objects []*unstructured.Unstructured, kinds []string) []*unstructured.Unstructured {
+	var selected []*unstructured.Unstructured
+	for _, obj := range objects {
+		for _, kind := range kinds {
+			if strings.EqualFold(obj.GetKind(), kind) {
+				selected = append(selected, obj)
+				break
+			}
+		}
+	}
+	return selected
+}
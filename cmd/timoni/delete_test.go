@@ -17,16 +17,31 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zerologr"
 	. "github.com/onsi/gomega"
+	"github.com/rs/zerolog"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/runtime"
 )
 
 func TestDelete(t *testing.T) {
@@ -60,7 +75,7 @@ func TestDelete(t *testing.T) {
 	t.Run("skips annotated resources on uninstall", func(t *testing.T) {
 		g := NewWithT(t)
 		_, err := executeCommand(fmt.Sprintf(
-			"delete -n %s %s --wait",
+			"delete -n %s %s --wait --yes",
 			namespace,
 			name,
 		))
@@ -77,3 +92,1179 @@ func TestDelete(t *testing.T) {
 		g.Expect(err).ToNot(HaveOccurred())
 	})
 }
+
+func TestDelete_AlreadyDeleted(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	clientCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-client", name),
+			Namespace: namespace,
+		},
+	}
+	g.Expect(envTestClient.Delete(context.Background(), clientCM)).To(Succeed())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --wait --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client already deleted", namespace, name)))
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+	g.Expect(output).To(ContainSubstring("Failed: 0"))
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestDelete_IgnoreNotFound(t *testing.T) {
+	g := NewWithT(t)
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("instance storage not found"))
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --yes --ignore-not-found",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring("instance not found, nothing to delete"))
+
+	output, err = executeCommand(fmt.Sprintf(
+		"delete -n %s %s --yes --ignore-not-found -o json",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var summary deleteSummary
+	g.Expect(json.Unmarshal([]byte(output), &summary)).To(Succeed())
+	g.Expect(summary.Name).To(Equal(name))
+	g.Expect(summary.Objects).To(BeEmpty())
+}
+
+func TestDelete_AllNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespaces := []string{rnd("my-namespace-a", 5), rnd("my-namespace-b", 5)}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	t.Run("requires confirmation", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf("delete %s --all-namespaces", name))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("requires --yes"))
+	})
+
+	t.Run("deletes the instances from all namespaces", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf("delete %s -A --wait --yes", name))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("found 2 instance(s) named %s: %s/%s, %s/%s",
+			name, namespaces[0], name, namespaces[1], name)))
+
+		for _, namespace := range namespaces {
+			g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+
+			storage := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("timoni.%s", name),
+					Namespace: namespace,
+				},
+			}
+			err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}
+	})
+
+	t.Run("fails when no instance is found", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf("delete %s -A --yes", name))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("found in any namespace"))
+	})
+}
+
+func TestDelete_GracePeriod(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --grace-period=0 --wait --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+
+	serverCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestDelete_ObjectTimeout(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	clientCM := &corev1.ConfigMap{}
+	clientKey := client.ObjectKey{Name: fmt.Sprintf("%s-client", name), Namespace: namespace}
+	g.Expect(envTestClient.Get(context.Background(), clientKey, clientCM)).To(Succeed())
+	clientCM.SetFinalizers([]string{"timoni.sh/test"})
+	g.Expect(envTestClient.Update(context.Background(), clientCM)).To(Succeed())
+	defer func() {
+		g.Expect(envTestClient.Get(context.Background(), clientKey, clientCM)).To(Succeed())
+		clientCM.SetFinalizers(nil)
+		g.Expect(envTestClient.Update(context.Background(), clientCM)).To(Succeed())
+	}()
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --wait --yes --object-timeout=2s",
+		namespace,
+		name,
+	))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("timeout waiting for the termination of 1 resource(s): ConfigMap/%s/%s-client", namespace, name)))
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+
+	serverCM := &corev1.ConfigMap{}
+	err = envTestClient.Get(context.Background(), client.ObjectKey{Name: fmt.Sprintf("%s-server", name), Namespace: namespace}, serverCM)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestDelete_NoWaitTimeoutError(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	clientCM := &corev1.ConfigMap{}
+	clientKey := client.ObjectKey{Name: fmt.Sprintf("%s-client", name), Namespace: namespace}
+	g.Expect(envTestClient.Get(context.Background(), clientKey, clientCM)).To(Succeed())
+	clientCM.SetFinalizers([]string{"timoni.sh/test"})
+	g.Expect(envTestClient.Update(context.Background(), clientCM)).To(Succeed())
+	defer func() {
+		g.Expect(envTestClient.Get(context.Background(), clientKey, clientCM)).To(Succeed())
+		clientCM.SetFinalizers(nil)
+		g.Expect(envTestClient.Update(context.Background(), clientCM)).To(Succeed())
+	}()
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --wait --yes --object-timeout=2s --no-wait-timeout-error",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("timeout waiting for the termination of 1 resource(s): ConfigMap/%s/%s-client", namespace, name)))
+	g.Expect(output).To(ContainSubstring("the finalization continues in the background"))
+	g.Expect(output).ToNot(ContainSubstring("all resources have been deleted"))
+}
+
+func TestDelete_Confirm(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	t.Run("refuses non-interactive input", func(t *testing.T) {
+		g := NewWithT(t)
+		r, w, err := os.Pipe()
+		g.Expect(err).ToNot(HaveOccurred())
+		defer r.Close()
+		defer w.Close()
+
+		err = confirmDelete(newConfirmReader(r), io.Discard, &apiv1.Instance{ObjectMeta: metav1.ObjectMeta{Name: name}}, 1, "")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("use --yes"))
+	})
+
+	t.Run("aborts on mismatch", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommandWithIn(fmt.Sprintf(
+			"delete -n %s %s",
+			namespace,
+			name,
+		), strings.NewReader("other\n"))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("delete aborted"))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("in namespace %s, type the instance name to confirm", namespace)))
+
+		serverCM := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-server", name),
+				Namespace: namespace,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("deletes on confirmation", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommandWithIn(fmt.Sprintf(
+			"delete -n %s %s --wait",
+			namespace,
+			name,
+		), strings.NewReader(name+"\n"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+	})
+}
+
+func TestConfirmDelete_SharedReader(t *testing.T) {
+	g := NewWithT(t)
+	in := newConfirmReader(strings.NewReader("app\ndb\n"))
+
+	for _, name := range []string{"app", "db"} {
+		inst := &apiv1.Instance{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		g.Expect(confirmDelete(in, io.Discard, inst, 1, "")).To(Succeed())
+	}
+}
+
+func TestRequiresConfirmation(t *testing.T) {
+	defer resetCmdArgs()
+	prod := &apiv1.Instance{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"environment": "production"}}}
+	dev := &apiv1.Instance{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"environment": "dev"}}}
+
+	tests := []struct {
+		name    string
+		confirm string
+		yes     bool
+		inst    *apiv1.Instance
+		want    bool
+	}{
+		{name: "always confirms", confirm: deleteConfirmAlways, inst: dev, want: true},
+		{name: "yes skips the confirmation", confirm: deleteConfirmAlways, yes: true, inst: prod, want: false},
+		{name: "confirms protected instance", confirm: deleteConfirmProtected, inst: prod, want: true},
+		{name: "skips unprotected instance", confirm: deleteConfirmProtected, inst: dev, want: false},
+		{name: "yes skips protected instance", confirm: deleteConfirmProtected, yes: true, inst: prod, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			resetCmdArgs()
+			deleteArgs.confirm = tt.confirm
+			deleteArgs.yes = tt.yes
+			g.Expect(requiresConfirmation(tt.inst)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestDelete_ConfirmProtected(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	namespace := rnd("my-namespace", 5)
+	protected := rnd("my-instance", 5)
+	unprotected := rnd("my-instance", 5)
+
+	for _, name := range []string{protected, unprotected} {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", protected),
+			Namespace: namespace,
+		},
+	}
+	err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+	storage.Labels["tier"] = "prod"
+	g.Expect(envTestClient.Update(context.Background(), storage)).To(Succeed())
+
+	t.Run("fails for invalid protect label", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --confirm=protected --protect-label=tier",
+			namespace,
+			unprotected,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("must be in the format key=value"))
+	})
+
+	t.Run("deletes the unprotected instance without confirmation", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --confirm=protected --protect-label=tier=prod --wait",
+			namespace,
+			unprotected,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).ToNot(ContainSubstring("type the instance name to confirm"))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, unprotected)))
+	})
+
+	t.Run("confirms the protected instance", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommandWithIn(fmt.Sprintf(
+			"delete -n %s %s --confirm=protected --protect-label=tier=prod",
+			namespace,
+			protected,
+		), strings.NewReader("other\n"))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("delete aborted"))
+		g.Expect(output).To(ContainSubstring("instance is protected by the label tier=prod"))
+
+		output, err = executeCommandWithIn(fmt.Sprintf(
+			"delete -n %s %s --confirm=protected --protect-label=tier=prod --wait",
+			namespace,
+			protected,
+		), strings.NewReader(protected+"\n"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, protected)))
+	})
+}
+
+func TestDelete_Propagation(t *testing.T) {
+	g := NewWithT(t)
+
+	for value, want := range map[string]metav1.DeletionPropagation{
+		"background": metav1.DeletePropagationBackground,
+		"Foreground": metav1.DeletePropagationForeground,
+		"orphan":     metav1.DeletePropagationOrphan,
+	} {
+		policy, err := deletePropagationPolicy(value)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(policy).To(Equal(want))
+	}
+
+	_, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --propagation=cascade",
+		rnd("my-namespace", 5),
+		rnd("my-instance", 5),
+	))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unsupported propagation policy 'cascade'"))
+}
+
+func TestDelete_Selector(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	namespace := rnd("my-namespace", 5)
+	selected := rnd("my-instance", 5)
+	skipped := rnd("my-instance", 5)
+
+	for _, name := range []string{selected, skipped} {
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", selected),
+			Namespace: namespace,
+		},
+	}
+	err := envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+	storage.Labels["env"] = "preview"
+	g.Expect(envTestClient.Update(context.Background(), storage)).To(Succeed())
+
+	t.Run("fails for name and selector", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --selector env=preview --yes",
+			namespace,
+			selected,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("mutually exclusive"))
+	})
+
+	t.Run("lists the selected instances on dry run", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s --selector env=preview --dry-run",
+			namespace,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("found 1 instance(s) matching selector 'env=preview': %s", selected)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, selected)))
+		g.Expect(output).ToNot(ContainSubstring(skipped))
+	})
+
+	t.Run("deletes the selected instances", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"delete -n %s --selector env=preview --wait --yes",
+			namespace,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		for name, deleted := range map[string]bool{selected: true, skipped: false} {
+			serverCM := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s-server", name),
+					Namespace: namespace,
+				},
+			}
+			err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+			g.Expect(apierrors.IsNotFound(err)).To(Equal(deleted))
+		}
+	})
+}
+
+func TestDelete_Orphan(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+	serverCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
+		},
+	}
+
+	t.Run("lists the orphaned objects on dry run", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --orphan --dry-run",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server orphaned", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("asks for confirmation before removing the storage", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommandWithIn(fmt.Sprintf(
+			"delete -n %s %s --orphan",
+			namespace,
+			name,
+		), strings.NewReader("other\n"))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("delete aborted"))
+		g.Expect(output).To(ContainSubstring("This will delete the instance storage and orphan"))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("removes the storage and keeps the objects", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --orphan --yes",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+}
+
+func TestDelete_Concurrency(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --concurrency=2 --wait --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client deleted", namespace, name)))
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestDelete_OutputJSON(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --output=json --wait --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var summary deleteSummary
+	g.Expect(json.Unmarshal([]byte(output), &summary)).To(Succeed())
+	g.Expect(summary.Name).To(Equal(name))
+	g.Expect(summary.Namespace).To(Equal(namespace))
+	g.Expect(summary.Objects).To(ContainElement(deleteSummaryEntry{
+		Kind:      "ConfigMap",
+		Name:      fmt.Sprintf("%s-server", name),
+		Namespace: namespace,
+		Action:    "deleted",
+	}))
+}
+
+func TestDelete_DryRun(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	clientCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-client", name),
+			Namespace: namespace,
+		},
+	}
+	g.Expect(envTestClient.Delete(context.Background(), clientCM)).To(Succeed())
+
+	t.Run("reports the deleted objects on server dry run", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --dry-run",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted (server dry run)", namespace, name)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client already deleted (server dry run)", namespace, name)))
+		g.Expect(output).To(ContainSubstring("Already deleted: 1"))
+		g.Expect(output).ToNot(ContainSubstring("Failed:"))
+	})
+
+	t.Run("lists the inventory on client dry run", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --dry-run=client",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client deleted (dry run)", namespace, name)))
+	})
+
+	t.Run("maps the boolean dry run to server", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --dry-run=true",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted (server dry run)", namespace, name)))
+	})
+
+	t.Run("fails for unknown dry run mode", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --dry-run=yes",
+			namespace,
+			name,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unsupported dry run mode"))
+	})
+}
+
+func TestDelete_Export(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	t.Run("exports the inventory to a file on dry run", func(t *testing.T) {
+		g := NewWithT(t)
+		file := filepath.Join(t.TempDir(), "inventory.yaml")
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --dry-run --export %s",
+			namespace,
+			name,
+			file,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("inventory exported to " + file))
+
+		data, err := os.ReadFile(file)
+		g.Expect(err).ToNot(HaveOccurred())
+		objects, err := ssa.ReadObjects(strings.NewReader(string(data)))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(objects).To(HaveLen(2))
+		g.Expect(objects[0].GetName()).To(Equal(name + "-client"))
+		g.Expect(objects[1].GetName()).To(Equal(name + "-server"))
+	})
+
+	t.Run("exports the inventory to stdout", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --export - --wait --yes",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("name: %s-server", name)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+	})
+
+	t.Run("fails with selector", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"delete -n %s --selector app=test --export -",
+			namespace,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("export and selector are mutually exclusive"))
+	})
+}
+
+func TestDelete_PreDeleteHook(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait --inventory-annotation='%s=echo checking $TIMONI_INSTANCE_NAME; exit 3'",
+		namespace,
+		name,
+		modPath,
+		apiv1.PreDeleteAction,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+
+	t.Run("ignores the annotation hook without run-hooks", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --dry-run",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("use --run-hooks to run it"))
+		g.Expect(output).ToNot(ContainSubstring("pre-delete hook would run"))
+	})
+
+	t.Run("skips the hook on dry run", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --dry-run --run-hooks",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("pre-delete hook would run: echo checking $TIMONI_INSTANCE_NAME; exit 3"))
+		g.Expect(output).ToNot(ContainSubstring("checking " + name))
+	})
+
+	t.Run("skips the hook when nothing would be deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --kind Deployment --run-hooks --yes",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("no resources found of kind Deployment"))
+		g.Expect(output).ToNot(ContainSubstring("checking " + name))
+	})
+
+	t.Run("shows the hook in the prompt and skips it on abort", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommandWithIn(fmt.Sprintf(
+			"delete -n %s %s --run-hooks",
+			namespace,
+			name,
+		), strings.NewReader("other\n"))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("delete aborted"))
+		g.Expect(output).To(ContainSubstring("The pre-delete hook will run: echo checking $TIMONI_INSTANCE_NAME; exit 3"))
+		g.Expect(output).ToNot(ContainSubstring("checking " + name))
+	})
+
+	t.Run("aborts the deletion when the annotation hook fails", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --wait --yes --run-hooks",
+			namespace,
+			name,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("pre-delete hook failed"))
+		g.Expect(output).To(ContainSubstring("checking " + name))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("deletes the instance when the flag hook succeeds", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --wait --yes --pre-delete-cmd='echo ready in $TIMONI_INSTANCE_NAMESPACE'",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("ready in " + namespace))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
+
+func TestRunPreDeleteHook(t *testing.T) {
+	inst := &apiv1.Instance{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"}}
+
+	newLogger := func(buf *bytes.Buffer) logr.Logger {
+		zcfg := zerolog.ConsoleWriter{Out: buf, NoColor: true}
+		zl := zerolog.New(zcfg)
+		return zerologr.New(&zl)
+	}
+
+	t.Run("logs stdout and stderr", func(t *testing.T) {
+		g := NewWithT(t)
+		buf := new(bytes.Buffer)
+		err := runPreDeleteHook(context.Background(), newLogger(buf), inst,
+			"echo $TIMONI_INSTANCE_NAMESPACE/$TIMONI_INSTANCE_NAME; echo warning >&2")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(buf.String()).To(ContainSubstring("pre-delete: apps/app"))
+		g.Expect(buf.String()).To(ContainSubstring("pre-delete: warning"))
+	})
+
+	t.Run("fails on non-zero exit code", func(t *testing.T) {
+		g := NewWithT(t)
+		buf := new(bytes.Buffer)
+		err := runPreDeleteHook(context.Background(), newLogger(buf), inst, "echo not ready >&2; exit 2")
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("exit status 2"))
+		g.Expect(buf.String()).To(ContainSubstring("pre-delete: not ready"))
+	})
+}
+
+func TestTerminationProgress(t *testing.T) {
+	g := NewWithT(t)
+
+	var objects []*unstructured.Unstructured
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		objects = append(objects, obj)
+	}
+
+	g.Expect(terminationProgress(objects[:2])).To(Equal(
+		"waiting for 2 resource(s) to be finalized: ConfigMap/default/a, ConfigMap/default/b"))
+	g.Expect(terminationProgress(objects)).To(Equal(
+		"waiting for 5 resource(s) to be finalized: ConfigMap/default/a, ConfigMap/default/b, ConfigMap/default/c, and 2 more"))
+}
+
+func TestDelete_Kind(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --kind Deployment --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring("no resources found of kind Deployment"))
+
+	output, err = executeCommand(fmt.Sprintf(
+		"delete -n %s %s --kind configmap --wait --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring("the instance will be partially managed"))
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var inst apiv1.Instance
+	g.Expect(json.Unmarshal(storage.Data["instance"], &inst)).To(Succeed())
+	g.Expect(inst.Inventory.Entries).To(BeEmpty())
+}
+
+func TestDelete_CountOnly(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --count-only",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(Equal("2\n"))
+
+	output, err = executeCommand(fmt.Sprintf(
+		"delete -n %s %s --count-only --kind Deployment",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(Equal("0\n"))
+
+	_, err = executeCommand(fmt.Sprintf(
+		"delete -n %s %s --count-only -o json",
+		namespace,
+		name,
+	))
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"delete -n %s %s --count-only",
+		namespace,
+		rnd("my-instance", 5),
+	))
+	g.Expect(err).To(HaveOccurred())
+
+	output, err = executeCommand(fmt.Sprintf(
+		"delete -n %s %s --count-only --ignore-not-found",
+		namespace,
+		rnd("my-instance", 5),
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(Equal("0\n"))
+
+	output, err = executeCommand(fmt.Sprintf(
+		"delete -A %s --count-only --ignore-not-found",
+		rnd("my-instance", 5),
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(Equal("0\n"))
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(cm), cm)
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestDelete_ExpectDigest(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var inst apiv1.Instance
+	g.Expect(json.Unmarshal(storage.Data["instance"], &inst)).To(Succeed())
+	digest := (&runtime.InstanceManager{Instance: inst}).Digest()
+
+	_, err = executeCommand(fmt.Sprintf(
+		"delete -n %s %s --expect-digest sha256:0000 --wait --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("instance digest mismatch"))
+
+	var exitErr *exitCodeError
+	g.Expect(errors.As(err, &exitErr)).To(BeTrue())
+	g.Expect(exitErr.code).To(Equal(3))
+
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --expect-digest %s --wait --yes",
+		namespace,
+		name,
+		strings.TrimPrefix(digest, "sha256:"),
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+}
+
+func TestDelete_PreserveClusterResources(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommandWithIn(fmt.Sprintf(
+		"apply -n %s %s %s -f- -p main --wait",
+		namespace,
+		name,
+		modPath,
+	), strings.NewReader("values: ns: enabled: true"))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	output, err := executeCommand(fmt.Sprintf(
+		"delete -n %s %s --include-cluster-resources=false --wait --yes",
+		namespace,
+		name,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("Namespace/%s-ns preserved (cluster-scoped)", name)))
+	g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+	g.Expect(output).To(ContainSubstring("Preserved: 1"))
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-ns", name)}}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(ns), ns)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var inst apiv1.Instance
+	g.Expect(json.Unmarshal(storage.Data["instance"], &inst)).To(Succeed())
+	g.Expect(inst.Inventory.Entries).To(HaveLen(1))
+	g.Expect(inst.Inventory.Entries[0].ID).To(ContainSubstring("_Namespace"))
+}
+
+func TestDelete_ClusterContext(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	storage := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("timoni.%s", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(storage), storage)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var inst apiv1.Instance
+	g.Expect(json.Unmarshal(storage.Data["instance"], &inst)).To(Succeed())
+	current, err := runtime.KubeContext(kubeconfigArgs)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(inst.ClusterContext).To(Equal(current))
+
+	t.Run("falls back to the current context if the recorded one is not found", func(t *testing.T) {
+		g := NewWithT(t)
+		inst.ClusterContext = "other-cluster"
+		data, err := json.Marshal(inst)
+		g.Expect(err).ToNot(HaveOccurred())
+		storage.Data["instance"] = data
+		g.Expect(envTestClient.Update(context.Background(), storage)).To(Succeed())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"delete -n %s %s --wait --yes",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf(
+			"the context other-cluster the instance was applied with is not in the kubeconfig, using the current context %s", current)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server deleted", namespace, name)))
+	})
+}
@@ -0,0 +1,327 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/flags"
+	"github.com/stefanprodan/timoni/internal/runtime"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [INSTANCE NAME] [MODULE URL]",
+	Short: "Compare a live instance with the objects built from a module version",
+	Long: `The diff command builds the module with the given values and compares the result
+with the live objects of an existing instance, using a server-side apply dry run.
+The objects that would be created, configured or pruned are reported, and no change
+is made to the cluster.`,
+	Example: `  # Compare an instance with a new module version
+  timoni diff -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --values ./values.cue
+
+  # Compare an instance with a local module ignoring the annotations
+  timoni diff -n apps app ./path/to/module \
+  --values ./values.cue \
+  --diff-ignore 'metadata.annotations.*'
+
+  # Exit with code 2 if the instance would be changed
+  timoni diff -n apps app ./path/to/module --exit-code
+`,
+	RunE: runDiffCmd,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeInstanceList(cmd, args, toComplete)
+		case 1:
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	},
+}
+
+type diffFlags struct {
+	name        string
+	module      string
+	version     flags.Version
+	pkg         flags.Package
+	tags        flags.Tags
+	valuesFiles []string
+	namePrefix  string
+	creds       flags.Credentials
+	output      string
+	ignore      []string
+	changes     []string
+	names       []string
+	kinds       []string
+	showSecrets bool
+	context     int
+	summary     bool
+	archive     string
+	showMerged  bool
+	tmpDir      string
+	source      string
+	ownedFields bool
+	keepTimoni  bool
+	exitCode    bool
+	color       string
+}
+
+var diffArgs diffFlags
+
+func init() {
+	diffCmd.Flags().VarP(&diffArgs.version, diffArgs.version.Type(), diffArgs.version.Shorthand(), diffArgs.version.Description())
+	diffCmd.Flags().VarP(&diffArgs.pkg, diffArgs.pkg.Type(), diffArgs.pkg.Shorthand(), diffArgs.pkg.Description())
+	diffCmd.Flags().Var(&diffArgs.tags, diffArgs.tags.Type(), diffArgs.tags.Description())
+	diffCmd.Flags().StringSliceVarP(&diffArgs.valuesFiles, "values", "f", nil,
+		"The local path to values files (cue, yaml or json format).")
+	diffCmd.Flags().StringVar(&diffArgs.namePrefix, "name-prefix", "",
+		"Prefix the names of the generated resources, their references and their app.kubernetes.io/name and instance labels and selectors, when set without a value the instance name is used as prefix.")
+	diffCmd.Flags().Lookup("name-prefix").NoOptDefVal = namePrefixInstance
+	diffCmd.Flags().Var(&diffArgs.creds, diffArgs.creds.Type(), diffArgs.creds.Description())
+	diffCmd.Flags().StringVar(&diffArgs.output, "diff-output", DyffHumanFormat,
+		"Print the diff in the specified format, can be 'human', 'json' or 'markdown'.")
+	diffCmd.Flags().StringVar(&diffArgs.source, "diff-source", DiffSourceLive,
+		"Compare the module with the specified source, can be 'live' or 'last-applied'. "+
+			"The last applied state is built from the module and values recorded in the instance storage, without querying the live objects.")
+	diffCmd.Flags().StringSliceVar(&diffArgs.changes, "diff-changes", nil,
+		"Print only the changes of the specified kinds, can be 'additions', 'removals', 'modifications', 'order-changes' or 'all'.")
+	diffCmd.Flags().StringSliceVar(&diffArgs.names, "diff-name", nil,
+		"Compare only the resources with the specified names, the other resources are reported as skipped.")
+	diffCmd.Flags().StringSliceVar(&diffArgs.kinds, "diff-kind", nil,
+		"Compare only the resources of the specified kinds e.g. 'Deployment', the other resources are reported as skipped.")
+	diffCmd.Flags().BoolVar(&diffArgs.showSecrets, "show-secrets", false,
+		"Print the values of the Secrets in the diff, by default the values are redacted. Use this flag only for local debugging.")
+	diffCmd.Flags().IntVar(&diffArgs.context, "diff-context", -1,
+		"Print only the specified number of unchanged lines around the changes of multiline text values. When negative, the whole text is printed.")
+	diffCmd.Flags().BoolVar(&diffArgs.summary, "diff-summary", false,
+		"Print the number of added, removed and modified fields of each resource instead of the full diff.")
+	diffCmd.Flags().StringVar(&diffArgs.archive, "diff-archive", "",
+		"Write a copy of the diff without colors to the specified file, while printing it to stdout.")
+	diffCmd.Flags().BoolVar(&diffArgs.showMerged, "diff-show-merged", false,
+		"Print the full merged object of each configured resource after its diff.")
+	diffCmd.Flags().StringArrayVar(&diffArgs.ignore, "diff-ignore", nil,
+		"Ignore the changes of the fields at the specified path, in the dot format e.g. 'metadata.annotations.*' or the JSON pointer format e.g. '/status'. This flag can be repeated.")
+	diffCmd.Flags().BoolVar(&diffArgs.ownedFields, "diff-owned-fields", false,
+		"Print the diff of the fields owned by Timoni, ignoring the fields set by the API server or by other controllers.")
+	diffCmd.Flags().BoolVar(&diffArgs.keepTimoni, "diff-keep-timoni-metadata", false,
+		"Print the changes of the labels and annotations injected by Timoni e.g. 'instance.timoni.sh/name', by default they are ignored.")
+	diffCmd.Flags().BoolVar(&diffArgs.exitCode, "exit-code", false,
+		"Exit with code 2 if any of the resources would be created, configured or deleted.")
+	diffCmd.Flags().StringVar(&diffArgs.tmpDir, "diff-tmp-dir", "",
+		"The directory where the temporary files of the module build and diff are written. Defaults to the system temporary directory, the directory must be writable.")
+	diffCmd.Flags().StringVar(&diffArgs.color, "color", "",
+		"Colorize the diff, can be 'auto', 'always' or 'never'. When not specified, the DYFF_COLOR environment variable is used and defaults to 'auto'.")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiffCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return errors.New("name and module are required")
+	}
+
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	diffArgs.name = name
+	diffArgs.module = args[1]
+
+	if err := validateDiffSource(diffArgs.source); err != nil {
+		return err
+	}
+
+	onlyChanges, err := parseDiffChanges(diffArgs.changes)
+	if err != nil {
+		return err
+	}
+
+	ignorePaths, err := parseDiffIgnorePaths(diffArgs.ignore)
+	if err != nil {
+		return err
+	}
+
+	if diffArgs.output == "" {
+		diffArgs.output = DyffHumanFormat
+	}
+	if err := validateDyffFormat(diffArgs.output); err != nil {
+		return err
+	}
+	if diffArgs.summary && diffArgs.output == DyffJSONFormat {
+		return fmt.Errorf("diff summary and JSON diff output are mutually exclusive")
+	}
+	if diffArgs.showMerged && diffArgs.output == DyffJSONFormat {
+		return fmt.Errorf("diff show merged and JSON diff output are mutually exclusive")
+	}
+	if err := validateColorMode(diffArgs.color); err != nil {
+		return err
+	}
+	if err := validateTmpDir(diffArgs.tmpDir); err != nil {
+		return err
+	}
+
+	log := LoggerInstance(cmd.Context(), diffArgs.name)
+
+	version := diffArgs.version.String()
+	if version == "" {
+		version = apiv1.LatestVersion
+	}
+
+	if strings.HasPrefix(diffArgs.module, apiv1.ArtifactPrefix) {
+		log.Info(fmt.Sprintf("pulling %s:%s", diffArgs.module, version))
+	} else {
+		log.Info(fmt.Sprintf("building %s", diffArgs.module))
+	}
+
+	tmpDir, err := os.MkdirTemp(diffArgs.tmpDir, apiv1.FieldManager)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var valuesCue [][]byte
+	if len(diffArgs.valuesFiles) > 0 {
+		valuesCue, err = convertToCue(cmd, diffArgs.valuesFiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	kubeVersion, err := runtime.ServerVersion(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	ctxPull, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	objects, err := buildModuleVersion(ctxPull, moduleVersionBuild{
+		description: "desired",
+		name:        diffArgs.name,
+		namespace:   *kubeconfigArgs.Namespace,
+		repository:  diffArgs.module,
+		version:     version,
+		pkg:         diffArgs.pkg.String(),
+		tags:        diffArgs.tags,
+		values:      valuesCue,
+		creds:       diffArgs.creds.String(),
+		kubeVersion: kubeVersion,
+		dir:         filepath.Join(tmpDir, "module"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if diffArgs.namePrefix != "" {
+		runtime.PrefixNames(objects, namePrefix(diffArgs.namePrefix, diffArgs.name))
+	}
+
+	rm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	rm.SetOwnerLabels(objects, diffArgs.name, *kubeconfigArgs.Namespace)
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), rootArgs.timeout)
+	defer cancel()
+
+	sm := runtime.NewStorageManager(rm)
+	instance, err := sm.Get(ctx, diffArgs.name, *kubeconfigArgs.Namespace)
+	if err != nil {
+		return fmt.Errorf("instance %s not found in namespace %s: %w", diffArgs.name, *kubeconfigArgs.Namespace, err)
+	}
+
+	diffOpts := dryRunDiffOptions{
+		withDiff:           true,
+		format:             diffArgs.output,
+		onlyChanges:        onlyChanges,
+		ignorePaths:        ignorePaths,
+		ownedFieldsOnly:    diffArgs.ownedFields,
+		keepTimoniMetadata: diffArgs.keepTimoni,
+		color:              diffArgs.color,
+		names:              diffArgs.names,
+		kinds:              diffArgs.kinds,
+		showSecrets:        diffArgs.showSecrets,
+		summary:            diffArgs.summary,
+		showMerged:         diffArgs.showMerged,
+		tmpDir:             tmpDir,
+	}
+	if diffArgs.context >= 0 {
+		diffOpts.contextLines = &diffArgs.context
+	}
+	if diffArgs.archive != "" {
+		archive, err := os.Create(diffArgs.archive)
+		if err != nil {
+			return fmt.Errorf("failed to create the diff archive: %w", err)
+		}
+		defer archive.Close()
+		diffOpts.output = NewDiffWriter(rootCmd.OutOrStdout()).AddPlain(diffArgs.archive, archive)
+	}
+
+	if diffArgs.source == DiffSourceLastApplied {
+		lastApplied, err := buildLastApplied(ctxPull, instance, diffArgs.pkg.String(), diffArgs.tags, diffArgs.creds.String(), kubeVersion, tmpDir)
+		if err != nil {
+			return err
+		}
+		if diffArgs.namePrefix != "" {
+			runtime.PrefixNames(lastApplied, namePrefix(diffArgs.namePrefix, diffArgs.name))
+		}
+		rm.SetOwnerLabels(lastApplied, diffArgs.name, *kubeconfigArgs.Namespace)
+
+		changes, err := versionDiff(logr.NewContext(ctx, log), lastApplied, objects, "last applied", version, diffOpts)
+		if err != nil {
+			return err
+		}
+		return driftExitCode(diffArgs.exitCode, changes)
+	}
+
+	nsExists, err := sm.NamespaceExists(ctx, *kubeconfigArgs.Namespace)
+	if err != nil {
+		return fmt.Errorf("instance init failed: %w", err)
+	}
+
+	// The instance is used only to compute the objects that would be pruned,
+	// AddObjects sorts the given slice so a copy is passed.
+	im := runtime.NewInstanceManager(diffArgs.name, *kubeconfigArgs.Namespace, "", apiv1.ModuleReference{})
+	if err := im.AddObjects(append([]*unstructured.Unstructured{}, objects...)); err != nil {
+		return fmt.Errorf("adding objects to instance failed: %w", err)
+	}
+
+	staleObjects, err := sm.GetStaleObjects(ctx, &im.Instance)
+	if err != nil {
+		return fmt.Errorf("getting stale objects failed: %w", err)
+	}
+
+	changes, err := instanceDryRunDiff(logr.NewContext(ctx, log), rm, objects, staleObjects, nsExists, diffOpts)
+	if err != nil {
+		return err
+	}
+
+	return driftExitCode(diffArgs.exitCode, changes)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDiff(t *testing.T) {
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	t.Run("fails for missing instance", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"diff -n %s %s %s -p main",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("not found"))
+	})
+
+	t.Run("reports the changes without applying", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait=false",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"diff -n %s %s %s -p main -f testdata/module-values/example.com.cue",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server configured", namespace, name)))
+		g.Expect(output).To(ContainSubstring("example.com"))
+
+		serverCM := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-server", name),
+				Namespace: namespace,
+			},
+		}
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(serverCM.Data["hostname"]).To(Equal("example.internal"))
+	})
+
+	t.Run("ignores the changes of the given paths", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"diff -n %s %s %s -p main -f testdata/module-values/example.com.cue --diff-ignore data --diff-ignore metadata.annotations --exit-code",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("archives the diff to a file", func(t *testing.T) {
+		g := NewWithT(t)
+		archive := filepath.Join(t.TempDir(), "diff.txt")
+		output, err := executeCommand(fmt.Sprintf(
+			"diff -n %s %s %s -p main -f testdata/module-values/example.com.cue --diff-archive %s",
+			namespace,
+			name,
+			modPath,
+			archive,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("example.com"))
+
+		data, err := os.ReadFile(archive)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring("example.com"))
+		g.Expect(string(data)).ToNot(ContainSubstring("\x1b["))
+	})
+
+	t.Run("prints the merged objects", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"diff -n %s %s %s -p main -f testdata/module-values/example.com.cue --diff-show-merged",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("# merged ConfigMap/%s/%s-server\n---\n", namespace, name)))
+		g.Expect(output).To(ContainSubstring("hostname: example.com"))
+	})
+
+	t.Run("exits with code 2 on changes", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"diff -n %s %s %s -p main -f testdata/module-values/example.com.cue --exit-code",
+			namespace,
+			name,
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("drift detected"))
+	})
+}
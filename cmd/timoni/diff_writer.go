@@ -0,0 +1,101 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gonvenience/bunt"
+)
+
+// DiffWriter writes the diff reports to multiple sinks e.g. the terminal and an
+// archive file, so that the objects are compared only once for all the outputs.
+type DiffWriter struct {
+	sinks []diffSink
+}
+
+// diffSink is an output of a DiffWriter.
+type diffSink struct {
+	name  string
+	w     io.Writer
+	plain bool
+}
+
+// NewDiffWriter returns a DiffWriter targeting the given writers. The sinks are
+// named after the files they write to, or after their position in the list.
+func NewDiffWriter(writers ...io.Writer) *DiffWriter {
+	d := &DiffWriter{}
+	for i, w := range writers {
+		name := fmt.Sprintf("output #%d", i+1)
+		if f, ok := w.(*os.File); ok {
+			name = f.Name()
+		}
+		d.sinks = append(d.sinks, diffSink{name: name, w: w})
+	}
+	return d
+}
+
+// AddPlain adds a sink which receives the reports without the color escape
+// sequences, e.g. a file archiving the diff printed to the terminal.
+func (d *DiffWriter) AddPlain(name string, w io.Writer) *DiffWriter {
+	d.sinks = append(d.sinks, diffSink{name: name, w: w, plain: true})
+	return d
+}
+
+// Write writes the given data to all sinks, the error of the
+// first sink that fails is returned prefixed with the sink name.
+func (d *DiffWriter) Write(p []byte) (int, error) {
+	var plain []byte
+	for _, sink := range d.sinks {
+		data := p
+		if sink.plain {
+			if plain == nil {
+				plain = []byte(bunt.RemoveAllEscapeSequences(string(p)))
+			}
+			data = plain
+		}
+		if _, err := sink.w.Write(data); err != nil {
+			return 0, fmt.Errorf("writing the diff to %s failed: %w", sink.name, err)
+		}
+	}
+	return len(p), nil
+}
+
+// Flush flushes the sinks which are buffered, so that the DiffWriter
+// streams the report of each object when used with DyffPrinter.
+func (d *DiffWriter) Flush() error {
+	for _, sink := range d.sinks {
+		if f, ok := sink.w.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				return fmt.Errorf("flushing the diff to %s failed: %w", sink.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// IsTerminal returns true if any of the sinks which keep the colors is a terminal.
+func (d *DiffWriter) IsTerminal() bool {
+	for _, sink := range d.sinks {
+		if !sink.plain && isTerminal(sink.w) {
+			return true
+		}
+	}
+	return false
+}
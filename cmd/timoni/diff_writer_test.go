@@ -0,0 +1,77 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// failingWriter is an io.Writer which always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestDiffWriter(t *testing.T) {
+	t.Run("writes to all sinks", func(t *testing.T) {
+		g := NewWithT(t)
+		terminal, archive := new(bytes.Buffer), new(bytes.Buffer)
+		w := NewDiffWriter(terminal).AddPlain("archive", archive)
+
+		n, err := w.Write([]byte("\x1b[1mdata.port\x1b[0m\n"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(n).To(Equal(len("\x1b[1mdata.port\x1b[0m\n")))
+		g.Expect(terminal.String()).To(Equal("\x1b[1mdata.port\x1b[0m\n"))
+		g.Expect(archive.String()).To(Equal("data.port\n"))
+	})
+
+	t.Run("reports the failed sink", func(t *testing.T) {
+		g := NewWithT(t)
+		w := NewDiffWriter(new(bytes.Buffer)).AddPlain("diff.txt", failingWriter{})
+
+		_, err := w.Write([]byte("data.port\n"))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(Equal("writing the diff to diff.txt failed: disk full"))
+
+		_, err = NewDiffWriter(new(bytes.Buffer), failingWriter{}).Write([]byte("data.port\n"))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("output #2"))
+	})
+
+	t.Run("prints the YAML diff once to all outputs", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := t.TempDir()
+		liveFile := filepath.Join(dir, "live.yaml")
+		mergedFile := filepath.Join(dir, "merged.yaml")
+		g.Expect(os.WriteFile(liveFile, []byte("data:\n  port: \"8080\"\n"), 0o600)).To(Succeed())
+		g.Expect(os.WriteFile(mergedFile, []byte("data:\n  port: \"9090\"\n"), 0o600)).To(Succeed())
+
+		stdout, archive := new(bytes.Buffer), new(bytes.Buffer)
+		err := diffYAML(context.Background(), liveFile, mergedFile, NewDyffPrinter(), stdout, archive)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(stdout.String()).To(ContainSubstring("9090"))
+		g.Expect(archive.String()).To(Equal(stdout.String()))
+	})
+}
@@ -17,31 +17,123 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/fluxcd/pkg/ssa"
+	"github.com/gonvenience/bunt"
 	"github.com/gonvenience/ytbx"
 	"github.com/homeport/dyff/pkg/dyff"
+	yamlv3 "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/runtime"
 )
 
+const (
+	// DyffHumanFormat prints the dyff reports in the human-readable format.
+	DyffHumanFormat = "human"
+
+	// DyffJSONFormat prints the dyff reports as JSON documents, one per line.
+	DyffJSONFormat = "json"
+
+	// DyffMarkdownFormat prints the dyff reports as GitHub-flavored markdown,
+	// each report is wrapped in a collapsible block without colors.
+	DyffMarkdownFormat = "markdown"
+
+	// DiffSourceLive compares the desired state with the live objects.
+	DiffSourceLive = "live"
+
+	// DiffSourceLastApplied compares the desired state with the objects built
+	// from the module and values recorded in the instance storage.
+	DiffSourceLastApplied = "last-applied"
+
+	// DyffColorEnv is the environment variable holding the default color mode
+	// of the dyff reports, can be 'auto', 'always' or 'never'.
+	DyffColorEnv = "DYFF_COLOR"
+)
+
+// TimoniMetadataPrefixes holds the prefixes of the label and annotation keys injected by
+// Timoni into the managed objects. The keys are removed from the live and merged objects
+// before the comparison, unless the metadata is kept with --diff-keep-timoni-metadata.
+// A prefix also matches the keys scoped with a custom --managed-by value
+// e.g. 'my-tool.instance.timoni.sh/name'.
+var TimoniMetadataPrefixes = []string{
+	fmt.Sprintf("%s.%s/", strings.ToLower(apiv1.InstanceKind), apiv1.GroupVersion.Group),
+	fmt.Sprintf("bundle.%s/", apiv1.GroupVersion.Group),
+}
+
 // DyffPrinter is a printer that prints dyff reports.
 type DyffPrinter struct {
+	// OmitHeader drops the dyff banner with the compared file locations
+	// from the human-readable reports.
 	OmitHeader bool
+
+	// Format is the output format of the reports, can be DyffHumanFormat, DyffJSONFormat
+	// or DyffMarkdownFormat. When empty, the reports are printed in the human-readable format.
+	Format string
+
+	// Subject identifies the compared objects in the summary of the markdown reports
+	// e.g. 'Deployment/apps/podinfo'. When empty, the report locations are used.
+	Subject string
+
+	// IgnoreAdditions drops the added fields from the reports.
+	IgnoreAdditions bool
+
+	// IgnoreRemovals drops the removed fields from the reports.
+	IgnoreRemovals bool
+
+	// OnlyChanges restricts the reports to the given dyff change kinds e.g. dyff.REMOVAL.
+	// When empty, all kinds of changes are printed.
+	OnlyChanges []rune
+
+	// ShowSubject prefixes the report of each object with its kind, namespace and name.
+	// The prefix is written only in the human-readable format to a terminal.
+	ShowSubject bool
+
+	// Color is the color mode of the human-readable reports, can be 'auto', 'always' or 'never'.
+	// In the auto mode, the reports are colorized only when written to a terminal
+	// and the NO_COLOR environment variable is not set.
+	Color string
+
+	// ContextLines is the number of unchanged lines printed around the changes
+	// of multiline text values e.g. embedded config files, the other unchanged
+	// lines are elided. When negative, the whole text is printed.
+	ContextLines int
+
+	// Summary prints a line per compared object with the number of added, removed
+	// and modified fields, instead of the report. The change kinds ignored by
+	// the printer are not counted.
+	Summary bool
 }
 
 // NewDyffPrinter returns a new DyffPrinter.
+// The color mode defaults to the value of the DYFF_COLOR environment variable,
+// or to 'auto' if the variable is not set or holds an unsupported mode.
 func NewDyffPrinter() *DyffPrinter {
+	color := os.Getenv(DyffColorEnv)
+	if color == "" || validateColorMode(color) != nil {
+		color = colorAuto
+	}
 	return &DyffPrinter{
-		OmitHeader: true,
+		OmitHeader:   true,
+		Format:       DyffHumanFormat,
+		ShowSubject:  true,
+		Color:        color,
+		ContextLines: -1,
 	}
 }
 
@@ -50,14 +142,47 @@ func (p *DyffPrinter) Print(w io.Writer, args ...interface{}) error {
 	for _, arg := range args {
 		switch arg := arg.(type) {
 		case dyff.Report:
-			reportWriter := &dyff.HumanReport{
-				Report:     arg,
-				OmitHeader: p.OmitHeader,
+			if p.Summary {
+				if err := p.printSummary(w, arg); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var reportWriter dyff.ReportWriter
+			switch p.Format {
+			case DyffHumanFormat, "":
+				// The dyff library reads the color setting from the bunt package,
+				// which detects the terminal on stdout instead of the given writer.
+				if colorEnabled(p.Color, w) {
+					bunt.SetColorSettings(bunt.ON, bunt.AUTO)
+				} else {
+					bunt.SetColorSettings(bunt.OFF, bunt.OFF)
+				}
+				reportWriter = &dyff.HumanReport{
+					Report:     p.filter(arg),
+					OmitHeader: p.OmitHeader,
+				}
+			case DyffJSONFormat:
+				reportWriter = &jsonReport{Report: p.filter(arg)}
+			case DyffMarkdownFormat:
+				bunt.SetColorSettings(bunt.OFF, bunt.OFF)
+				reportWriter = &markdownReport{Report: p.filter(arg), Subject: p.Subject}
+			default:
+				return fmt.Errorf("unsupported format %s", p.Format)
 			}
 
 			if err := reportWriter.WriteReport(w); err != nil {
 				return fmt.Errorf("failed to print report: %w", err)
 			}
+
+			// Flush the report of each object, so that the diff of large instances
+			// is streamed to a pager while the remaining objects are compared.
+			if f, ok := w.(flusher); ok {
+				if err := f.Flush(); err != nil {
+					return fmt.Errorf("failed to flush report: %w", err)
+				}
+			}
 		default:
 			return fmt.Errorf("unsupported type %T", arg)
 		}
@@ -65,51 +190,857 @@ func (p *DyffPrinter) Print(w io.Writer, args ...interface{}) error {
 	return nil
 }
 
-func diffYAML(liveFile, mergedFile string, output io.Writer) error {
+// printSummary writes the diff statistics of each object in the given report,
+// one line per object in the format '<kind>/<namespace>/<name>: <stats>'.
+func (p *DyffPrinter) printSummary(w io.Writer, report dyff.Report) error {
+	stats := ReportSummary(p.filter(report))
+	subjects := make([]string, 0, len(stats))
+	for subject := range stats {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+
+	for _, subject := range subjects {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", subject, stats[subject]); err != nil {
+			return fmt.Errorf("failed to print summary: %w", err)
+		}
+	}
+	return nil
+}
+
+// DiffStats holds the number of changes of an object, by dyff change kind.
+type DiffStats struct {
+	Added        int `json:"added"`
+	Removed      int `json:"removed"`
+	Modified     int `json:"modified"`
+	OrderChanged int `json:"orderChanged"`
+}
+
+// String returns the statistics in the format '3 modified, 1 added, 0 removed',
+// the order changes are listed only when present.
+func (s DiffStats) String() string {
+	result := fmt.Sprintf("%d modified, %d added, %d removed", s.Modified, s.Added, s.Removed)
+	if s.OrderChanged > 0 {
+		result += fmt.Sprintf(", %d order changed", s.OrderChanged)
+	}
+	return result
+}
+
+// ReportSummary counts the changes of the given dyff report by object.
+// The objects are identified in the format '<kind>/<namespace>/<name>',
+// and only the objects with changes are included in the result.
+func ReportSummary(report dyff.Report) map[string]DiffStats {
+	result := make(map[string]DiffStats)
+	for _, diff := range report.Diffs {
+		subject := reportSubject(report, diff.Path)
+		stats := result[subject]
+		for _, detail := range diff.Details {
+			switch detail.Kind {
+			case dyff.ADDITION:
+				stats.Added++
+			case dyff.REMOVAL:
+				stats.Removed++
+			case dyff.MODIFICATION:
+				stats.Modified++
+			case dyff.ORDERCHANGE:
+				stats.OrderChanged++
+			}
+		}
+		result[subject] = stats
+	}
+	return result
+}
+
+// reportSubject returns the kind, namespace and name of the object the given
+// path points to, read from the document of the report target or source.
+func reportSubject(report dyff.Report, path *ytbx.Path) string {
+	idx := 0
+	if path != nil {
+		idx = path.DocumentIdx
+	}
+
+	var meta struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+	}
+	for _, input := range []ytbx.InputFile{report.To, report.From} {
+		if idx < len(input.Documents) && input.Documents[idx].Decode(&meta) == nil && meta.Kind != "" {
+			obj := &unstructured.Unstructured{}
+			obj.SetKind(meta.Kind)
+			obj.SetName(meta.Metadata.Name)
+			obj.SetNamespace(meta.Metadata.Namespace)
+			return ssa.FmtUnstructured(obj)
+		}
+	}
+	return fmt.Sprintf("document #%d", idx)
+}
+
+// flusher is implemented by the buffered writers e.g. bufio.Writer.
+type flusher interface {
+	Flush() error
+}
+
+// filter returns a copy of the report without the change kinds ignored by the printer,
+// the differences left without details are removed from the report.
+func (p *DyffPrinter) filter(report dyff.Report) dyff.Report {
+	if !p.IgnoreAdditions && !p.IgnoreRemovals && len(p.OnlyChanges) == 0 && p.ContextLines < 0 {
+		return report
+	}
+
+	result := dyff.Report{From: report.From, To: report.To}
+	for _, diff := range report.Diffs {
+		var details []dyff.Detail
+		for _, detail := range diff.Details {
+			if (p.IgnoreAdditions && detail.Kind == dyff.ADDITION) ||
+				(p.IgnoreRemovals && detail.Kind == dyff.REMOVAL) ||
+				(len(p.OnlyChanges) > 0 && !slices.Contains(p.OnlyChanges, detail.Kind)) {
+				continue
+			}
+			if p.ContextLines >= 0 && detail.Kind == dyff.MODIFICATION {
+				detail = trimDetailContext(detail, p.ContextLines)
+			}
+			details = append(details, detail)
+		}
+		if len(details) > 0 {
+			result.Diffs = append(result.Diffs, dyff.Diff{Path: diff.Path, Details: details})
+		}
+	}
+	return result
+}
+
+// trimDetailContext returns a copy of the given modification detail where the
+// unchanged lines of multiline text values are elided, except for the given
+// number of context lines before and after the changed lines.
+func trimDetailContext(detail dyff.Detail, contextLines int) dyff.Detail {
+	from, to := detail.From, detail.To
+	if from == nil || to == nil ||
+		from.Kind != yamlv3.ScalarNode || to.Kind != yamlv3.ScalarNode ||
+		!strings.Contains(from.Value, "\n") || !strings.Contains(to.Value, "\n") {
+		return detail
+	}
+
+	fromText, toText := trimTextContext(from.Value, to.Value, contextLines)
+	if fromText == from.Value && toText == to.Value {
+		return detail
+	}
+
+	trimmedFrom, trimmedTo := *from, *to
+	trimmedFrom.Value, trimmedTo.Value = fromText, toText
+	detail.From, detail.To = &trimmedFrom, &trimmedTo
+	return detail
+}
+
+// trimTextContext elides the unchanged lines at the start and at the end of the
+// given texts, keeping the given number of context lines around the changed lines.
+// The elided lines are replaced in both texts with a marker holding their count.
+func trimTextContext(from, to string, contextLines int) (string, string) {
+	fromLines := strings.Split(from, "\n")
+	toLines := strings.Split(to, "\n")
+	maxEqual := min(len(fromLines), len(toLines))
+
+	prefix := 0
+	for prefix < maxEqual && fromLines[prefix] == toLines[prefix] {
+		prefix++
+	}
+	if prefix == maxEqual && len(fromLines) == len(toLines) {
+		return from, to
+	}
+
+	suffix := 0
+	for suffix < maxEqual-prefix &&
+		fromLines[len(fromLines)-1-suffix] == toLines[len(toLines)-1-suffix] {
+		suffix++
+	}
+
+	head := max(0, prefix-contextLines)
+	tail := max(0, suffix-contextLines)
+	trim := func(lines []string) string {
+		var result []string
+		if head > 0 {
+			result = append(result, fmt.Sprintf("... (%d unchanged lines)", head))
+		}
+		result = append(result, lines[head:len(lines)-tail]...)
+		if tail > 0 {
+			result = append(result, fmt.Sprintf("... (%d unchanged lines)", tail))
+		}
+		return strings.Join(result, "\n")
+	}
+
+	if head == 0 && tail == 0 {
+		return from, to
+	}
+	return trim(fromLines), trim(toLines)
+}
+
+// jsonReport is a dyff.ReportWriter which writes the report as a JSON document.
+type jsonReport struct {
+	Report dyff.Report
+}
+
+// jsonReportDiff holds a change of the JSON report.
+type jsonReportDiff struct {
+	// Path is the GoPatch style path of the changed field e.g. '/data/port'.
+	Path string `json:"path"`
+	// Kind can be 'addition', 'removal', 'modification' or 'order-change'.
+	Kind string      `json:"kind"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// WriteReport writes the report as a single line JSON document.
+func (r *jsonReport) WriteReport(out io.Writer) error {
+	diffs := make([]jsonReportDiff, 0, len(r.Report.Diffs))
+	for _, diff := range r.Report.Diffs {
+		path := "/"
+		if diff.Path != nil {
+			path = diff.Path.ToGoPatchStyle()
+		}
+		for _, detail := range diff.Details {
+			entry := jsonReportDiff{Path: path, Kind: dyffKindName(detail.Kind)}
+			if detail.From != nil {
+				if err := detail.From.Decode(&entry.From); err != nil {
+					return fmt.Errorf("failed to decode %s: %w", path, err)
+				}
+			}
+			if detail.To != nil {
+				if err := detail.To.Decode(&entry.To); err != nil {
+					return fmt.Errorf("failed to decode %s: %w", path, err)
+				}
+			}
+			diffs = append(diffs, entry)
+		}
+	}
+
+	// The compared objects are written to temporary files,
+	// the report holds only the base names of their locations.
+	return json.NewEncoder(out).Encode(struct {
+		From  string           `json:"from"`
+		To    string           `json:"to"`
+		Diffs []jsonReportDiff `json:"diffs"`
+	}{
+		From:  filepath.Base(r.Report.From.Location),
+		To:    filepath.Base(r.Report.To.Location),
+		Diffs: diffs,
+	})
+}
+
+// markdownReport is a dyff.ReportWriter which writes the human-readable report
+// in a collapsible GitHub-flavored markdown block, with the subject as summary.
+type markdownReport struct {
+	Report  dyff.Report
+	Subject string
+}
+
+// WriteReport writes the report in a details block holding a fenced code block.
+func (r *markdownReport) WriteReport(out io.Writer) error {
+	var buf bytes.Buffer
+	human := &dyff.HumanReport{Report: r.Report, OmitHeader: true}
+	if err := human.WriteReport(&buf); err != nil {
+		return err
+	}
+
+	subject := r.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("%s -> %s", r.Report.From.Location, r.Report.To.Location)
+	}
+
+	_, err := fmt.Fprintf(out, "<details>\n<summary>%s</summary>\n\n```\n%s\n```\n\n</details>\n",
+		html.EscapeString(subject), strings.Trim(buf.String(), "\n"))
+	return err
+}
+
+// dyffKindName returns the name of the given dyff detail kind.
+func dyffKindName(kind rune) string {
+	switch kind {
+	case dyff.ADDITION:
+		return "addition"
+	case dyff.REMOVAL:
+		return "removal"
+	case dyff.MODIFICATION:
+		return "modification"
+	case dyff.ORDERCHANGE:
+		return "order-change"
+	default:
+		return string(kind)
+	}
+}
+
+// validateDyffFormat returns an error if the given format is not supported by DyffPrinter.
+func validateDyffFormat(format string) error {
+	switch format {
+	case DyffHumanFormat, DyffJSONFormat, DyffMarkdownFormat:
+		return nil
+	default:
+		return fmt.Errorf("unsupported diff output '%s', can be '%s', '%s' or '%s'",
+			format, DyffHumanFormat, DyffJSONFormat, DyffMarkdownFormat)
+	}
+}
+
+// validateTmpDir returns an error if a file can't be created in the given directory,
+// or in the system temporary directory when empty, so that the commands fail before
+// building the module, e.g. on CI runners with a read-only temporary directory.
+func validateTmpDir(dir string) error {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	f, err := os.CreateTemp(dir, "."+apiv1.FieldManager+"-")
+	if err != nil {
+		return fmt.Errorf("temporary directory %s is not writable, use --diff-tmp-dir to set a writable directory: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// CompareYAML loads the given YAML files and returns the dyff report of their differences,
+// the order changes are reported and the Kubernetes objects are matched by their ID.
+func CompareYAML(liveFile, mergedFile string) (dyff.Report, error) {
 	from, to, err := ytbx.LoadFiles(liveFile, mergedFile)
 	if err != nil {
-		return fmt.Errorf("failed to load input files: %w", err)
+		return dyff.Report{}, fmt.Errorf("failed to load input files: %w", err)
+	}
+
+	return compareInputFiles(from, to)
+}
+
+// diffYAML prints the dyff report of the given YAML files to the given outputs,
+// the files are compared once for all the outputs. The loading and the comparison
+// are bounded by the given context.
+func diffYAML(ctx context.Context, liveFile, mergedFile string, printer *DyffPrinter, outputs ...io.Writer) error {
+	report, err := compareWithContext(ctx, func() (dyff.Report, error) {
+		return CompareYAML(liveFile, mergedFile)
+	})
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = NewDiffWriter(outputs...)
+	if len(outputs) == 1 {
+		output = outputs[0]
 	}
 
+	return printer.Print(output, report)
+}
+
+// compareInputs returns the dyff report of the differences between the given inputs,
+// the order changes are reported and the Kubernetes objects are matched by their ID.
+// The comparison is bounded by the given context.
+func compareInputs(ctx context.Context, from, to ytbx.InputFile) (dyff.Report, error) {
+	return compareWithContext(ctx, func() (dyff.Report, error) {
+		return compareInputFiles(from, to)
+	})
+}
+
+func compareInputFiles(from, to ytbx.InputFile) (dyff.Report, error) {
 	report, err := dyff.CompareInputFiles(from, to,
 		dyff.IgnoreOrderChanges(false),
 		dyff.KubernetesEntityDetection(true),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to compare input files: %w", err)
+		return dyff.Report{}, fmt.Errorf("failed to compare input files: %w", err)
+	}
+	return report, nil
+}
+
+// compareWithContext runs the given comparison and returns its result, or the context
+// error if the context is done before the comparison finishes. The result channel
+// is buffered, so that an abandoned comparison can exit when done.
+func compareWithContext(ctx context.Context, compare func() (dyff.Report, error)) (dyff.Report, error) {
+	type result struct {
+		report dyff.Report
+		err    error
+	}
+
+	if err := ctx.Err(); err != nil {
+		return dyff.Report{}, fmt.Errorf("diff aborted: %w", err)
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		report, err := compare()
+		ch <- result{report: report, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return dyff.Report{}, fmt.Errorf("diff aborted: %w", ctx.Err())
+	case r := <-ch:
+		return r.report, r.err
+	}
+}
+
+// dryRunDiffOptions holds the settings of the instance dry-run diff.
+type dryRunDiffOptions struct {
+	// withDiff enables printing the dyff report of the configured objects.
+	withDiff bool
+
+	// lastApplied holds the objects built from the last applied module and values.
+	// When set, the diff is split in two reports: the drift of the live state
+	// from the last applied state, and the change from the last applied state
+	// to the desired state.
+	lastApplied []*unstructured.Unstructured
+
+	// showManagedFields keeps the metadata.managedFields of the live and merged
+	// objects in the diff, to help debugging server-side apply ownership conflicts.
+	showManagedFields bool
+
+	// onlyActions restricts the reported objects to the ones with the given actions.
+	// When empty, all objects are reported.
+	onlyActions []ssa.Action
+
+	// ignoreAdded drops the added fields from the diff.
+	ignoreAdded bool
+
+	// ignoreRemoved drops the removed fields from the diff.
+	ignoreRemoved bool
+
+	// onlyChanges restricts the diff to the given dyff change kinds.
+	// When empty, all changes are reported.
+	onlyChanges []rune
+
+	// format is the output format of the diff, defaults to DyffHumanFormat.
+	format string
+
+	// ownedFieldsOnly restricts the diff to the fields owned by the Timoni field manager,
+	// the fields set by the API server or by other controllers are removed from
+	// the live and merged objects before the comparison.
+	ownedFieldsOnly bool
+
+	// ignorePaths holds the paths of the fields removed from the
+	// live and merged objects before the comparison.
+	ignorePaths [][]string
+
+	// diffDir is the directory where the diff of each configured object is written
+	// to a separate file. When empty, the diffs are written to the command output.
+	diffDir string
+
+	// color is the color mode of the diff, when empty the DYFF_COLOR environment
+	// variable is used and defaults to 'auto'.
+	color string
+
+	// tmpDir is the directory where the temporary files holding the compared
+	// objects are written. When empty, the system temporary directory is used.
+	tmpDir string
+
+	// keepFilesDir is the directory where the live and merged objects compared
+	// for each configured object are saved as YAML files, for debugging the diff.
+	keepFilesDir string
+
+	// names restricts the compared objects to the ones with the given names.
+	// The other objects are reported as skipped without being compared.
+	names []string
+
+	// kinds restricts the compared objects to the ones of the given kinds, case-insensitive.
+	// The other objects are reported as skipped without being compared.
+	kinds []string
+
+	// showSecrets prints the values of the Secrets in the diff, by default the values
+	// are redacted and the diff reports only the keys that were added, removed or changed.
+	showSecrets bool
+
+	// contextLines is the number of unchanged lines printed around the changes of
+	// multiline text values. When nil, the whole text is printed.
+	contextLines *int
+
+	// summary prints the number of added, removed and modified fields
+	// of each configured object, instead of the full diff.
+	summary bool
+
+	// output is the writer of the diff reports e.g. a DiffWriter archiving
+	// the reports to a file. When nil, the reports are written to stdout.
+	output io.Writer
+
+	// showMerged prints the full merged object of each configured object after its diff,
+	// the object is printed as written to the merged.yaml file by --keep-diff-files.
+	showMerged bool
+
+	// keepTimoniMetadata keeps the labels and annotations matching the TimoniMetadataPrefixes
+	// in the compared objects, by default they are removed from both objects.
+	keepTimoniMetadata bool
+
+	// driftReport records the identity of the objects which differ from the desired state.
+	// When nil, the objects are not recorded.
+	driftReport *driftReport
+}
+
+// driftReport holds the result of an instance dry run, written with --drift-report
+// for the monitoring systems running the dry run on a schedule.
+type driftReport struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// Drifted is true when any of the in-cluster objects differs from the desired state.
+	Drifted bool `json:"drifted"`
+
+	// DriftedObjects lists the objects which would be configured.
+	DriftedObjects []string `json:"driftedObjects"`
+
+	// MissingObjects lists the objects which would be created.
+	MissingObjects []string `json:"missingObjects"`
+
+	// StaleObjects lists the objects which would be deleted from the cluster.
+	StaleObjects []string `json:"staleObjects"`
+}
+
+// newDriftReport returns an empty drift report for the given instance.
+func newDriftReport(name, namespace string) *driftReport {
+	return &driftReport{
+		Name:           name,
+		Namespace:      namespace,
+		DriftedObjects: []string{},
+		MissingObjects: []string{},
+		StaleObjects:   []string{},
+	}
+}
+
+// add records the given object in the list matching the dry run action,
+// the unchanged and skipped objects are not recorded.
+func (r *driftReport) add(obj *unstructured.Unstructured, action ssa.Action) {
+	if r == nil {
+		return
+	}
+	subject := ssa.FmtUnstructured(obj)
+	switch action {
+	case ssa.ConfiguredAction:
+		r.DriftedObjects = append(r.DriftedObjects, subject)
+		r.Drifted = true
+	case ssa.CreatedAction:
+		r.MissingObjects = append(r.MissingObjects, subject)
+	case ssa.DeletedAction:
+		r.StaleObjects = append(r.StaleObjects, subject)
+	}
+}
+
+// write saves the report as an indented JSON document to the given file.
+func (r *driftReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("drift report JSON conversion failed: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write the drift report: %w", err)
+	}
+	return nil
+}
+
+// diffFileName returns the name of the file holding the diff of the given object,
+// in the format '<namespace>_<kind>_<name>.<ext>'. The cluster-scoped objects are
+// prefixed with 'cluster' instead of the namespace, and the characters which are
+// not valid in file names e.g. the colon in RBAC names, are replaced with dashes.
+func diffFileName(obj *unstructured.Unstructured, ext string) string {
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = "cluster"
+	}
+	sanitize := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+				return r
+			default:
+				return '-'
+			}
+		}, s)
+	}
+	return fmt.Sprintf("%s_%s_%s.%s", sanitize(namespace), sanitize(obj.GetKind()), sanitize(obj.GetName()), ext)
+}
+
+// writeDiffInputs saves the given live and merged objects as YAML files in the given
+// directory, named '<namespace>_<kind>_<name>.live.yaml' and '<namespace>_<kind>_<name>.merged.yaml'.
+func writeDiffInputs(dir string, live, merged *unstructured.Unstructured) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for ext, obj := range map[string]*unstructured.Unstructured{
+		"live.yaml":   live,
+		"merged.yaml": merged,
+	} {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, diffFileName(merged, ext)), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDiffFile writes the diff of the given objects to a file in the diff directory.
+func writeDiffFile(ctx context.Context, tmpDir, dir string, live, merged *unstructured.Unstructured, printer *DyffPrinter) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(dir, diffFileName(merged, "diff")))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := diffObjects(ctx, tmpDir, live, merged, printer, file); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// removeIgnoredPaths removes the fields matching the ignored paths from the given object.
+// A path ending with a wildcard matches all the fields of its parent.
+func (o dryRunDiffOptions) removeIgnoredPaths(obj *unstructured.Unstructured) {
+	if obj == nil {
+		return
+	}
+	for _, path := range o.ignorePaths {
+		if path[len(path)-1] == "*" {
+			path = path[:len(path)-1]
+		}
+		unstructured.RemoveNestedField(obj.Object, path...)
+	}
+}
+
+// removeTimoniMetadata removes the labels and annotations injected by Timoni from the
+// given object, unless the Timoni metadata is kept.
+func (o dryRunDiffOptions) removeTimoniMetadata(obj *unstructured.Unstructured) {
+	if obj == nil || o.keepTimoniMetadata {
+		return
+	}
+	for _, field := range []string{"labels", "annotations"} {
+		values, found, err := unstructured.NestedStringMap(obj.Object, "metadata", field)
+		if !found || err != nil {
+			continue
+		}
+		for key := range values {
+			if isTimoniMetadataKey(key) {
+				delete(values, key)
+			}
+		}
+		if len(values) == 0 {
+			unstructured.RemoveNestedField(obj.Object, "metadata", field)
+			continue
+		}
+		_ = unstructured.SetNestedStringMap(obj.Object, values, "metadata", field)
+	}
+}
+
+// isTimoniMetadataKey returns true if the given label or annotation key
+// matches any of the TimoniMetadataPrefixes.
+func isTimoniMetadataKey(key string) bool {
+	for _, prefix := range TimoniMetadataPrefixes {
+		if strings.HasPrefix(key, prefix) || strings.Contains(key, "."+prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDiffIgnorePaths splits the given paths into fields. The paths starting with
+// a slash are in the JSON pointer format e.g. '/metadata/annotations/example.com~1revision',
+// the others are dot separated e.g. 'metadata.annotations.*'.
+func parseDiffIgnorePaths(paths []string) ([][]string, error) {
+	var result [][]string
+	for _, p := range paths {
+		var fields []string
+		if strings.HasPrefix(p, "/") {
+			for _, field := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+				field = strings.ReplaceAll(field, "~1", "/")
+				fields = append(fields, strings.ReplaceAll(field, "~0", "~"))
+			}
+		} else {
+			fields = strings.Split(p, ".")
+		}
+
+		for i, field := range fields {
+			if field == "" || (field == "*" && (i == 0 || i != len(fields)-1)) {
+				return nil, fmt.Errorf("invalid diff ignore path '%s'", p)
+			}
+		}
+		result = append(result, fields)
 	}
+	return result, nil
+}
 
+// printer returns a DyffPrinter configured with the diff filters.
+func (o dryRunDiffOptions) printer() *DyffPrinter {
 	printer := NewDyffPrinter()
-	return printer.Print(output, report)
+	printer.IgnoreAdditions = o.ignoreAdded
+	printer.IgnoreRemovals = o.ignoreRemoved
+	printer.OnlyChanges = o.onlyChanges
+	if o.format != "" {
+		printer.Format = o.format
+	}
+	if o.color != "" {
+		printer.Color = o.color
+	}
+	if o.contextLines != nil {
+		printer.ContextLines = *o.contextLines
+	}
+	printer.Summary = o.summary
+	return printer
+}
+
+// writer returns the writer of the diff reports.
+func (o dryRunDiffOptions) writer() io.Writer {
+	if o.output != nil {
+		return o.output
+	}
+	return rootCmd.OutOrStdout()
+}
+
+// showAction returns true if the objects with the given action should be reported.
+func (o dryRunDiffOptions) showAction(action ssa.Action) bool {
+	if len(o.onlyActions) == 0 {
+		return true
+	}
+	for _, a := range o.onlyActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// selectObject returns true if the given object matches the name and kind filters.
+func (o dryRunDiffOptions) selectObject(obj *unstructured.Unstructured) bool {
+	if len(o.names) > 0 && !slices.Contains(o.names, obj.GetName()) {
+		return false
+	}
+	if len(o.kinds) > 0 && !slices.ContainsFunc(o.kinds, func(kind string) bool {
+		return strings.EqualFold(kind, obj.GetKind())
+	}) {
+		return false
+	}
+	return true
+}
+
+// parseDiffActions converts the given action names to ssa actions,
+// returning an error for unknown names.
+func parseDiffActions(names []string) ([]ssa.Action, error) {
+	supported := []ssa.Action{
+		ssa.CreatedAction,
+		ssa.ConfiguredAction,
+		ssa.UnchangedAction,
+		ssa.DeletedAction,
+		ssa.SkippedAction,
+	}
+
+	var actions []ssa.Action
+	for _, name := range names {
+		found := false
+		for _, action := range supported {
+			if name == action.String() {
+				actions = append(actions, action)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unsupported action '%s', can be one of %v", name, supported)
+		}
+	}
+	return actions, nil
+}
+
+// validateDiffSource returns an error if the given diff source is not supported.
+func validateDiffSource(source string) error {
+	switch source {
+	case "", DiffSourceLive, DiffSourceLastApplied:
+		return nil
+	default:
+		return fmt.Errorf("unsupported diff source '%s', can be '%s' or '%s'", source, DiffSourceLive, DiffSourceLastApplied)
+	}
+}
+
+// diffChangeKinds maps the values of the --diff-changes flag to the dyff change kinds.
+var diffChangeKinds = map[string]rune{
+	"additions":     dyff.ADDITION,
+	"removals":      dyff.REMOVAL,
+	"modifications": dyff.MODIFICATION,
+	"order-changes": dyff.ORDERCHANGE,
+}
+
+// parseDiffChanges returns the dyff change kinds of the given names, which can be
+// 'additions', 'removals', 'modifications', 'order-changes' or 'all'.
+// When 'all' is specified, the result is empty and no change is filtered.
+func parseDiffChanges(names []string) ([]rune, error) {
+	var kinds []rune
+	for _, name := range names {
+		if name == "all" {
+			return nil, nil
+		}
+		kind, ok := diffChangeKinds[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported change kind '%s', can be 'additions', 'removals', 'modifications', 'order-changes' or 'all'", name)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
 }
 
+// instanceDryRunDiff performs a server-side apply dry run of the given objects and
+// prints the changes. It returns the number of objects that would be created,
+// configured or deleted, the objects which fail to diff are counted as changed.
 func instanceDryRunDiff(ctx context.Context,
 	rm *ssa.ResourceManager,
 	objects []*unstructured.Unstructured,
 	staleObjects []*unstructured.Unstructured,
 	nsExists bool,
-	tmpDir string,
-	withDiff bool) error {
+	opts dryRunDiffOptions) (int, error) {
 	log := LoggerFrom(ctx)
 	diffOpts := ssa.DefaultDiffOptions()
 	sort.Sort(ssa.SortableUnstructureds(objects))
 
+	lastApplied := make(map[string]*unstructured.Unstructured, len(opts.lastApplied))
+	for _, obj := range opts.lastApplied {
+		lastApplied[ssa.FmtUnstructured(obj)] = obj
+	}
+
+	changes := 0
 	for _, r := range objects {
+		if !opts.selectObject(r) {
+			if opts.showAction(ssa.SkippedAction) {
+				logJoin(log, r, ssa.SkippedAction, "(diff filter)", dryRunServer)
+			}
+			continue
+		}
+
 		if !nsExists {
-			log.Info(colorizeJoin(r, ssa.CreatedAction, dryRunServer))
+			changes++
+			opts.driftReport.add(r, ssa.CreatedAction)
+			if opts.showAction(ssa.CreatedAction) {
+				logJoin(log, r, ssa.CreatedAction, dryRunServer)
+			}
 			continue
 		}
 
 		change, liveObject, mergedObject, err := rm.Diff(ctx, r, diffOpts)
 		if err != nil {
+			// The objects which can't be diffed, including the ones with
+			// immutable field changes, are counted as changed.
+			changes++
 			if ssa.IsImmutableError(err) {
+				// The objects with immutable field changes differ from the desired state.
+				opts.driftReport.add(r, ssa.ConfiguredAction)
+				showRecreate := true
 				if ssa.AnyInMetadata(r, map[string]string{
 					apiv1.ForceAction: apiv1.EnabledValue,
 				}) {
-					log.Info(colorizeJoin(r, ssa.CreatedAction, dryRunServer))
+					showRecreate = opts.showAction(ssa.CreatedAction)
+					if showRecreate {
+						logJoin(log, r, ssa.CreatedAction, dryRunServer)
+					}
 				} else {
 					log.Error(nil, colorizeJoin(r, "immutable", dryRunServer))
 				}
+
+				if opts.withDiff && showRecreate {
+					if err := recreateDiff(ctx, rm, r, opts); err != nil {
+						return changes, err
+					}
+				}
 			} else {
 				log.Error(err, colorizeUnstructured(r))
 			}
@@ -117,29 +1048,489 @@ func instanceDryRunDiff(ctx context.Context,
 			continue
 		}
 
-		log.Info(colorizeJoin(change, dryRunServer))
-		if withDiff && change.Action == ssa.ConfiguredAction {
-			liveYAML, _ := yaml.Marshal(liveObject)
-			liveFile := filepath.Join(tmpDir, "live.yaml")
-			if err := os.WriteFile(liveFile, liveYAML, 0644); err != nil {
-				return err
+		// The Secret values are masked by the dry-run diff, they are fetched again
+		// unmasked when requested, or redacted before being written to any output.
+		if change.Action == ssa.ConfiguredAction && ssa.IsSecret(r) {
+			if opts.showSecrets {
+				liveObject, mergedObject, err = runtime.DryRunDiffUnmasked(ctx, rm, r)
+				if err != nil {
+					return changes, err
+				}
+			} else {
+				runtime.RedactSecretData(liveObject, mergedObject)
+			}
+		}
+
+		// The objects with changes only in the ignored paths or
+		// in the Timoni metadata are reported as unchanged.
+		if change.Action == ssa.ConfiguredAction && (len(opts.ignorePaths) > 0 || !opts.keepTimoniMetadata) {
+			opts.removeIgnoredPaths(liveObject)
+			opts.removeIgnoredPaths(mergedObject)
+			opts.removeTimoniMetadata(liveObject)
+			opts.removeTimoniMetadata(mergedObject)
+			if equality.Semantic.DeepEqual(liveObject.Object, mergedObject.Object) {
+				change.Action = ssa.UnchangedAction
+			}
+		}
+
+		// The objects with changes only in the fields owned by other managers are reported as unchanged.
+		if change.Action == ssa.ConfiguredAction && opts.ownedFieldsOnly {
+			_, mergedFields, err := runtime.GetManagedFields(ctx, rm, r)
+			if err != nil {
+				return changes, err
+			}
+			owned, err := runtime.OwnedFieldSet(mergedFields)
+			if err != nil {
+				return changes, err
+			}
+			runtime.RemoveUnownedFields(liveObject, owned)
+			runtime.RemoveUnownedFields(mergedObject, owned)
+			if equality.Semantic.DeepEqual(liveObject.Object, mergedObject.Object) {
+				change.Action = ssa.UnchangedAction
+			}
+		}
+
+		if change.Action != ssa.UnchangedAction && change.Action != ssa.SkippedAction {
+			changes++
+		}
+		opts.driftReport.add(r, change.Action)
+
+		if !opts.showAction(change.Action) {
+			continue
+		}
+
+		logJoin(log, change, dryRunServer)
+		if !opts.withDiff {
+			continue
+		}
+
+		// Secrets are excluded from the three-way diff, as their data is masked in the dry-run results.
+		if last, ok := lastApplied[ssa.FmtUnstructured(r)]; ok && change.Action != ssa.CreatedAction && !ssa.IsSecret(r) {
+			if err := threeWayDiff(ctx, rm, last, mergedObject, opts); err != nil {
+				return changes, err
+			}
+			continue
+		}
+
+		if change.Action == ssa.ConfiguredAction {
+			if opts.showManagedFields {
+				liveFields, mergedFields, err := runtime.GetManagedFields(ctx, rm, r)
+				if err != nil {
+					return changes, err
+				}
+				liveObject.SetManagedFields(liveFields)
+				mergedObject.SetManagedFields(mergedFields)
+			}
+
+			if opts.keepFilesDir != "" {
+				if err := writeDiffInputs(opts.keepFilesDir, liveObject, mergedObject); err != nil {
+					return changes, err
+				}
+			}
+
+			if opts.diffDir != "" {
+				if err := writeDiffFile(ctx, opts.tmpDir, opts.diffDir, liveObject, mergedObject, opts.printer()); err != nil {
+					return changes, err
+				}
+				continue
 			}
 
-			mergedYAML, _ := yaml.Marshal(mergedObject)
-			mergedFile := filepath.Join(tmpDir, "merged.yaml")
-			if err := os.WriteFile(mergedFile, mergedYAML, 0644); err != nil {
-				return err
+			if err := diffObjects(ctx, opts.tmpDir, liveObject, mergedObject, opts.printer(), opts.writer()); err != nil {
+				return changes, err
 			}
 
-			if err := diffYAML(liveFile, mergedFile, rootCmd.OutOrStdout()); err != nil {
-				return err
+			if opts.showMerged {
+				if err := printMergedObject(opts.writer(), mergedObject, opts.format); err != nil {
+					return changes, err
+				}
 			}
 		}
 	}
 
 	for _, r := range staleObjects {
-		log.Info(colorizeJoin(r, ssa.DeletedAction, dryRunServer))
+		if !opts.selectObject(r) {
+			continue
+		}
+		changes++
+		opts.driftReport.add(r, ssa.DeletedAction)
+		if opts.showAction(ssa.DeletedAction) {
+			logJoin(log, r, ssa.DeletedAction, dryRunServer)
+		}
+	}
+
+	return changes, nil
+}
+
+// versionDiff prints the changes between the objects built from two versions of a module,
+// without querying the cluster. The objects missing from the from version are reported
+// as created, and the ones missing from the to version as deleted.
+// It returns the number of created, configured and deleted objects.
+func versionDiff(ctx context.Context,
+	fromObjects []*unstructured.Unstructured,
+	toObjects []*unstructured.Unstructured,
+	fromVersion, toVersion string,
+	opts dryRunDiffOptions) (int, error) {
+	log := LoggerFrom(ctx)
+	sort.Sort(ssa.SortableUnstructureds(fromObjects))
+	sort.Sort(ssa.SortableUnstructureds(toObjects))
+	versions := fmt.Sprintf("(%s -> %s)", fromVersion, toVersion)
+
+	from := make(map[string]*unstructured.Unstructured, len(fromObjects))
+	for _, obj := range fromObjects {
+		from[ssa.FmtUnstructured(obj)] = obj
+	}
+
+	changes := 0
+	to := make(map[string]bool, len(toObjects))
+	for _, obj := range toObjects {
+		subject := ssa.FmtUnstructured(obj)
+		to[subject] = true
+
+		if !opts.selectObject(obj) {
+			if opts.showAction(ssa.SkippedAction) {
+				logJoin(log, obj, ssa.SkippedAction, "(diff filter)", versions)
+			}
+			continue
+		}
+
+		action := ssa.ConfiguredAction
+		previous, ok := from[subject]
+		switch {
+		case !ok:
+			action = ssa.CreatedAction
+		case equality.Semantic.DeepEqual(previous.Object, obj.Object):
+			action = ssa.UnchangedAction
+		}
+
+		if action != ssa.UnchangedAction {
+			changes++
+		}
+
+		if !opts.showAction(action) {
+			continue
+		}
+
+		logJoin(log, obj, action, versions)
+		if opts.withDiff && action == ssa.ConfiguredAction {
+			fromObj, toObj := previous, obj
+			if ssa.IsSecret(obj) && !opts.showSecrets {
+				fromObj, toObj = previous.DeepCopy(), obj.DeepCopy()
+				runtime.RedactSecretData(fromObj, toObj)
+			}
+			if err := diffObjects(ctx, opts.tmpDir, fromObj, toObj, opts.printer(), opts.writer()); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	for _, obj := range fromObjects {
+		if to[ssa.FmtUnstructured(obj)] || !opts.selectObject(obj) {
+			continue
+		}
+		changes++
+		if opts.showAction(ssa.DeletedAction) {
+			logJoin(log, obj, ssa.DeletedAction, versions)
+		}
 	}
 
+	return changes, nil
+}
+
+// recreateDiff prints the diff of an object which can't be updated due to immutable field
+// changes, between the live object which would be deleted and the desired object which
+// would be created instead. The fields set by the API server are removed from the live object.
+func recreateDiff(ctx context.Context,
+	rm *ssa.ResourceManager,
+	desired *unstructured.Unstructured,
+	opts dryRunDiffOptions) error {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(desired.GroupVersionKind())
+	if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(desired), live); err != nil {
+		return fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(desired), err)
+	}
+	for _, field := range []string{"managedFields", "uid", "resourceVersion", "generation", "creationTimestamp"} {
+		unstructured.RemoveNestedField(live.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(live.Object, "status")
+
+	recreated := desired.DeepCopy()
+	if ssa.IsSecret(desired) && !opts.showSecrets {
+		runtime.RedactSecretData(live, recreated)
+	}
+	opts.removeIgnoredPaths(live)
+	opts.removeIgnoredPaths(recreated)
+
+	if opts.diffDir != "" {
+		return writeDiffFile(ctx, opts.tmpDir, opts.diffDir, live, recreated, opts.printer())
+	}
+
+	// The report is prefixed with its own header.
+	printer := opts.printer()
+	printer.ShowSubject = false
+
+	subject := ssa.FmtUnstructured(desired)
+	if printer.Format == DyffHumanFormat && !printer.Summary {
+		fmt.Fprintf(opts.writer(), "# requires recreate %s\n", subject)
+	}
+	printer.Subject = fmt.Sprintf("%s requires recreate", subject)
+	return diffObjects(ctx, opts.tmpDir, live, recreated, printer, opts.writer())
+}
+
+// threeWayDiff prints the drift of the live state from the last applied state,
+// and the change from the last applied state to the desired state.
+func threeWayDiff(ctx context.Context,
+	rm *ssa.ResourceManager,
+	lastApplied *unstructured.Unstructured,
+	mergedObject *unstructured.Unstructured,
+	opts dryRunDiffOptions) error {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(lastApplied.GroupVersionKind())
+	if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(lastApplied), live); err != nil {
+		return fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(lastApplied), err)
+	}
+	unstructured.RemoveNestedField(live.Object, "metadata", "managedFields")
+
+	// Diff returns nil objects for unchanged objects, in which case the state is the live one.
+	desired := live
+	if mergedObject != nil {
+		desired = mergedObject
+	}
+
+	_, _, lastMergedObject, err := rm.Diff(ctx, lastApplied, ssa.DefaultDiffOptions())
+	if err != nil {
+		return err
+	}
+	last := live
+	if lastMergedObject != nil {
+		last = lastMergedObject
+	}
+
+	for _, obj := range []*unstructured.Unstructured{live, last, desired} {
+		opts.removeIgnoredPaths(obj)
+	}
+
+	// The reports are prefixed with their own headers.
+	printer := opts.printer()
+	printer.ShowSubject = false
+
+	subject := ssa.FmtUnstructured(lastApplied)
+	for _, report := range []struct {
+		header   string
+		from, to *unstructured.Unstructured
+	}{
+		{header: "drift (last-applied -> live)", from: last, to: live},
+		{header: "change (last-applied -> desired)", from: last, to: desired},
+	} {
+		if equality.Semantic.DeepEqual(report.from.Object, report.to.Object) {
+			continue
+		}
+
+		if printer.Format == DyffHumanFormat {
+			fmt.Fprintf(opts.writer(), "# %s %s\n", report.header, subject)
+		}
+		printer.Subject = fmt.Sprintf("%s %s", subject, report.header)
+		if err := diffObjects(ctx, opts.tmpDir, report.from, report.to, printer, opts.writer()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffObjects prints the dyff report of the given objects. To keep the memory bounded
+// for large objects, the fields equal in both objects are pruned before the comparison,
+// and the pruned objects are written to a directory created in tmpDir and compared
+// with diffYAML, one object pair at a time.
+func diffObjects(ctx context.Context, tmpDir string, fromObject, toObject *unstructured.Unstructured, printer *DyffPrinter, output io.Writer) error {
+	from, to := pruneEqualFields(fromObject.Object, toObject.Object, true)
+
+	dir, err := os.MkdirTemp(tmpDir, apiv1.FieldManager+"-diff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	liveFile := filepath.Join(dir, "live")
+	if err := writeYAMLFile(liveFile, from); err != nil {
+		return err
+	}
+
+	mergedFile := filepath.Join(dir, "merged")
+	if err := writeYAMLFile(mergedFile, to); err != nil {
+		return err
+	}
+
+	if printer.Format == DyffMarkdownFormat && printer.Subject == "" {
+		p := *printer
+		p.Subject = ssa.FmtUnstructured(toObject)
+		printer = &p
+	} else if printer.ShowSubject && !printer.Summary && printer.Format != DyffJSONFormat && isTerminal(output) {
+		fmt.Fprintln(output, colorizeUnstructured(toObject))
+	}
+
+	return diffYAML(ctx, liveFile, mergedFile, printer, output)
+}
+
+// writeYAMLFile writes the given object as YAML to the given file.
+func writeYAMLFile(path string, obj map[string]interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// printMergedObject writes the YAML of the given merged object, prefixed with a header
+// in the human-readable format, or wrapped in a collapsible block in the markdown format.
+func printMergedObject(w io.Writer, obj *unstructured.Unstructured, format string) error {
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	subject := ssa.FmtUnstructured(obj)
+	if format == DyffMarkdownFormat {
+		_, err = fmt.Fprintf(w, "<details>\n<summary>%s merged</summary>\n\n```yaml\n%s```\n\n</details>\n",
+			html.EscapeString(subject), data)
+	} else {
+		_, err = fmt.Fprintf(w, "# merged %s\n---\n%s", subject, data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to print the merged object: %w", err)
+	}
 	return nil
 }
+
+// diffObjectSets prints the dyff report of two sets of objects, e.g. built from different
+// module versions or values, without querying the cluster. The objects are matched by their
+// kind, namespace and name, the objects present in only one of the sets are reported as
+// added or removed documents.
+func diffObjectSets(ctx context.Context, fromObjects, toObjects []*unstructured.Unstructured, printer *DyffPrinter, output io.Writer) error {
+	fromInput, err := yamlSetInput("from", fromObjects)
+	if err != nil {
+		return err
+	}
+
+	toInput, err := yamlSetInput("to", toObjects)
+	if err != nil {
+		return err
+	}
+
+	report, err := compareInputs(ctx, fromInput, toInput)
+	if err != nil {
+		return err
+	}
+
+	// The documents are sorted in both sets, their order changes only
+	// when objects are added or removed, which is reported separately.
+	diffs := report.Diffs[:0]
+	for _, diff := range report.Diffs {
+		if diff.Path == nil || len(diff.Path.PathElements) == 0 {
+			var details []dyff.Detail
+			for _, detail := range diff.Details {
+				if detail.Kind != dyff.ORDERCHANGE {
+					details = append(details, detail)
+				}
+			}
+			if len(details) == 0 {
+				continue
+			}
+			diff.Details = details
+		}
+		diffs = append(diffs, diff)
+	}
+	report.Diffs = diffs
+
+	return printer.Print(output, report)
+}
+
+// yamlSetInput converts the given objects to a multi-document dyff input,
+// the documents are ordered like the objects are applied.
+func yamlSetInput(location string, objects []*unstructured.Unstructured) (ytbx.InputFile, error) {
+	sorted := make([]*unstructured.Unstructured, len(objects))
+	copy(sorted, objects)
+	sort.Sort(ssa.SortableUnstructureds(sorted))
+
+	var documents []*yamlv3.Node
+	for _, obj := range sorted {
+		input, err := yamlInput(location, obj.Object)
+		if err != nil {
+			return ytbx.InputFile{}, err
+		}
+		documents = append(documents, input.Documents...)
+	}
+
+	return ytbx.InputFile{Location: location, Documents: documents}, nil
+}
+
+// yamlInput converts the given object to a dyff input.
+func yamlInput(location string, obj map[string]interface{}) (ytbx.InputFile, error) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return ytbx.InputFile{}, fmt.Errorf("failed to marshal %s object: %w", location, err)
+	}
+
+	documents, err := ytbx.LoadYAMLDocuments(data)
+	if err != nil {
+		return ytbx.InputFile{}, fmt.Errorf("failed to load %s object: %w", location, err)
+	}
+
+	return ytbx.InputFile{Location: location, Documents: documents}, nil
+}
+
+// pruneEqualFields returns shallow copies of the given maps without the fields
+// that have the same value in both, the nested maps are pruned recursively.
+// The fields used by dyff for the Kubernetes entity detection are always kept.
+// Lists are compared as a whole, to preserve the detection of named list entries.
+func pruneEqualFields(from, to map[string]interface{}, root bool) (map[string]interface{}, map[string]interface{}) {
+	prunedFrom := make(map[string]interface{})
+	prunedTo := make(map[string]interface{})
+
+	for key, fromValue := range from {
+		toValue, ok := to[key]
+		if !ok {
+			prunedFrom[key] = fromValue
+			continue
+		}
+
+		if root && (key == "apiVersion" || key == "kind") {
+			prunedFrom[key] = fromValue
+			prunedTo[key] = toValue
+			continue
+		}
+
+		fromMap, fromIsMap := fromValue.(map[string]interface{})
+		toMap, toIsMap := toValue.(map[string]interface{})
+		if fromIsMap && toIsMap {
+			nestedFrom, nestedTo := pruneEqualFields(fromMap, toMap, false)
+			if root && key == "metadata" {
+				for _, field := range []string{"name", "namespace"} {
+					if v, ok := fromMap[field]; ok {
+						nestedFrom[field] = v
+					}
+					if v, ok := toMap[field]; ok {
+						nestedTo[field] = v
+					}
+				}
+			}
+			if len(nestedFrom) > 0 || len(nestedTo) > 0 {
+				prunedFrom[key] = nestedFrom
+				prunedTo[key] = nestedTo
+			}
+			continue
+		}
+
+		if !equality.Semantic.DeepEqual(fromValue, toValue) {
+			prunedFrom[key] = fromValue
+			prunedTo[key] = toValue
+		}
+	}
+
+	for key, toValue := range to {
+		if _, ok := from[key]; !ok {
+			prunedTo[key] = toValue
+		}
+	}
+
+	return prunedFrom, prunedTo
+}
@@ -18,10 +18,23 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/fluxcd/pkg/ssa"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 )
 
 func TestDiffYAML(t *testing.T) {
@@ -42,7 +55,927 @@ func TestDiffYAML(t *testing.T) {
 	g.Expect(err).ToNot(HaveOccurred())
 
 	buf := new(bytes.Buffer)
-	err = diffYAML(liveFile.Name(), mergedFile.Name(), buf)
+	err = diffYAML(context.Background(), liveFile.Name(), mergedFile.Name(), NewDyffPrinter(), buf)
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(buf.String()).To(ContainSubstring("name: test-pod-merged"))
+
+	err = os.WriteFile(liveFile.Name(), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\ndata:\n  port: \"8080\"\n"), 0644)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	err = os.WriteFile(mergedFile.Name(), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\ndata:\n  port: \"9090\"\n"), 0644)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	printer := NewDyffPrinter()
+	printer.Format = DyffJSONFormat
+	buf.Reset()
+	err = diffYAML(context.Background(), liveFile.Name(), mergedFile.Name(), printer, buf)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var report struct {
+		Diffs []map[string]interface{} `json:"diffs"`
+	}
+	g.Expect(json.Unmarshal(buf.Bytes(), &report)).To(Succeed())
+	g.Expect(report.Diffs).To(ConsistOf(map[string]interface{}{
+		"path": "/data/port",
+		"kind": "modification",
+		"from": "8080",
+		"to":   "9090",
+	}))
+
+	printer.Format = "table"
+	err = diffYAML(context.Background(), liveFile.Name(), mergedFile.Name(), printer, buf)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCompareYAML(t *testing.T) {
+	g := NewWithT(t)
+	tmpDir := t.TempDir()
+
+	liveFile := filepath.Join(tmpDir, "live.yaml")
+	err := os.WriteFile(liveFile, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n  generation: 1\ndata:\n  port: \"8080\"\n"), 0644)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	mergedFile := filepath.Join(tmpDir, "merged.yaml")
+	err = os.WriteFile(mergedFile, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n  generation: 2\ndata:\n  port: \"9090\"\n"), 0644)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	report, err := CompareYAML(liveFile, mergedFile)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var paths []string
+	for _, diff := range report.Diffs {
+		paths = append(paths, diff.Path.ToGoPatchStyle())
+	}
+	g.Expect(paths).To(ConsistOf("/metadata/generation", "/data/port"))
+
+	filtered := report.Exclude("/metadata/generation")
+	g.Expect(filtered.Diffs).To(HaveLen(1))
+
+	_, err = CompareYAML(liveFile, filepath.Join(tmpDir, "missing.yaml"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestParseDiffActions(t *testing.T) {
+	g := NewWithT(t)
+
+	actions, err := parseDiffActions([]string{"configured", "deleted"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(actions).To(Equal([]ssa.Action{ssa.ConfiguredAction, ssa.DeletedAction}))
+
+	opts := dryRunDiffOptions{onlyActions: actions}
+	g.Expect(opts.showAction(ssa.ConfiguredAction)).To(BeTrue())
+	g.Expect(opts.showAction(ssa.DeletedAction)).To(BeTrue())
+	g.Expect(opts.showAction(ssa.CreatedAction)).To(BeFalse())
+	g.Expect(opts.showAction(ssa.UnchangedAction)).To(BeFalse())
+	g.Expect(dryRunDiffOptions{}.showAction(ssa.UnchangedAction)).To(BeTrue())
+
+	_, err = parseDiffActions([]string{"updated"})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("unsupported action 'updated'"))
+}
+
+func TestParseDiffIgnorePaths(t *testing.T) {
+	g := NewWithT(t)
+
+	paths, err := parseDiffIgnorePaths([]string{
+		"metadata.annotations.*",
+		"/metadata/annotations/deployment.kubernetes.io~1revision",
+		"status",
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(paths).To(Equal([][]string{
+		{"metadata", "annotations", "*"},
+		{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+		{"status"},
+	}))
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":        "test",
+			"annotations": map[string]interface{}{"deployment.kubernetes.io/revision": "2"},
+			"labels":      map[string]interface{}{"app": "test"},
+		},
+		"status": map[string]interface{}{"replicas": int64(1)},
+	}}
+	dryRunDiffOptions{ignorePaths: paths}.removeIgnoredPaths(obj)
+	g.Expect(obj.Object).To(Equal(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":   "test",
+			"labels": map[string]interface{}{"app": "test"},
+		},
+	}))
+
+	for _, invalid := range []string{"metadata..name", "*", "metadata.*.name", "/"} {
+		_, err = parseDiffIgnorePaths([]string{invalid})
+		g.Expect(err).To(HaveOccurred(), invalid)
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      "test",
+				"namespace": "default",
+				"labels":    map[string]interface{}{"app": "test"},
+			},
+			"data": data,
+		}}
+	}
+
+	fromData := map[string]interface{}{"port": "8080"}
+	toData := map[string]interface{}{"port": "9090"}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%v", i)
+		fromData[key] = strings.Repeat("x", 1024)
+		toData[key] = strings.Repeat("x", 1024)
+	}
+	from := newConfigMap(fromData)
+	to := newConfigMap(toData)
+
+	prunedFrom, prunedTo := pruneEqualFields(from.Object, to.Object, true)
+	g.Expect(prunedFrom).To(Equal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"data":       map[string]interface{}{"port": "8080"},
+	}))
+	g.Expect(prunedTo["data"]).To(Equal(map[string]interface{}{"port": "9090"}))
+
+	// The input objects must not be modified
+	g.Expect(from.Object["data"]).To(HaveLen(101))
+
+	buf := new(bytes.Buffer)
+	err := diffObjects(context.Background(), t.TempDir(), from, to, NewDyffPrinter(), buf)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(buf.String()).To(ContainSubstring("data.port"))
+	g.Expect(buf.String()).To(ContainSubstring("9090"))
+	g.Expect(buf.String()).ToNot(ContainSubstring("key1"))
+
+	// The subject prefix is written only to terminals
+	g.Expect(isTerminal(buf)).To(BeFalse())
+	g.Expect(buf.String()).ToNot(ContainSubstring("ConfigMap/default/test"))
+}
+
+func TestDiffObjects_Canceled(t *testing.T) {
+	g := NewWithT(t)
+	from := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"data":       map[string]interface{}{"port": "8080"},
+	}}
+	to := from.DeepCopy()
+	g.Expect(unstructured.SetNestedField(to.Object, "9090", "data", "port")).To(Succeed())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tmpDir := t.TempDir()
+	buf := new(bytes.Buffer)
+	err := diffObjects(ctx, tmpDir, from, to, NewDyffPrinter(), buf)
+	g.Expect(err).To(MatchError(context.Canceled))
+	g.Expect(buf.String()).To(BeEmpty())
+
+	// The temporary files of the compared objects are removed
+	entries, err := os.ReadDir(tmpDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+}
+
+func TestDiffObjects_Markdown(t *testing.T) {
+	g := NewWithT(t)
+	from := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"data":       map[string]interface{}{"port": "8080"},
+	}}
+	to := from.DeepCopy()
+	g.Expect(unstructured.SetNestedField(to.Object, "9090", "data", "port")).To(Succeed())
+
+	printer := NewDyffPrinter()
+	printer.Format = DyffMarkdownFormat
+	printer.Color = colorAlways
+
+	buf := new(bytes.Buffer)
+	g.Expect(diffObjects(context.Background(), t.TempDir(), from, to, printer, buf)).To(Succeed())
+
+	output := buf.String()
+	g.Expect(output).To(HavePrefix("<details>\n<summary>ConfigMap/default/test</summary>\n\n```\n"))
+	g.Expect(output).To(HaveSuffix("\n```\n\n</details>\n"))
+	g.Expect(output).To(ContainSubstring("data.port"))
+	g.Expect(output).To(ContainSubstring("9090"))
+	g.Expect(output).ToNot(ContainSubstring("\x1b["))
+	g.Expect(printer.Subject).To(BeEmpty())
+}
+
+func TestDyffPrinter_Color(t *testing.T) {
+	from := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"data":       map[string]interface{}{"port": "8080"},
+	}}
+	to := from.DeepCopy()
+	g := NewWithT(t)
+	g.Expect(unstructured.SetNestedField(to.Object, "9090", "data", "port")).To(Succeed())
+
+	tests := []struct {
+		name      string
+		env       string
+		color     string
+		wantColor bool
+	}{
+		{name: "auto to non-terminal", wantColor: false},
+		{name: "always", color: colorAlways, wantColor: true},
+		{name: "never", color: colorNever, wantColor: false},
+		{name: "from env", env: colorAlways, wantColor: true},
+		{name: "flag overrides env", env: colorAlways, color: colorNever, wantColor: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			t.Setenv(DyffColorEnv, tt.env)
+
+			opts := dryRunDiffOptions{color: tt.color}
+			buf := new(bytes.Buffer)
+			err := diffObjects(context.Background(), t.TempDir(), from, to, opts.printer(), buf)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(buf.String()).To(ContainSubstring("port"))
+			g.Expect(strings.Contains(buf.String(), "\x1b[")).To(Equal(tt.wantColor))
+		})
+	}
+
+	g.Expect(validateColorMode("sometimes")).To(HaveOccurred())
+}
+
+// flushRecorder records the writes made between flushes.
+type flushRecorder struct {
+	pending bytes.Buffer
+	flushed []string
+}
+
+func (r *flushRecorder) Write(p []byte) (int, error) {
+	return r.pending.Write(p)
+}
+
+func (r *flushRecorder) Flush() error {
+	r.flushed = append(r.flushed, r.pending.String())
+	r.pending.Reset()
+	return nil
+}
+
+func TestDiffObjects_Flush(t *testing.T) {
+	g := NewWithT(t)
+
+	printer := NewDyffPrinter()
+	printer.Color = colorNever
+	output := &flushRecorder{}
+
+	for _, port := range []string{"8081", "8082"} {
+		from := newBenchObject("test-"+port, "8080")
+		to := newBenchObject("test-"+port, port)
+		g.Expect(diffObjects(context.Background(), t.TempDir(), from, to, printer, output)).To(Succeed())
+	}
+
+	g.Expect(output.pending.Len()).To(BeZero())
+	g.Expect(output.flushed).To(HaveLen(2))
+	g.Expect(output.flushed[0]).To(ContainSubstring("8081"))
+	g.Expect(output.flushed[1]).To(ContainSubstring("8082"))
+}
+
+// newBenchObject returns a ConfigMap with the given port and a payload
+// of a few hundred fields, to make the comparison representative.
+func newBenchObject(name, port string) *unstructured.Unstructured {
+	data := map[string]interface{}{"port": port}
+	for i := 0; i < 200; i++ {
+		data[fmt.Sprintf("key-%d", i)] = fmt.Sprintf("value-%d", i)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"data":       data,
+	}}
+}
+
+// BenchmarkDiffObjects streams the diff of a growing number of objects and reports
+// the peak heap usage, which should stay flat as the number of objects grows.
+func BenchmarkDiffObjects(b *testing.B) {
+	for _, count := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("objects-%d", count), func(b *testing.B) {
+			printer := NewDyffPrinter()
+			printer.Color = colorNever
+
+			// The objects are built on the fly, as the live and merged objects are
+			// fetched one at a time from the cluster, to measure only the diff memory.
+			var peak uint64
+			var stats goruntime.MemStats
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				for i := 0; i < count; i++ {
+					name := fmt.Sprintf("test-%d", i)
+					from := newBenchObject(name, "8080")
+					to := newBenchObject(name, "9090")
+					if err := diffObjects(context.Background(), b.TempDir(), from, to, printer, io.Discard); err != nil {
+						b.Fatal(err)
+					}
+					if i%10 == 0 {
+						goruntime.ReadMemStats(&stats)
+						peak = max(peak, stats.HeapInuse)
+					}
+				}
+			}
+			b.ReportMetric(float64(peak), "peak-heap-B")
+		})
+	}
+}
+
+func TestWriteDiffFile(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(kind, namespace, name, port string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name},
+			"data":       map[string]interface{}{"port": port},
+		}}
+		obj.SetNamespace(namespace)
+		return obj
+	}
+
+	g.Expect(diffFileName(newObject("ConfigMap", "apps", "test", ""), "diff")).To(Equal("apps_ConfigMap_test.diff"))
+	g.Expect(diffFileName(newObject("ClusterRole", "", "system:test", ""), "diff")).To(Equal("cluster_ClusterRole_system-test.diff"))
+
+	dir := filepath.Join(t.TempDir(), "diffs")
+	from := newObject("ConfigMap", "apps", "test", "8080")
+	to := newObject("ConfigMap", "apps", "test", "9090")
+	err := writeDiffFile(context.Background(), t.TempDir(), dir, from, to, NewDyffPrinter())
+	g.Expect(err).ToNot(HaveOccurred())
+
+	data, err := os.ReadFile(filepath.Join(dir, "apps_ConfigMap_test.diff"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring("data.port"))
+	g.Expect(string(data)).To(ContainSubstring("9090"))
+
+	err = writeDiffInputs(dir, from, to)
+	g.Expect(err).ToNot(HaveOccurred())
+	for file, port := range map[string]string{
+		"apps_ConfigMap_test.live.yaml":   "8080",
+		"apps_ConfigMap_test.merged.yaml": "9090",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring("port: \"%s\"", port))
+	}
+}
+
+func TestDiffObjectSets(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(name, port string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "default",
+			},
+			"data": map[string]interface{}{"port": port},
+		}}
+	}
+
+	from := []*unstructured.Unstructured{newConfigMap("server", "8080"), newConfigMap("legacy", "8080")}
+	to := []*unstructured.Unstructured{newConfigMap("client", "8080"), newConfigMap("server", "9090")}
+
+	buf := new(bytes.Buffer)
+	printer := NewDyffPrinter()
+	printer.Format = DyffJSONFormat
+	err := diffObjectSets(context.Background(), from, to, printer, buf)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var report struct {
+		Diffs []jsonReportDiff `json:"diffs"`
+	}
+	g.Expect(json.Unmarshal(buf.Bytes(), &report)).To(Succeed())
+	g.Expect(report.Diffs).To(HaveLen(3))
+	g.Expect(report.Diffs[0].Kind).To(Equal("removal"))
+	g.Expect(report.Diffs[0].From).To(HaveKeyWithValue("metadata", HaveKeyWithValue("name", "legacy")))
+	g.Expect(report.Diffs[1].Kind).To(Equal("addition"))
+	g.Expect(report.Diffs[1].To).To(HaveKeyWithValue("metadata", HaveKeyWithValue("name", "client")))
+	g.Expect(report.Diffs[2]).To(Equal(jsonReportDiff{Path: "/data/port", Kind: "modification", From: "8080", To: "9090"}))
+
+	// The input sets must not be reordered
+	g.Expect(to[0].GetName()).To(Equal("client"))
+}
+
+func TestDryRunDiffOptions_SelectObject(t *testing.T) {
+	newObject := func(kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetName(name)
+		return obj
+	}
+
+	tests := []struct {
+		name   string
+		opts   dryRunDiffOptions
+		object *unstructured.Unstructured
+		want   bool
+	}{
+		{name: "no filters", object: newObject("ConfigMap", "app"), want: true},
+		{name: "name match", opts: dryRunDiffOptions{names: []string{"app"}}, object: newObject("ConfigMap", "app"), want: true},
+		{name: "name mismatch", opts: dryRunDiffOptions{names: []string{"app"}}, object: newObject("ConfigMap", "db"), want: false},
+		{name: "kind match is case-insensitive", opts: dryRunDiffOptions{kinds: []string{"configmap"}}, object: newObject("ConfigMap", "app"), want: true},
+		{name: "kind mismatch", opts: dryRunDiffOptions{kinds: []string{"Deployment"}}, object: newObject("ConfigMap", "app"), want: false},
+		{name: "name and kind match", opts: dryRunDiffOptions{names: []string{"app"}, kinds: []string{"Deployment"}}, object: newObject("Deployment", "app"), want: true},
+		{name: "name match kind mismatch", opts: dryRunDiffOptions{names: []string{"app"}, kinds: []string{"Deployment"}}, object: newObject("Service", "app"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(tt.opts.selectObject(tt.object)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestDryRunDiffOptions_RemoveTimoniMetadata(t *testing.T) {
+	newObject := func() *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName("app")
+		obj.SetLabels(map[string]string{
+			"app.kubernetes.io/name":             "app",
+			"instance.timoni.sh/name":            "app",
+			"my-tool.instance.timoni.sh/name":    "app",
+			"bundle.timoni.sh/name":              "apps",
+			"example.com/instance.timoni.sh-ref": "app",
+		})
+		obj.SetAnnotations(map[string]string{
+			"instance.timoni.sh/namespace": "default",
+		})
+		return obj
+	}
+
+	t.Run("removes the Timoni metadata", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := newObject()
+		dryRunDiffOptions{}.removeTimoniMetadata(obj)
+		g.Expect(obj.GetLabels()).To(Equal(map[string]string{
+			"app.kubernetes.io/name":             "app",
+			"example.com/instance.timoni.sh-ref": "app",
+		}))
+		_, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "metadata", "annotations")
+		g.Expect(found).To(BeFalse())
+	})
+
+	t.Run("keeps the Timoni metadata", func(t *testing.T) {
+		g := NewWithT(t)
+		obj := newObject()
+		dryRunDiffOptions{keepTimoniMetadata: true}.removeTimoniMetadata(obj)
+		g.Expect(obj.Object).To(Equal(newObject().Object))
+	})
+
+	t.Run("matches the configured prefixes", func(t *testing.T) {
+		g := NewWithT(t)
+		defer func(prefixes []string) { TimoniMetadataPrefixes = prefixes }(TimoniMetadataPrefixes)
+		TimoniMetadataPrefixes = []string{"bundle.timoni.sh/"}
+
+		obj := newObject()
+		dryRunDiffOptions{}.removeTimoniMetadata(obj)
+		g.Expect(obj.GetLabels()).To(HaveKey("instance.timoni.sh/name"))
+		g.Expect(obj.GetLabels()).ToNot(HaveKey("bundle.timoni.sh/name"))
+	})
+}
+
+func TestVersionDiff_RedactSecrets(t *testing.T) {
+	newSecret := func(token string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+			"data":       map[string]interface{}{"token": token},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		showSecrets bool
+		want        []string
+		notWant     []string
+	}{
+		{name: "redacts values", want: []string{"<redacted: changed>"}, notWant: []string{"b2xk", "bmV3"}},
+		{name: "shows values", showSecrets: true, want: []string{"b2xk", "bmV3"}, notWant: []string{"redacted"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			buf := new(bytes.Buffer)
+			rootCmd.SetOut(buf)
+			defer rootCmd.SetOut(nil)
+
+			from := []*unstructured.Unstructured{newSecret("b2xk")}
+			to := []*unstructured.Unstructured{newSecret("bmV3")}
+			changes, err := versionDiff(context.Background(), from, to, "1.0.0", "2.0.0", dryRunDiffOptions{
+				withDiff:    true,
+				color:       colorNever,
+				showSecrets: tt.showSecrets,
+			})
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(changes).To(Equal(1))
+			for _, s := range tt.want {
+				g.Expect(buf.String()).To(ContainSubstring(s))
+			}
+			for _, s := range tt.notWant {
+				g.Expect(buf.String()).ToNot(ContainSubstring(s))
+			}
+			g.Expect(to[0].Object["data"]).To(HaveKeyWithValue("token", "bmV3"))
+		})
+	}
+}
+
+func TestParseDiffChanges(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := parseDiffChanges([]string{"removals", "deletions"})
+	g.Expect(err).To(MatchError(ContainSubstring("unsupported change kind 'deletions'")))
+}
+
+func TestDyffPrinter_IgnoreChanges(t *testing.T) {
+	newConfigMap := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      "test",
+				"namespace": "default",
+			},
+			"data": data,
+		}}
+	}
+
+	from := newConfigMap(map[string]interface{}{"port": "8080", "removed": "old"})
+	to := newConfigMap(map[string]interface{}{"port": "9090", "added": "new"})
+
+	tests := []struct {
+		name           string
+		ignoreAdded    bool
+		ignoreRemoved  bool
+		onlyChanges    []string
+		expectedOutput []string
+		ignoredOutput  []string
+	}{
+		{
+			name:           "shows all changes by default",
+			expectedOutput: []string{"data.port", "added: new", "removed: old"},
+		},
+		{
+			name:           "ignores additions",
+			ignoreAdded:    true,
+			expectedOutput: []string{"data.port", "removed: old"},
+			ignoredOutput:  []string{"added: new"},
+		},
+		{
+			name:           "ignores removals",
+			ignoreRemoved:  true,
+			expectedOutput: []string{"data.port", "added: new"},
+			ignoredOutput:  []string{"removed: old"},
+		},
+		{
+			name:           "shows only removals",
+			onlyChanges:    []string{"removals"},
+			expectedOutput: []string{"removed: old"},
+			ignoredOutput:  []string{"data.port", "added: new"},
+		},
+		{
+			name:           "shows only modifications and additions",
+			onlyChanges:    []string{"modifications", "additions"},
+			expectedOutput: []string{"data.port", "added: new"},
+			ignoredOutput:  []string{"removed: old"},
+		},
+		{
+			name:           "shows all changes",
+			onlyChanges:    []string{"all"},
+			expectedOutput: []string{"data.port", "added: new", "removed: old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			onlyChanges, err := parseDiffChanges(tt.onlyChanges)
+			g.Expect(err).ToNot(HaveOccurred())
+			opts := dryRunDiffOptions{ignoreAdded: tt.ignoreAdded, ignoreRemoved: tt.ignoreRemoved, onlyChanges: onlyChanges}
+
+			buf := new(bytes.Buffer)
+			err = diffObjects(context.Background(), t.TempDir(), from, to, opts.printer(), buf)
+			g.Expect(err).ToNot(HaveOccurred())
+			for _, s := range tt.expectedOutput {
+				g.Expect(buf.String()).To(ContainSubstring(s))
+			}
+			for _, s := range tt.ignoredOutput {
+				g.Expect(buf.String()).ToNot(ContainSubstring(s))
+			}
+		})
+	}
+}
+
+func TestTrimTextContext(t *testing.T) {
+	lines := func(values ...string) string {
+		return strings.Join(values, "\n")
+	}
+
+	tests := []struct {
+		name         string
+		contextLines int
+		from         string
+		to           string
+		expectedFrom string
+		expectedTo   string
+	}{
+		{
+			name:         "elides the lines outside the context",
+			contextLines: 1,
+			from:         lines("a", "b", "c", "d", "e", "f", "g"),
+			to:           lines("a", "b", "c", "D", "e", "f", "g"),
+			expectedFrom: lines("... (2 unchanged lines)", "c", "d", "e", "... (2 unchanged lines)"),
+			expectedTo:   lines("... (2 unchanged lines)", "c", "D", "e", "... (2 unchanged lines)"),
+		},
+		{
+			name:         "prints only the changed lines without context",
+			contextLines: 0,
+			from:         lines("a", "b", "c"),
+			to:           lines("a", "B", "X", "c"),
+			expectedFrom: lines("... (1 unchanged lines)", "b", "... (1 unchanged lines)"),
+			expectedTo:   lines("... (1 unchanged lines)", "B", "X", "... (1 unchanged lines)"),
+		},
+		{
+			name:         "keeps the text when the context covers it",
+			contextLines: 3,
+			from:         lines("a", "b", "c"),
+			to:           lines("a", "B", "c"),
+			expectedFrom: lines("a", "b", "c"),
+			expectedTo:   lines("a", "B", "c"),
+		},
+		{
+			name:         "elides only the trailing lines of appended text",
+			contextLines: 1,
+			from:         lines("a", "b", "c"),
+			to:           lines("a", "b", "c", "d"),
+			expectedFrom: lines("... (2 unchanged lines)", "c"),
+			expectedTo:   lines("... (2 unchanged lines)", "c", "d"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			from, to := trimTextContext(tt.from, tt.to, tt.contextLines)
+			g.Expect(from).To(Equal(tt.expectedFrom))
+			g.Expect(to).To(Equal(tt.expectedTo))
+		})
+	}
+}
+
+func TestDyffPrinter_ContextLines(t *testing.T) {
+	newConfigMap := func(config string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+			"data":       map[string]interface{}{"config": config},
+		}}
+	}
+
+	var fromLines []string
+	for i := 1; i <= 20; i++ {
+		fromLines = append(fromLines, fmt.Sprintf("key%d = value%d", i, i))
+	}
+	toLines := slices.Clone(fromLines)
+	toLines[9] = "key10 = changed"
+	from := newConfigMap(strings.Join(fromLines, "\n") + "\n")
+	to := newConfigMap(strings.Join(toLines, "\n") + "\n")
+
+	t.Run("prints the whole text by default", func(t *testing.T) {
+		g := NewWithT(t)
+		printer := dryRunDiffOptions{color: colorNever}.printer()
+
+		buf := new(bytes.Buffer)
+		g.Expect(diffObjects(context.Background(), t.TempDir(), from, to, printer, buf)).To(Succeed())
+		g.Expect(buf.String()).To(ContainSubstring("key1 = value1"))
+		g.Expect(buf.String()).To(ContainSubstring("key20 = value20"))
+		g.Expect(buf.String()).ToNot(ContainSubstring("unchanged lines"))
+	})
+
+	t.Run("prints the context lines", func(t *testing.T) {
+		g := NewWithT(t)
+		contextLines := 2
+		printer := dryRunDiffOptions{color: colorNever, contextLines: &contextLines}.printer()
+
+		buf := new(bytes.Buffer)
+		g.Expect(diffObjects(context.Background(), t.TempDir(), from, to, printer, buf)).To(Succeed())
+		output := buf.String()
+		g.Expect(output).To(ContainSubstring("... (7 unchanged lines)"))
+		g.Expect(output).To(ContainSubstring("key8 = value8"))
+		g.Expect(output).To(ContainSubstring("key10 = changed"))
+		g.Expect(output).To(ContainSubstring("key12 = value12"))
+		g.Expect(output).To(ContainSubstring("... (9 unchanged lines)"))
+		g.Expect(output).ToNot(ContainSubstring("key1 = value1\n"))
+		g.Expect(output).ToNot(ContainSubstring("key20 = value20"))
+	})
+}
+
+func TestReportSummary(t *testing.T) {
+	g := NewWithT(t)
+	newObject := func(kind, name string, data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"data":       data,
+		}}
+	}
+
+	fromInput, err := yamlSetInput("from", []*unstructured.Unstructured{
+		newObject("ConfigMap", "app", map[string]interface{}{"a": "1", "b": "2", "c": "3"}),
+		newObject("Secret", "app", map[string]interface{}{"token": "x"}),
+		newObject("ConfigMap", "db", map[string]interface{}{"host": "db"}),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	toInput, err := yamlSetInput("to", []*unstructured.Unstructured{
+		newObject("ConfigMap", "app", map[string]interface{}{"a": "10", "b": "20", "d": "4"}),
+		newObject("Secret", "app", map[string]interface{}{"token": "y"}),
+		newObject("ConfigMap", "db", map[string]interface{}{"host": "db"}),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	report, err := compareInputs(context.Background(), fromInput, toInput)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	stats := ReportSummary(report)
+	g.Expect(stats).To(HaveLen(2))
+	g.Expect(stats).To(HaveKeyWithValue("ConfigMap/default/app", DiffStats{Added: 1, Removed: 1, Modified: 2}))
+	g.Expect(stats).To(HaveKeyWithValue("Secret/default/app", DiffStats{Modified: 1}))
+	g.Expect(stats["ConfigMap/default/app"].String()).To(Equal("2 modified, 1 added, 1 removed"))
+}
+
+func TestDiffObjects_Summary(t *testing.T) {
+	g := NewWithT(t)
+	from := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "api", "namespace": "apps"},
+		"spec":       map[string]interface{}{"replicas": int64(1), "paused": false},
+	}}
+	to := from.DeepCopy()
+	g.Expect(unstructured.SetNestedField(to.Object, int64(3), "spec", "replicas")).To(Succeed())
+	unstructured.RemoveNestedField(to.Object, "spec", "paused")
+
+	printer := dryRunDiffOptions{color: colorNever, summary: true}.printer()
+	buf := new(bytes.Buffer)
+	g.Expect(diffObjects(context.Background(), t.TempDir(), from, to, printer, buf)).To(Succeed())
+	g.Expect(buf.String()).To(Equal("Deployment/apps/api: 1 modified, 0 added, 1 removed\n"))
+}
+
+func TestPrintMergedObject(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+		"data":       map[string]interface{}{"port": "9090"},
+	}}
+
+	t.Run("human format", func(t *testing.T) {
+		g := NewWithT(t)
+		buf := new(bytes.Buffer)
+		g.Expect(printMergedObject(buf, obj, DyffHumanFormat)).To(Succeed())
+		g.Expect(buf.String()).To(HavePrefix("# merged ConfigMap/default/test\n---\napiVersion: v1\n"))
+		g.Expect(buf.String()).To(ContainSubstring("  port: \"9090\"\n"))
+	})
+
+	t.Run("markdown format", func(t *testing.T) {
+		g := NewWithT(t)
+		buf := new(bytes.Buffer)
+		g.Expect(printMergedObject(buf, obj, DyffMarkdownFormat)).To(Succeed())
+		g.Expect(buf.String()).To(HavePrefix("<details>\n<summary>ConfigMap/default/test merged</summary>\n\n```yaml\napiVersion: v1\n"))
+		g.Expect(buf.String()).To(HaveSuffix("```\n\n</details>\n"))
+	})
+}
+
+func TestDriftReport(t *testing.T) {
+	g := NewWithT(t)
+	newObject := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	var nilReport *driftReport
+	nilReport.add(newObject("app"), ssa.ConfiguredAction)
+
+	report := newDriftReport("app", "default")
+	report.add(newObject("unchanged"), ssa.UnchangedAction)
+	report.add(newObject("missing"), ssa.CreatedAction)
+	g.Expect(report.Drifted).To(BeFalse())
+
+	report.add(newObject("drifted"), ssa.ConfiguredAction)
+	report.add(newObject("stale"), ssa.DeletedAction)
+
+	file := filepath.Join(t.TempDir(), "drift.json")
+	g.Expect(report.write(file)).To(Succeed())
+	data, err := os.ReadFile(file)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(MatchJSON(`{
+		"name": "app",
+		"namespace": "default",
+		"drifted": true,
+		"driftedObjects": ["ConfigMap/default/drifted"],
+		"missingObjects": ["ConfigMap/default/missing"],
+		"staleObjects": ["ConfigMap/default/stale"]
+	}`))
+}
+
+func TestRecreateDiff(t *testing.T) {
+	newObject := func(kind, value string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+			"immutable":  true,
+			"data":       map[string]interface{}{"port": value},
+		}}
+		return obj
+	}
+
+	tests := []struct {
+		name           string
+		live           *unstructured.Unstructured
+		desired        *unstructured.Unstructured
+		expectedOutput []string
+		ignoredOutput  []string
+	}{
+		{
+			name:           "prints the recreate diff",
+			live:           newObject("ConfigMap", "8080"),
+			desired:        newObject("ConfigMap", "9090"),
+			expectedOutput: []string{"# requires recreate ConfigMap/default/app\n", "data.port", "8080", "9090"},
+			ignoredOutput:  []string{"resourceVersion", "creationTimestamp"},
+		},
+		{
+			name:           "redacts the Secret values",
+			live:           newObject("Secret", "ODA4MA=="),
+			desired:        newObject("Secret", "OTA5MA=="),
+			expectedOutput: []string{"# requires recreate Secret/default/app\n", "data.port", "<redacted: changed>"},
+			ignoredOutput:  []string{"ODA4MA==", "OTA5MA=="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := fake.NewClientBuilder().WithObjects(tt.live).Build()
+			rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+			buf := new(bytes.Buffer)
+			opts := dryRunDiffOptions{withDiff: true, color: colorNever, output: buf}
+			g.Expect(recreateDiff(context.Background(), rm, tt.desired, opts)).To(Succeed())
+
+			for _, expected := range tt.expectedOutput {
+				g.Expect(buf.String()).To(ContainSubstring(expected))
+			}
+			for _, ignored := range tt.ignoredOutput {
+				g.Expect(buf.String()).ToNot(ContainSubstring(ignored))
+			}
+		})
+	}
+}
+
+func TestValidateTmpDir(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(validateTmpDir(dir)).To(Succeed())
+	entries, err := os.ReadDir(dir)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+
+	err = validateTmpDir(filepath.Join(dir, "missing"))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("is not writable, use --diff-tmp-dir"))
 }
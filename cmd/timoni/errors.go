@@ -11,3 +11,17 @@ func describeErr(moduleRoot, description string, err error) error {
 		Cwd: moduleRoot,
 	}))
 }
+
+// exitCodeError is returned by the commands which exit with a specific code.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
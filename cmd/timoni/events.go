@@ -0,0 +1,173 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/runtime"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events [INSTANCE NAME]",
+	Short: "Displays the Kubernetes events of the resources managed by an instance",
+	Long: `The events command lists the Kubernetes events referencing the resources
+managed by an instance, sorted chronologically.`,
+	Example: `  # Show the events of the managed resources
+  timoni -n apps events app
+
+  # Show the warning events from the last 10 minutes
+  timoni -n apps events app --only-warnings --since=10m
+`,
+	RunE: runEventsCmd,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeInstanceList(cmd, args, toComplete)
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	},
+}
+
+type eventsFlags struct {
+	name         string
+	since        time.Duration
+	onlyWarnings bool
+}
+
+var eventsArgs eventsFlags
+
+func init() {
+	eventsCmd.Flags().DurationVar(&eventsArgs.since, "since", 0,
+		"Only show the events newer than a relative duration e.g. 5s, 2m, 3h.")
+	eventsCmd.Flags().BoolVar(&eventsArgs.onlyWarnings, "only-warnings", false,
+		"Only show the events of type Warning.")
+
+	rootCmd.AddCommand(eventsCmd)
+}
+
+func runEventsCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("instance name is required")
+	}
+
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	eventsArgs.name = name
+
+	rm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	sm := runtime.NewStorageManager(rm)
+	instance, err := sm.Get(ctx, eventsArgs.name, *kubeconfigArgs.Namespace)
+	if err != nil {
+		return err
+	}
+
+	im := runtime.InstanceManager{Instance: apiv1.Instance{Inventory: instance.Inventory}}
+	objects, err := im.ListObjects()
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if eventsArgs.since > 0 {
+		since = time.Now().Add(-eventsArgs.since)
+	}
+
+	type objectEvent struct {
+		object string
+		event  corev1.Event
+	}
+
+	var events []objectEvent
+	for _, obj := range objects {
+		// Events of cluster-scoped objects are recorded in the default namespace.
+		ns := obj.GetNamespace()
+		if ns == "" {
+			ns = "default"
+		}
+
+		list := &corev1.EventList{}
+		err = rm.Client().List(ctx, list, client.InNamespace(ns), client.MatchingFields{
+			"involvedObject.kind": obj.GetKind(),
+			"involvedObject.name": obj.GetName(),
+		})
+		if err != nil {
+			return fmt.Errorf("listing events for %s failed: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		for _, event := range list.Items {
+			if eventsArgs.onlyWarnings && event.Type != corev1.EventTypeWarning {
+				continue
+			}
+			if !since.IsZero() && eventTime(event).Before(since) {
+				continue
+			}
+			events = append(events, objectEvent{object: ssa.FmtUnstructured(obj), event: event})
+		}
+	}
+
+	// chronological sort by the last occurrence
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i].event).Before(eventTime(events[j].event))
+	})
+
+	var rows [][]string
+	for _, e := range events {
+		rows = append(rows, []string{
+			eventTime(e.event).Format(time.RFC3339),
+			e.event.Type,
+			e.event.Reason,
+			e.object,
+			e.event.Message,
+		})
+	}
+
+	printTable(rootCmd.OutOrStdout(), []string{"last seen", "type", "reason", "object", "message"}, rows)
+
+	return nil
+}
+
+// eventTime returns the time of the last occurrence of the event.
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInstanceEvents(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -p main --wait",
+		namespace,
+		name,
+		modPath,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	newEvent := func(reason, eventType string, age time.Duration) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      rnd(name, 5),
+				Namespace: namespace,
+			},
+			InvolvedObject: corev1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+				Name:       name + "-server",
+				Namespace:  namespace,
+			},
+			Reason:        reason,
+			Message:       reason + " message",
+			Type:          eventType,
+			LastTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		}
+	}
+
+	for _, event := range []*corev1.Event{
+		newEvent("OldWarning", corev1.EventTypeWarning, time.Hour),
+		newEvent("RecentWarning", corev1.EventTypeWarning, time.Minute),
+		newEvent("RecentInfo", corev1.EventTypeNormal, 2*time.Minute),
+	} {
+		g.Expect(envTestClient.Create(context.Background(), event)).To(Succeed())
+	}
+
+	t.Run("lists events chronologically", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"events -n %s %s",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server", namespace, name)))
+		g.Expect(output).To(MatchRegexp("(?s)OldWarning.*RecentInfo.*RecentWarning"))
+	})
+
+	t.Run("filters events", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"events -n %s %s --only-warnings --since=10m",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(output).To(ContainSubstring("RecentWarning"))
+		g.Expect(output).ToNot(ContainSubstring("OldWarning"))
+		g.Expect(output).ToNot(ContainSubstring("RecentInfo"))
+	})
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stefanprodan/timoni/internal/runtime"
+)
+
+var inspectAnnotationsCmd = &cobra.Command{
+	Use:   "annotations [INSTANCE NAME]",
+	Short: "Print the annotations stored with the instance inventory",
+	Example: `  # Print the annotations set with 'timoni apply --inventory-annotation'
+  timoni -n default inspect annotations app
+`,
+	RunE: runInspectAnnotationsCmd,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeInstanceList(cmd, args, toComplete)
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	},
+}
+
+type inspectAnnotationsFlags struct {
+	name string
+}
+
+var inspectAnnotationsArgs inspectAnnotationsFlags
+
+func init() {
+	inspectCmd.AddCommand(inspectAnnotationsCmd)
+}
+
+func runInspectAnnotationsCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return errors.New("instance name is required")
+	}
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	inspectAnnotationsArgs.name = name
+
+	sm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	iStorage := runtime.NewStorageManager(sm)
+	inst, err := iStorage.Get(ctx, inspectAnnotationsArgs.name, *kubeconfigArgs.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(inst.Annotations) == 0 {
+		return nil
+	}
+
+	data, err := yaml.Marshal(inst.Annotations)
+	if err != nil {
+		return fmt.Errorf("failed to read annotations: %w", err)
+	}
+	cmd.OutOrStdout().Write(data)
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/stefanprodan/timoni/internal/runtime"
+)
+
+var inspectImagesCmd = &cobra.Command{
+	Use:   "images [INSTANCE NAME]",
+	Short: "Print the container images deployed by an instance",
+	Long: `The inspect images command lists the container images
+found in the Pod spec of the workloads managed by an instance.`,
+	Example: `  # Print the container images
+  timoni -n default inspect images app
+
+  # Print the container images in JSON format
+  timoni -n default inspect images app -o json
+`,
+	RunE: runInspectImagesCmd,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeInstanceList(cmd, args, toComplete)
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	},
+}
+
+type inspectImagesFlags struct {
+	name   string
+	output string
+}
+
+var inspectImagesArgs inspectImagesFlags
+
+func init() {
+	inspectImagesCmd.Flags().StringVarP(&inspectImagesArgs.output, "output", "o", "",
+		"The format in which the images should be printed, can be 'json'.")
+
+	inspectCmd.AddCommand(inspectImagesCmd)
+}
+
+func runInspectImagesCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("instance name is required")
+	}
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	inspectImagesArgs.name = name
+
+	rm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	iStorage := runtime.NewStorageManager(rm)
+	inst, err := iStorage.Get(ctx, inspectImagesArgs.name, *kubeconfigArgs.Namespace)
+	if err != nil {
+		return err
+	}
+
+	iManager := runtime.InstanceManager{Instance: *inst}
+
+	objects, err := iManager.ListObjects()
+	if err != nil {
+		return err
+	}
+
+	type objectImage struct {
+		Object string `json:"object"`
+		runtime.ContainerImage
+	}
+
+	images := []objectImage{}
+	for _, obj := range objects {
+		err = rm.Client().Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		for _, image := range runtime.GetContainerImages(obj) {
+			images = append(images, objectImage{
+				Object:         ssa.FmtUnstructured(obj),
+				ContainerImage: image,
+			})
+		}
+	}
+
+	switch inspectImagesArgs.output {
+	case "json":
+		marshalled, err := json.MarshalIndent(images, "", "  ")
+		if err != nil {
+			return fmt.Errorf("images JSON conversion failed: %w", err)
+		}
+		marshalled = append(marshalled, "\n"...)
+		cmd.OutOrStdout().Write(marshalled)
+	case "":
+		var rows [][]string
+		for _, image := range images {
+			rows = append(rows, []string{image.Image, image.Container, image.Object})
+		}
+		printTable(rootCmd.OutOrStdout(), []string{"image", "container", "object"}, rows)
+	default:
+		return fmt.Errorf("unsupported output format '%s'", inspectImagesArgs.output)
+	}
+
+	return nil
+}
@@ -18,20 +18,39 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
 
+	"cuelang.org/go/cue/cuecontext"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/yaml"
 
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/engine"
+	"github.com/stefanprodan/timoni/internal/flags"
 	"github.com/stefanprodan/timoni/internal/runtime"
 )
 
 var inspectModuleCmd = &cobra.Command{
-	Use:   "module [INSTANCE NAME]",
+	Use:   "module [INSTANCE NAME | MODULE URL]",
 	Short: "Print the module information of an instance",
+	Long: `The inspect module command prints the module reference of an instance.
+
+With '--deps', the command pulls the module and lists the CUE packages it imports
+from 'cue.mod' with their content digests, and the dependencies declared in
+'cue.mod/module.cue' with their versions. The argument can be an instance name,
+a module OCI URL or a local module path.`,
 	Example: `  # Print the module info
   timoni -n default inspect module app
+
+  # List the CUE dependencies of the module used by an instance
+  timoni -n default inspect module app --deps
+
+  # List the CUE dependencies of a module version as JSON
+  timoni inspect module oci://docker.io/org/module -v 1.0.0 --deps --output=json
 `,
 	RunE: runInspectModuleCmd,
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -45,12 +64,25 @@ var inspectModuleCmd = &cobra.Command{
 }
 
 type inspectModuleFlags struct {
-	name string
+	name    string
+	deps    bool
+	output  string
+	version flags.Version
+	pkg     flags.Package
+	creds   flags.Credentials
 }
 
 var inspectModuleArgs inspectModuleFlags
 
 func init() {
+	inspectModuleCmd.Flags().BoolVar(&inspectModuleArgs.deps, "deps", false,
+		"List the CUE dependencies of the module.")
+	inspectModuleCmd.Flags().StringVarP(&inspectModuleArgs.output, "output", "o", "",
+		"The format in which the dependencies should be printed, can be 'json'.")
+	inspectModuleCmd.Flags().VarP(&inspectModuleArgs.version, inspectModuleArgs.version.Type(), inspectModuleArgs.version.Shorthand(),
+		"The module version to inspect when a module URL is specified.")
+	inspectModuleCmd.Flags().VarP(&inspectModuleArgs.pkg, inspectModuleArgs.pkg.Type(), inspectModuleArgs.pkg.Shorthand(), inspectModuleArgs.pkg.Description())
+	inspectModuleCmd.Flags().Var(&inspectModuleArgs.creds, inspectModuleArgs.creds.Type(), inspectModuleArgs.creds.Description())
 	inspectCmd.AddCommand(inspectModuleCmd)
 }
 
@@ -58,7 +90,22 @@ func runInspectModuleCmd(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return errors.New("instance name is required")
 	}
-	inspectModuleArgs.name = args[0]
+
+	switch inspectModuleArgs.output {
+	case "", "json":
+	default:
+		return fmt.Errorf("unsupported output format '%s'", inspectModuleArgs.output)
+	}
+
+	if inspectModuleArgs.deps && isModuleURL(args[0]) {
+		return printModuleDeps(cmd, args[0], inspectModuleArgs.version.String())
+	}
+
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	inspectModuleArgs.name = name
 
 	sm, err := runtime.NewResourceManager(kubeconfigArgs)
 	if err != nil {
@@ -74,6 +121,14 @@ func runInspectModuleCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if inspectModuleArgs.deps {
+		version := inst.Module.Version
+		if inst.Module.Digest != "" {
+			version = "@" + inst.Module.Digest
+		}
+		return printModuleDeps(cmd, inst.Module.Repository, version)
+	}
+
 	data, err := yaml.Marshal(inst.Module)
 	if err != nil {
 		return fmt.Errorf("failed to read module info: %w", err)
@@ -81,3 +136,73 @@ func runInspectModuleCmd(cmd *cobra.Command, args []string) error {
 	cmd.OutOrStdout().Write(data)
 	return nil
 }
+
+// isModuleURL returns true if the given argument is a module
+// OCI URL or a local module path instead of an instance name.
+func isModuleURL(arg string) bool {
+	if strings.HasPrefix(arg, apiv1.ArtifactPrefix) {
+		return true
+	}
+	fs, err := os.Stat(arg)
+	return err == nil && fs.IsDir()
+}
+
+// printModuleDeps fetches the module and prints its CUE dependencies.
+func printModuleDeps(cmd *cobra.Command, moduleURL, version string) error {
+	if version == "" {
+		version = apiv1.LatestVersion
+	}
+
+	tmpDir, err := os.MkdirTemp("", apiv1.FieldManager)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctxPull, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	fetcher := engine.NewFetcher(
+		ctxPull,
+		moduleURL,
+		version,
+		tmpDir,
+		rootArgs.cacheDir,
+		inspectModuleArgs.creds.String(),
+		rootArgs.registryInsecure,
+	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
+	if _, err := fetcher.Fetch(); err != nil {
+		return err
+	}
+
+	builder := engine.NewModuleBuilder(
+		cuecontext.New(),
+		"default",
+		*kubeconfigArgs.Namespace,
+		fetcher.GetModuleRoot(),
+		inspectModuleArgs.pkg.String(),
+	)
+
+	deps, err := builder.ListDependencies()
+	if err != nil {
+		return fmt.Errorf("failed to list dependencies: %w", err)
+	}
+
+	if inspectModuleArgs.output == "json" {
+		data, err := json.MarshalIndent(deps, "", "  ")
+		if err != nil {
+			return fmt.Errorf("dependencies JSON conversion failed: %w", err)
+		}
+		data = append(data, '\n')
+		cmd.OutOrStdout().Write(data)
+		return nil
+	}
+
+	var rows [][]string
+	for _, dep := range deps {
+		rows = append(rows, []string{dep.Path, printOrPass(dep.Version), printOrPass(dep.Digest)})
+	}
+	printTable(cmd.OutOrStdout(), []string{"path", "version", "digest"}, rows)
+	return nil
+}
@@ -57,7 +57,11 @@ func runInspectResourcesCmd(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("instance name is required")
 	}
-	inspectResourcesArgs.name = args[0]
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	inspectResourcesArgs.name = name
 
 	sm, err := runtime.NewResourceManager(kubeconfigArgs)
 	if err != nil {
@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
@@ -27,6 +28,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/engine"
 )
 
 func TestInspect(t *testing.T) {
@@ -50,7 +52,7 @@ func TestInspect(t *testing.T) {
 
 	// Install the module from the registry
 	_, err = executeCommandWithIn(fmt.Sprintf(
-		"apply -n %s %s %s -v %s -p main --wait -f-",
+		"apply -n %s %s %s -v %s -p main --wait -f- --inventory-annotation=ci.example.com/pipeline=1234",
 		namespace,
 		name,
 		modURL,
@@ -76,6 +78,17 @@ func TestInspect(t *testing.T) {
 
 	})
 
+	t.Run("inspect annotations", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect annotations -n %s %s",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(`ci.example.com/pipeline: "1234"`))
+	})
+
 	t.Run("inspect values", func(t *testing.T) {
 		g := NewWithT(t)
 		output, err := executeCommand(fmt.Sprintf(
@@ -103,6 +116,19 @@ func TestInspect(t *testing.T) {
 		g.Expect(output).To(ContainSubstring(fmt.Sprintf("configmap/%s-client", name)))
 		g.Expect(output).To(ContainSubstring(fmt.Sprintf("configmap/%s-server", name)))
 	})
+
+	t.Run("inspect images", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"inspect images -n %s %s -o json",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		// The module contains no workloads
+		g.Expect(strings.TrimSpace(output)).To(Equal("[]"))
+	})
 }
 
 func TestInspect_Latest(t *testing.T) {
@@ -179,3 +205,32 @@ func TestInspect_StorageType(t *testing.T) {
 	g.Expect(secret.Labels).To(HaveKeyWithValue("app.kubernetes.io/name", name))
 	g.Expect(secret.Data).To(HaveKey(strings.ToLower(apiv1.InstanceKind)))
 }
+
+func TestInspectModule_Deps(t *testing.T) {
+	modPath := "testdata/module"
+
+	t.Run("lists dependencies", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf("inspect module %s -p main --deps", modPath))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("timoni.sh/core/v1alpha1"))
+		g.Expect(output).To(ContainSubstring("sha256:"))
+	})
+
+	t.Run("lists dependencies as JSON", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf("inspect module %s -p main --deps -o json", modPath))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		var deps []engine.ModuleDependency
+		g.Expect(json.Unmarshal([]byte(output), &deps)).To(Succeed())
+		g.Expect(deps).ToNot(BeEmpty())
+		g.Expect(deps[0].Path).To(Equal("timoni.sh/core/v1alpha1"))
+	})
+
+	t.Run("fails for unknown output", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf("inspect module %s -p main --deps -o yaml", modPath))
+		g.Expect(err).To(HaveOccurred())
+	})
+}
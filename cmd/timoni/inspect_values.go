@@ -58,7 +58,11 @@ func runInspectValuesCmd(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("instance name is required")
 	}
-	inspectValuesArgs.name = args[0]
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	inspectValuesArgs.name = name
 
 	sm, err := runtime.NewResourceManager(kubeconfigArgs)
 	if err != nil {
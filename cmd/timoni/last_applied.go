@@ -0,0 +1,126 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue/cuecontext"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/engine"
+)
+
+// buildLastApplied pulls the module at the digest recorded in the instance storage
+// and builds it with the stored values and the given CUE tags, returning the last applied objects.
+func buildLastApplied(ctx context.Context, instance *apiv1.Instance, pkg string, tags []string, creds, kubeVersion, tmpDir string) ([]*unstructured.Unstructured, error) {
+	if !strings.HasPrefix(instance.Module.Repository, apiv1.ArtifactPrefix) {
+		return nil, fmt.Errorf("the last applied module %s was not pulled from a container registry", instance.Module.Repository)
+	}
+
+	values := fmt.Sprintf("%s: %s", apiv1.ValuesSelector, instance.Values)
+	return buildModuleVersion(ctx, moduleVersionBuild{
+		description: "last applied",
+		name:        instance.Name,
+		namespace:   instance.Namespace,
+		repository:  instance.Module.Repository,
+		version:     "@" + instance.Module.Digest,
+		pkg:         pkg,
+		tags:        tags,
+		values:      [][]byte{[]byte(values)},
+		creds:       creds,
+		kubeVersion: kubeVersion,
+		dir:         filepath.Join(tmpDir, "last-applied"),
+	})
+}
+
+// moduleVersionBuild holds the inputs for building an instance
+// from a specific version of a module pulled from a container registry.
+type moduleVersionBuild struct {
+	// description is used to prefix the error messages e.g. 'last applied'.
+	description string
+	name        string
+	namespace   string
+	repository  string
+	version     string
+	pkg         string
+	tags        []string
+	values      [][]byte
+	creds       string
+	kubeVersion string
+	dir         string
+}
+
+// buildModuleVersion pulls the module version and builds it with the given values,
+// returning the resulting objects.
+func buildModuleVersion(ctx context.Context, b moduleVersionBuild) ([]*unstructured.Unstructured, error) {
+	fetcher := engine.NewFetcher(
+		ctx,
+		b.repository,
+		b.version,
+		b.dir,
+		rootArgs.cacheDir,
+		b.creds,
+		rootArgs.registryInsecure,
+	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
+	mod, err := fetcher.Fetch()
+	if err != nil {
+		return nil, fmt.Errorf("pulling the %s module failed: %w", b.description, err)
+	}
+
+	builder := engine.NewModuleBuilder(
+		cuecontext.New(),
+		b.name,
+		b.namespace,
+		fetcher.GetModuleRoot(),
+		b.pkg,
+	)
+
+	if err := builder.WriteSchemaFile(); err != nil {
+		return nil, err
+	}
+
+	if len(b.values) > 0 {
+		if err := builder.MergeValuesFile(b.values); err != nil {
+			return nil, fmt.Errorf("merging the %s values failed: %w", b.description, err)
+		}
+	}
+
+	builder.SetVersionInfo(mod.Version, b.kubeVersion)
+
+	buildResult, err := builder.Build(b.tags...)
+	if err != nil {
+		return nil, describeErr(fetcher.GetModuleRoot(), fmt.Sprintf("building the %s module failed", b.description), err)
+	}
+
+	applySets, err := builder.GetApplySets(buildResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the %s objects: %w", b.description, err)
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, set := range applySets {
+		objects = append(objects, set.Objects...)
+	}
+
+	return objects, nil
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zerologr"
 	gcrLog "github.com/google/go-containerregistry/pkg/logs"
+	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	runtimeLog "sigs.k8s.io/controller-runtime/pkg/log"
@@ -38,20 +40,31 @@ import (
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 )
 
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
 // NewConsoleLogger returns a human-friendly Logger.
 // Pretty print adds timestamp, log level and colorized output to the logs.
+// In the JSON format, the logs are written as structured entries, one per line.
 func NewConsoleLogger() logr.Logger {
-	color.NoColor = !rootArgs.coloredLog
-	zconfig := zerolog.ConsoleWriter{Out: color.Error, NoColor: !rootArgs.coloredLog}
-	if !rootArgs.prettyLog {
-		zconfig.PartsExclude = []string{
-			zerolog.TimestampFieldName,
-			zerolog.LevelFieldName,
+	var zlog zerolog.Logger
+	if rootArgs.logFormat == logFormatJSON {
+		color.NoColor = true
+		zlog = zerolog.New(color.Error).With().Timestamp().Logger()
+	} else {
+		color.NoColor = !rootArgs.coloredLog
+		zconfig := zerolog.ConsoleWriter{Out: color.Error, NoColor: !rootArgs.coloredLog}
+		if !rootArgs.prettyLog {
+			zconfig.PartsExclude = []string{
+				zerolog.TimestampFieldName,
+				zerolog.LevelFieldName,
+			}
 		}
+		zlog = zerolog.New(zconfig).With().Timestamp().Logger()
 	}
 
-	zlog := zerolog.New(zconfig).With().Timestamp().Logger()
-
 	// Discard the container registry client logger.
 	gcrLog.Warn.SetOutput(io.Discard)
 
@@ -79,6 +92,9 @@ var (
 		ssa.DeletedAction:    color.New(color.FgRed),
 		ssa.SkippedAction:    color.New(color.FgHiBlack),
 		ssa.UnknownAction:    color.New(color.FgYellow, color.Italic),
+		orphanedAction:       color.New(color.FgYellow),
+		preservedAction:      color.New(color.FgYellow),
+		goneAction:           color.New(color.FgHiBlack),
 	}
 	colorPerStatus = map[status.Status]*color.Color{
 		status.InProgressStatus:  color.New(color.FgHiCyan, color.Italic),
@@ -108,6 +124,38 @@ func colorizeJoin(values ...any) string {
 	return sb.String()
 }
 
+// logJoin logs the given values joined as a single message. In the JSON format,
+// the object, namespace and action of the objects and change set entries found
+// in the values are also set as separate fields.
+func logJoin(log logr.Logger, values ...any) {
+	if rootArgs.logFormat != logFormatJSON {
+		log.Info(colorizeJoin(values...))
+		return
+	}
+	log.Info(colorizeJoin(values...), objectKeysAndValues(values)...)
+}
+
+// objectKeysAndValues returns the structured log fields of the objects,
+// change set entries, actions and statuses found in the given values.
+func objectKeysAndValues(values []any) []any {
+	var kv []any
+	for _, v := range values {
+		switch v := v.(type) {
+		case *unstructured.Unstructured:
+			kv = append(kv, "object", ssa.FmtUnstructured(v), "namespace", v.GetNamespace())
+		case ssa.ChangeSetEntry:
+			kv = append(kv, "object", v.Subject, "namespace", v.ObjMetadata.Namespace, "action", v.Action.String())
+		case *ssa.ChangeSetEntry:
+			kv = append(kv, "object", v.Subject, "namespace", v.ObjMetadata.Namespace, "action", v.Action.String())
+		case ssa.Action:
+			kv = append(kv, "action", v.String())
+		case status.Status:
+			kv = append(kv, "status", v.String())
+		}
+	}
+	return kv
+}
+
 func colorizeAny(v any) string {
 	switch v := v.(type) {
 	case *unstructured.Unstructured:
@@ -201,15 +249,21 @@ func colorizeCluster(cluster string) string {
 	return colorCallerPrefix.Sprint("c:") + colorInstance.Sprint(cluster)
 }
 
+// structuredLog returns true if the log values should be set as separate
+// fields instead of a colorized caller prefix.
+func structuredLog() bool {
+	return !rootArgs.prettyLog || rootArgs.logFormat == logFormatJSON
+}
+
 func LoggerBundle(ctx context.Context, bundle, cluster string) logr.Logger {
 	switch cluster {
 	case apiv1.RuntimeDefaultName:
-		if !rootArgs.prettyLog {
+		if structuredLog() {
 			return LoggerFrom(ctx, "bundle", bundle)
 		}
 		return LoggerFrom(ctx, "caller", colorizeBundle(bundle))
 	default:
-		if !rootArgs.prettyLog {
+		if structuredLog() {
 			return LoggerFrom(ctx, "bundle", bundle, "cluster", cluster)
 		}
 		return LoggerFrom(ctx, "caller",
@@ -221,7 +275,7 @@ func LoggerBundle(ctx context.Context, bundle, cluster string) logr.Logger {
 }
 
 func LoggerInstance(ctx context.Context, instance string) logr.Logger {
-	if !rootArgs.prettyLog {
+	if structuredLog() {
 		return LoggerFrom(ctx, "instance", instance)
 	}
 	return LoggerFrom(ctx, "caller", colorizeInstance(instance))
@@ -230,7 +284,7 @@ func LoggerInstance(ctx context.Context, instance string) logr.Logger {
 func LoggerBundleInstance(ctx context.Context, bundle, cluster, instance string) logr.Logger {
 	switch cluster {
 	case apiv1.RuntimeDefaultName:
-		if !rootArgs.prettyLog {
+		if structuredLog() {
 			return LoggerFrom(ctx, "bundle", bundle, "instance", instance)
 		}
 		return LoggerFrom(ctx, "caller",
@@ -239,7 +293,7 @@ func LoggerBundleInstance(ctx context.Context, bundle, cluster, instance string)
 				color.CyanString(">"),
 				colorizeInstance(instance)))
 	default:
-		if !rootArgs.prettyLog {
+		if structuredLog() {
 			return LoggerFrom(ctx, "bundle", bundle, "cluster", cluster, "instance", instance)
 		}
 		return LoggerFrom(ctx, "caller",
@@ -256,12 +310,12 @@ func LoggerBundleInstance(ctx context.Context, bundle, cluster, instance string)
 func LoggerRuntime(ctx context.Context, runtime, cluster string) logr.Logger {
 	switch cluster {
 	case apiv1.RuntimeDefaultName:
-		if !rootArgs.prettyLog {
+		if structuredLog() {
 			return LoggerFrom(ctx, "runtime", runtime)
 		}
 		return LoggerFrom(ctx, "caller", colorizeRuntime(runtime))
 	default:
-		if !rootArgs.prettyLog {
+		if structuredLog() {
 			return LoggerFrom(ctx, "runtime", runtime, "cluster", cluster)
 		}
 		return LoggerFrom(ctx, "caller",
@@ -281,10 +335,149 @@ func LoggerFrom(ctx context.Context, keysAndValues ...interface{}) logr.Logger {
 	return newLogger.WithValues(keysAndValues...)
 }
 
+// summaryActions holds the order of the actions in the change set summary,
+// and the verbs used to report them in dry run mode.
+var summaryActions = []struct {
+	action ssa.Action
+	verb   string
+}{
+	{action: ssa.CreatedAction, verb: "create"},
+	{action: ssa.ConfiguredAction, verb: "configure"},
+	{action: ssa.UnchangedAction},
+	{action: ssa.DeletedAction, verb: "delete"},
+	{action: ssa.SkippedAction, verb: "skip"},
+	{action: orphanedAction, verb: "orphan"},
+	{action: preservedAction, verb: "preserve"},
+	{action: goneAction},
+}
+
+// summarizeChangeSet returns a summary of the given change set, tallying the objects
+// by action e.g. 'Deleted: 12, Skipped: 1, Failed: 0'. In dry run mode, the actions
+// are reported as planned e.g. 'Would delete: 12, Would skip: 1'.
+func summarizeChangeSet(cs *ssa.ChangeSet, failed int, dryRun bool) string {
+	counts := make(map[ssa.Action]int)
+	for _, entry := range cs.Entries {
+		counts[entry.Action]++
+	}
+
+	capitalize := func(s string) string {
+		return strings.ToUpper(s[:1]) + s[1:]
+	}
+
+	var parts []string
+	for _, a := range summaryActions {
+		count, ok := counts[a.action]
+		if !ok {
+			continue
+		}
+		delete(counts, a.action)
+		label := a.action.String()
+		if dryRun && a.verb != "" {
+			label = "would " + a.verb
+		}
+		parts = append(parts, fmt.Sprintf("%s: %v", capitalize(label), count))
+	}
+
+	// The actions not listed in the summary order e.g. unknown, are appended sorted by name.
+	var others []string
+	for action := range counts {
+		others = append(others, action.String())
+	}
+	sort.Strings(others)
+	for _, action := range others {
+		parts = append(parts, fmt.Sprintf("%s: %v", capitalize(action), counts[ssa.Action(action)]))
+	}
+
+	if !dryRun {
+		parts = append(parts, fmt.Sprintf("Failed: %v", failed))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// isTerminal returns true if the given writer is a terminal,
+// or a DiffWriter targeting a terminal.
+func isTerminal(w io.Writer) bool {
+	if t, ok := w.(interface{ IsTerminal() bool }); ok {
+		return t.IsTerminal()
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// validateColorMode returns an error if the given color mode is not
+// 'auto', 'always' or 'never'. An empty mode is equivalent to 'auto'.
+func validateColorMode(mode string) error {
+	switch mode {
+	case "", colorAuto, colorAlways, colorNever:
+		return nil
+	default:
+		return fmt.Errorf("unsupported color mode '%s', can be '%s', '%s' or '%s'", mode, colorAuto, colorAlways, colorNever)
+	}
+}
+
+// colorEnabled returns true if the output written to the given writer should be colorized.
+// In the auto mode, the colors are enabled only for terminals and when the
+// NO_COLOR environment variable is not set, see https://no-color.org.
+func colorEnabled(mode string, w io.Writer) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	default:
+		return os.Getenv("NO_COLOR") == "" && isTerminal(w)
+	}
+}
+
 // StartSpinner starts a spinner with the given message.
+// The spinner is not started when the logs are in the JSON format.
 func StartSpinner(msg string) *spinner.Spinner {
 	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
 	s.Suffix = " " + msg
+	if rootArgs.logFormat != logFormatJSON {
+		s.Start()
+	}
+	return s
+}
+
+// StartSpinnerWithTimer starts a spinner with the given message followed by the
+// elapsed time, which ticks every second. When the logs are in the JSON format or
+// stderr is not a terminal, the message is logged once with the given logger instead.
+func StartSpinnerWithTimer(log logr.Logger, msg string) *spinner.Spinner {
+	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriter(os.Stderr))
+	s.Suffix = " " + msg
+	if rootArgs.logFormat == logFormatJSON || !isTerminal(os.Stderr) {
+		log.Info(msg)
+		return s
+	}
+
+	start := time.Now()
+	var message, last string
+	s.PreUpdate = func(s *spinner.Spinner) {
+		// The suffix differs from the last one set here when
+		// the message was replaced with UpdateSpinner.
+		if s.Suffix != last {
+			message = s.Suffix
+		}
+		s.Suffix = fmt.Sprintf("%s (%s)", message, time.Since(start).Truncate(time.Second))
+		last = s.Suffix
+	}
 	s.Start()
 	return s
 }
+
+// UpdateSpinner replaces the message of a running spinner.
+func UpdateSpinner(s *spinner.Spinner, msg string) {
+	s.Lock()
+	defer s.Unlock()
+	s.Suffix = " " + msg
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/fluxcd/cli-utils/pkg/object"
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zerologr"
+	. "github.com/onsi/gomega"
+	"github.com/rs/zerolog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeLog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestNewConsoleLogger_JSON(t *testing.T) {
+	g := NewWithT(t)
+
+	output, noColor := color.Error, color.NoColor
+	defer func() {
+		color.Error, color.NoColor = output, noColor
+		rootArgs.logFormat = logFormatText
+		runtimeLog.SetLogger(logger)
+	}()
+
+	buf := new(bytes.Buffer)
+	color.Error = buf
+	rootArgs.logFormat = logFormatJSON
+
+	log := NewConsoleLogger()
+	g.Expect(color.NoColor).To(BeTrue())
+
+	ctx := logr.NewContext(context.Background(), log)
+	LoggerInstance(ctx, "podinfo").WithValues("namespace", "apps").
+		Info(colorizeJoin("ConfigMap/apps/podinfo", "created"))
+
+	var entry map[string]interface{}
+	g.Expect(json.Unmarshal(buf.Bytes(), &entry)).To(Succeed())
+	g.Expect(entry).To(HaveKeyWithValue(zerolog.LevelFieldName, "info"))
+	g.Expect(entry).To(HaveKey(zerolog.TimestampFieldName))
+	g.Expect(entry).To(HaveKeyWithValue("instance", "podinfo"))
+	g.Expect(entry).To(HaveKeyWithValue("namespace", "apps"))
+	g.Expect(entry).To(HaveKeyWithValue(zerolog.MessageFieldName, "ConfigMap/apps/podinfo created"))
+}
+
+func TestLogJoin_JSON(t *testing.T) {
+	g := NewWithT(t)
+
+	output, noColor := color.Error, color.NoColor
+	defer func() {
+		color.Error, color.NoColor = output, noColor
+		rootArgs.logFormat = logFormatText
+		runtimeLog.SetLogger(logger)
+	}()
+
+	buf := new(bytes.Buffer)
+	color.Error = buf
+	rootArgs.logFormat = logFormatJSON
+	log := NewConsoleLogger()
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName("podinfo")
+	obj.SetNamespace("apps")
+
+	logJoin(log, obj, ssa.DeletedAction, dryRunServer)
+	change := ssa.ChangeSetEntry{
+		ObjMetadata: object.UnstructuredToObjMetadata(obj),
+		Subject:     ssa.FmtUnstructured(obj),
+		Action:      ssa.CreatedAction,
+	}
+	logJoin(log, change)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	g.Expect(lines).To(HaveLen(2))
+
+	var entry map[string]interface{}
+	g.Expect(json.Unmarshal([]byte(lines[0]), &entry)).To(Succeed())
+	g.Expect(entry).To(HaveKeyWithValue("object", "ConfigMap/apps/podinfo"))
+	g.Expect(entry).To(HaveKeyWithValue("namespace", "apps"))
+	g.Expect(entry).To(HaveKeyWithValue("action", "deleted"))
+	g.Expect(entry).To(HaveKeyWithValue(zerolog.MessageFieldName, "ConfigMap/apps/podinfo deleted (server dry run)"))
+
+	entry = nil
+	g.Expect(json.Unmarshal([]byte(lines[1]), &entry)).To(Succeed())
+	g.Expect(entry).To(HaveKeyWithValue("object", "ConfigMap/apps/podinfo"))
+	g.Expect(entry).To(HaveKeyWithValue("namespace", "apps"))
+	g.Expect(entry).To(HaveKeyWithValue("action", "created"))
+}
+
+func TestStartSpinnerWithTimer_NonTerminal(t *testing.T) {
+	g := NewWithT(t)
+
+	buf := new(bytes.Buffer)
+	zcfg := zerolog.ConsoleWriter{Out: buf, NoColor: true}
+	zcfg.PartsExclude = []string{
+		zerolog.TimestampFieldName,
+		zerolog.LevelFieldName,
+	}
+	zl := zerolog.New(zcfg)
+	log := zerologr.New(&zl)
+
+	spin := StartSpinnerWithTimer(log, "waiting for 2 resource(s) to be finalized...")
+	UpdateSpinner(spin, "waiting for 1 resource(s) to be finalized...")
+	spin.Stop()
+
+	g.Expect(spin.Active()).To(BeFalse())
+	g.Expect(strings.Count(buf.String(), "\n")).To(Equal(1))
+	g.Expect(buf.String()).To(ContainSubstring("waiting for 2 resource(s) to be finalized..."))
+}
+
+func TestSummarizeChangeSet(t *testing.T) {
+	g := NewWithT(t)
+
+	cs := ssa.NewChangeSet()
+	for _, action := range []ssa.Action{
+		ssa.DeletedAction,
+		ssa.SkippedAction,
+		ssa.DeletedAction,
+		goneAction,
+		ssa.UnknownAction,
+	} {
+		cs.Add(ssa.ChangeSetEntry{Action: action})
+	}
+
+	g.Expect(summarizeChangeSet(cs, 2, false)).To(Equal("Deleted: 2, Skipped: 1, Already deleted: 1, Unknown: 1, Failed: 2"))
+	g.Expect(summarizeChangeSet(cs, 0, true)).To(Equal("Would delete: 2, Would skip: 1, Already deleted: 1, Unknown: 1"))
+	g.Expect(summarizeChangeSet(ssa.NewChangeSet(), 0, false)).To(Equal("Failed: 0"))
+}
@@ -18,9 +18,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -29,6 +32,10 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/tools/clientcmd"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/oci"
+	"github.com/stefanprodan/timoni/internal/runtime"
 )
 
 var (
@@ -42,7 +49,14 @@ var rootCmd = &cobra.Command{
 	SilenceUsage:  true,
 	SilenceErrors: true,
 	Short:         "A package manager for Kubernetes powered by CUE.",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		switch rootArgs.logFormat {
+		case logFormatText, logFormatJSON:
+		default:
+			return fmt.Errorf("unsupported log format '%s', can be '%s' or '%s'",
+				rootArgs.logFormat, logFormatText, logFormatJSON)
+		}
+
 		// Initialize the console logger just before running
 		// a command only if one wasn't provided. This allows other
 		// callers (e.g. unit tests) to inject their own logger ahead of time.
@@ -53,6 +67,9 @@ var rootCmd = &cobra.Command{
 		// Inject the logger in the command context.
 		ctx := logr.NewContext(context.Background(), logger)
 		cmd.SetContext(ctx)
+
+		// Configure the inventory naming and the ownership labels.
+		return runtime.SetOwnership(rootArgs.inventoryPrefix, rootArgs.managedBy)
 	},
 }
 
@@ -60,15 +77,26 @@ type rootFlags struct {
 	timeout          time.Duration
 	prettyLog        bool
 	coloredLog       bool
+	logFormat        string
 	cacheDir         string
 	registryInsecure bool
+	pullRetries      int
+	pullBackoff      time.Duration
+	inventoryPrefix  string
+	managedBy        string
 }
 
 var (
 	rootArgs = rootFlags{
 		prettyLog:  true,
 		coloredLog: !color.NoColor,
+		logFormat:  logFormatText,
 		timeout:    5 * time.Minute,
+
+		pullBackoff: 2 * time.Second,
+
+		inventoryPrefix: apiv1.FieldManager,
+		managedBy:       apiv1.FieldManager,
 	}
 	logger         logr.Logger
 	kubeconfigArgs = genericclioptions.NewConfigFlags(false)
@@ -81,10 +109,20 @@ func init() {
 		"Adds timestamps to the logs.")
 	rootCmd.PersistentFlags().BoolVar(&rootArgs.coloredLog, "log-color", rootArgs.coloredLog,
 		"Adds colorized output to the logs. (defaults to false when no tty)")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.logFormat, "log-format", rootArgs.logFormat,
+		"The format of the logs, can be 'text' or 'json'. The JSON format writes one structured log entry per line, without colors and spinners.")
 	rootCmd.PersistentFlags().StringVar(&rootArgs.cacheDir, "cache-dir", "",
 		"Artifacts cache dir, can be disable with 'TIMONI_CACHING=false' env var. (defaults to \"$HOME/.timoni/cache\")")
 	rootCmd.PersistentFlags().BoolVar(&rootArgs.registryInsecure, "registry-insecure", false,
 		"If true, allows connecting to a container registry without TLS or with a self-signed certificate.")
+	rootCmd.PersistentFlags().IntVar(&rootArgs.pullRetries, "pull-retries", 0,
+		"The number of times to retry pulling an artifact when the registry returns a transient error e.g. timeouts, 5xx responses.")
+	rootCmd.PersistentFlags().DurationVar(&rootArgs.pullBackoff, "pull-retry-backoff", rootArgs.pullBackoff,
+		"The delay before the first pull retry, doubled after each attempt.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.inventoryPrefix, "inventory-prefix", rootArgs.inventoryPrefix,
+		"The prefix of the Secret name used to store the instance inventory.")
+	rootCmd.PersistentFlags().StringVar(&rootArgs.managedBy, "managed-by", rootArgs.managedBy,
+		"The field manager name and the created-by label value set on the managed resources.")
 
 	addKubeConfigFlags(rootCmd)
 
@@ -105,6 +143,11 @@ func main() {
 		// Set the logger err to nil to pretty print
 		// the error message on multiple lines.
 		logger.Error(nil, err.Error())
+
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -184,3 +227,32 @@ func getCurrentKubeconfigPath() string {
 	}
 	return defaultPath
 }
+
+// instanceNameFromArg returns the instance name from an argument in the format
+// '<name>' or '<namespace>/<name>'. When the argument contains the namespace,
+// it's used as the namespace scope for the operation.
+func instanceNameFromArg(cmd *cobra.Command, arg string) (string, error) {
+	namespace, name, found := strings.Cut(arg, "/")
+	if !found {
+		return arg, nil
+	}
+
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("invalid instance '%s', must be in the format '<name>' or '<namespace>/<name>'", arg)
+	}
+
+	if cmd.Flags().Changed("namespace") && *kubeconfigArgs.Namespace != namespace {
+		return "", fmt.Errorf("instance namespace '%s' conflicts with --namespace '%s'", namespace, *kubeconfigArgs.Namespace)
+	}
+
+	*kubeconfigArgs.Namespace = namespace
+	return name, nil
+}
+
+// pullRetryOptions returns the registry retry settings from the global flags.
+func pullRetryOptions() oci.RetryOptions {
+	return oci.RetryOptions{
+		Retries: rootArgs.pullRetries,
+		Backoff: rootArgs.pullBackoff,
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/phayes/freeport"
 	"github.com/rs/zerolog"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -117,18 +118,50 @@ func executeCommandWithIn(cmd string, in io.Reader) (string, error) {
 }
 
 func resetCmdArgs() {
-	applyArgs = applyFlags{}
-	buildArgs = buildFlags{}
-	deleteArgs = deleteFlags{}
+	applyArgs = applyFlags{
+		waitInterval: 5 * time.Second,
+		prune:        true,
+		summary:      true,
+		diffContext:  -1,
+	}
+	// The apply diff is enabled by the changed flags, which are not reset by cobra between runs.
+	applyCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		f.Changed = false
+	})
+	planArgs = planFlags{}
+	diffArgs = diffFlags{
+		context: -1,
+	}
+	buildArgs = buildFlags{
+		sort: buildSortSSA,
+	}
+	deleteArgs = deleteFlags{
+		gracePeriod: -1,
+		propagation: "background",
+		retries:     3,
+		summary:     true,
+		confirm:     deleteConfirmAlways,
+
+		includeClusterResources: true,
+		protectLabel:            defaultProtectLabel,
+	}
 	statusArgs = statusFlags{}
+	eventsArgs = eventsFlags{}
 	inspectModuleArgs = inspectModuleFlags{}
+	inspectAnnotationsArgs = inspectAnnotationsFlags{}
 	inspectResourcesArgs = inspectResourcesFlags{}
 	inspectValuesArgs = inspectValuesFlags{}
+	inspectImagesArgs = inspectImagesFlags{}
 	vetModArgs = vetModFlags{
 		name: "default",
 	}
 	listArgs = listFlags{}
 	pullModArgs = pullModFlags{}
+	saveModArgs = saveModFlags{}
+	lockModArgs = lockModFlags{}
+	reconcileArgs = reconcileFlags{
+		waitInterval: 5 * time.Second,
+	}
 	pushModArgs = pushModFlags{}
 	bundleArgs = bundleFlags{}
 	bundleApplyArgs = bundleApplyFlags{}
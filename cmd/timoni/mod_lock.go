@@ -0,0 +1,93 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/timoni/internal/engine"
+	"github.com/stefanprodan/timoni/internal/flags"
+)
+
+var lockModCmd = &cobra.Command{
+	Use:   "lock [MODULE PATH]",
+	Short: "Pin the CUE dependencies of a local module",
+	Long: `The lock command resolves the CUE packages imported by the module from 'cue.mod',
+and writes their content digests to 'cue.mod/timoni.lock'.
+
+When the lock file is present, the module build fails if any of the dependencies
+resolves to a different content than the one pinned in the lock file.
+To update the lock file after vendoring new schemas, run the lock command again.`,
+	Example: `  # Generate or update the lock file of the module in the current directory
+  timoni mod lock
+
+  # Generate or update the lock file of a module
+  timoni mod lock ./path/to/module
+`,
+	RunE: runLockModCmd,
+}
+
+type lockModFlags struct {
+	path string
+	pkg  flags.Package
+}
+
+var lockModArgs lockModFlags
+
+func init() {
+	lockModCmd.Flags().VarP(&lockModArgs.pkg, lockModArgs.pkg.Type(), lockModArgs.pkg.Shorthand(), lockModArgs.pkg.Description())
+	modCmd.AddCommand(lockModCmd)
+}
+
+func runLockModCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		lockModArgs.path = "."
+	} else {
+		lockModArgs.path = args[0]
+	}
+
+	if fs, err := os.Stat(path.Join(lockModArgs.path, "cue.mod")); err != nil || !fs.IsDir() {
+		return fmt.Errorf("cue.mod not found in the module path %s", lockModArgs.path)
+	}
+
+	log := LoggerFrom(cmd.Context())
+
+	builder := engine.NewModuleBuilder(
+		cuecontext.New(),
+		"default",
+		*kubeconfigArgs.Namespace,
+		lockModArgs.path,
+		lockModArgs.pkg.String(),
+	)
+
+	deps, err := builder.WriteLockFile()
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range deps {
+		log.Info(fmt.Sprintf("%s %s", colorizeSubject(dep.Path), colorizeInfo(dep.Digest)))
+	}
+	log.Info(fmt.Sprintf("%v dependencies locked in %s", len(deps), path.Join(lockModArgs.path, engine.LockFile)))
+
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	cp "github.com/otiai10/copy"
+
+	"github.com/stefanprodan/timoni/internal/engine"
+)
+
+func Test_LockMod(t *testing.T) {
+	g := NewWithT(t)
+	modPath := filepath.Join(t.TempDir(), "module")
+	g.Expect(cp.Copy("testdata/module", modPath)).To(Succeed())
+
+	output, err := executeCommand(fmt.Sprintf("mod lock %s -p main", modPath))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(output).To(ContainSubstring("timoni.sh/core/v1alpha1 sha256:"))
+	g.Expect(filepath.Join(modPath, engine.LockFile)).To(BeAnExistingFile())
+
+	t.Run("builds with the locked dependencies", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf("build -n default test %s -p main -o yaml", modPath))
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("fails to build with changed dependencies", func(t *testing.T) {
+		g := NewWithT(t)
+		schema := filepath.Join(modPath, "cue.mod", "pkg", "timoni.sh", "core", "v1alpha1", "image.cue")
+		f, err := os.OpenFile(schema, os.O_APPEND|os.O_WRONLY, 0)
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = f.WriteString("\n#Changed: string\n")
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(f.Close()).To(Succeed())
+
+		_, err = executeCommand(fmt.Sprintf("build -n default test %s -p main -o yaml", modPath))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("dependency timoni.sh/core/v1alpha1 resolved to"))
+	})
+}
@@ -143,7 +143,9 @@ func pullCmdRun(cmd *cobra.Command, args []string) error {
 
 	spin := StartSpinner(fmt.Sprintf("pulling %s", ociURL))
 	opts := oci.Options(ctx, pullModArgs.creds.String(), rootArgs.registryInsecure)
-	err := oci.PullArtifact(ociURL, pullModArgs.output, apiv1.AnyContentType, opts)
+	err := oci.Retry(ctx, pullRetryOptions(), func() error {
+		return oci.PullArtifact(ociURL, pullModArgs.output, apiv1.AnyContentType, opts)
+	})
 	spin.Stop()
 	if err != nil {
 		return err
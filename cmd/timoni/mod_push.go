@@ -36,7 +36,11 @@ var pushModCmd = &cobra.Command{
 	Use:   "push [MODULE PATH] [MODULE URL]",
 	Short: "Push a module to a container registry",
 	Long: `The push command packages the module as an OCI artifact and pushes it to the
-container registry using the version as the image tag.`,
+container registry using the version as the image tag.
+
+The artifact is reproducible: identical module content results in an identical digest.
+The created date annotation is set from the last Git commit date, or from the
+SOURCE_DATE_EPOCH env var when specified.`,
 	Example: `  # Push a module to Docker Hub using the credentials from '~/.docker/config.json'
   echo $DOCKER_PAT | docker login --username timoni --password-stdin
   timoni mod push ./path/to/module oci://docker.io/org/app-module -v 1.0.0
@@ -71,6 +75,11 @@ container registry using the version as the image tag.`,
   timoni mod push ./path/to/module oci://ghcr.io/org/modules/app \
 	--version=1.0.0 \
 	--sign=cosign
+
+  # Preview the artifact layers, files and digest without pushing the module
+  timoni mod push ./path/to/module oci://ghcr.io/org/modules/app \
+	--version=1.0.0 \
+	--dry-run
 `,
 	RunE: pushModCmdRun,
 }
@@ -85,6 +94,7 @@ type pushModFlags struct {
 	annotations []string
 	sign        string
 	cosignKey   string
+	dryRun      bool
 }
 
 var pushModArgs pushModFlags
@@ -103,6 +113,9 @@ func init() {
 	pushModCmd.Flags().StringVar(&pushModArgs.cosignKey, "cosign-key", "",
 		"The Cosign private key for signing the module.")
 
+	pushModCmd.Flags().BoolVar(&pushModArgs.dryRun, "dry-run", false,
+		"Package the module and print the artifact details without pushing it to the container registry.")
+
 	modCmd.AddCommand(pushModCmd)
 }
 
@@ -142,6 +155,10 @@ func pushModCmdRun(cmd *cobra.Command, args []string) error {
 	}
 	pushModArgs.ignorePaths = append(pushModArgs.ignorePaths, ps...)
 
+	if pushModArgs.dryRun {
+		return pushModDryRun(cmd, ociURL, annotations)
+	}
+
 	spin := StartSpinner("pushing module")
 	defer spin.Stop()
 
@@ -207,3 +224,51 @@ func pushModCmdRun(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func pushModDryRun(cmd *cobra.Command, ociURL string, annotations map[string]string) error {
+	log := LoggerFrom(cmd.Context())
+
+	artifact, err := oci.InspectModule(ociURL, pushModArgs.module, pushModArgs.ignorePaths, annotations)
+	if err != nil {
+		return err
+	}
+
+	switch pushModArgs.output {
+	case "json":
+		marshalled, err := json.MarshalIndent(artifact, "", "  ")
+		if err != nil {
+			return fmt.Errorf("artifact info JSON conversion failed: %w", err)
+		}
+		marshalled = append(marshalled, "\n"...)
+		cmd.OutOrStdout().Write(marshalled)
+	case "yaml":
+		marshalled, err := yaml.Marshal(artifact)
+		if err != nil {
+			return fmt.Errorf("artifact info YAML conversion failed: %w", err)
+		}
+		cmd.OutOrStdout().Write(marshalled)
+	default:
+		log.Info(colorizeJoin("artifact:", colorizeSubject(ociURL), dryRunClient))
+		log.Info(colorizeJoin("digest:", colorizeSubject(artifact.Digest), dryRunClient))
+		log.Info(colorizeJoin("size:", colorizeSubject(fmt.Sprintf("%v bytes", artifact.Size)), dryRunClient))
+		for _, layer := range artifact.Layers {
+			log.Info(colorizeJoin("layer:", colorizeSubject(layer.ContentType),
+				fmt.Sprintf("%v bytes", layer.Size), fmt.Sprintf("%v files", len(layer.Files)), dryRunClient))
+			for _, file := range layer.Files {
+				log.Info(colorizeJoin("file:", file, dryRunClient))
+			}
+		}
+		if pushModArgs.latest {
+			log.Info(colorizeJoin("tag:", colorizeSubject(apiv1.LatestVersion), dryRunClient))
+		}
+
+		marshalled, err := json.MarshalIndent(artifact.Manifest, "", "  ")
+		if err != nil {
+			return fmt.Errorf("artifact manifest JSON conversion failed: %w", err)
+		}
+		marshalled = append(marshalled, "\n"...)
+		cmd.OutOrStdout().Write(marshalled)
+	}
+
+	return nil
+}
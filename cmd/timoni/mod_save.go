@@ -0,0 +1,96 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/flags"
+	"github.com/stefanprodan/timoni/internal/oci"
+)
+
+var saveModCmd = &cobra.Command{
+	Use:   "save [MODULE URL] [FILE]",
+	Short: "Save a module version from a container registry to a tar file",
+	Long: `The save command downloads the module artifact from a container registry and
+writes it to a tar file in the OCI image layout format.
+The tar file contains the artifact manifest, digest and annotations,
+and can be used in place of the module URL with apply and build
+to install modules in air-gapped environments.`,
+	Example: `  # Save a module version to a tar file
+  timoni mod save oci://ghcr.io/org/modules/app -v 1.0.0 app.tar
+
+  # Install the module from the tar file on a disconnected machine
+  timoni apply -n apps app ./app.tar
+`,
+	RunE: runSaveModCmd,
+}
+
+type saveModFlags struct {
+	version flags.Version
+	creds   flags.Credentials
+}
+
+var saveModArgs saveModFlags
+
+func init() {
+	saveModCmd.Flags().VarP(&saveModArgs.version, saveModArgs.version.Type(), saveModArgs.version.Shorthand(), saveModArgs.version.Description())
+	saveModCmd.Flags().Var(&saveModArgs.creds, saveModArgs.creds.Type(), saveModArgs.creds.Description())
+
+	modCmd.AddCommand(saveModCmd)
+}
+
+func runSaveModCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return errors.New("module URL and file path are required")
+	}
+
+	version := saveModArgs.version.String()
+	if version == "" {
+		version = apiv1.LatestVersion
+	}
+	ociURL := fmt.Sprintf("%s:%s", args[0], version)
+	dstFile := args[1]
+
+	log := LoggerFrom(cmd.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	spin := StartSpinner(fmt.Sprintf("saving %s", ociURL))
+	opts := oci.Options(ctx, saveModArgs.creds.String(), rootArgs.registryInsecure)
+	var mr *apiv1.ModuleReference
+	err := oci.Retry(ctx, pullRetryOptions(), func() error {
+		var err error
+		mr, err = oci.SaveModule(ociURL, dstFile, opts)
+		return err
+	})
+	spin.Stop()
+	if err != nil {
+		return err
+	}
+
+	log.Info(fmt.Sprintf("saved: %s", colorizeSubject(fmt.Sprintf("%s@%s", mr.Repository, mr.Digest))))
+	log.Info(fmt.Sprintf("file: %s", colorizeSubject(dstFile)))
+
+	return nil
+}
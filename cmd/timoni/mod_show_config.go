@@ -98,6 +98,7 @@ func runConfigShowModCmd(cmd *cobra.Command, args []string) error {
 		"",
 		rootArgs.registryInsecure,
 	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
 	mod, err := fetcher.Fetch()
 	if err != nil {
 		return err
@@ -18,11 +18,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 
 	"cuelang.org/go/cue/cuecontext"
+	cueerrors "cuelang.org/go/cue/errors"
 	"cuelang.org/go/pkg/strings"
 	"github.com/fluxcd/pkg/ssa"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -45,6 +48,9 @@ var vetModCmd = &cobra.Command{
 
   # validate module using debug values
   timoni mod vet ./path/to/module --debug
+
+  # print the findings as JSON and fail on warnings
+  timoni mod vet ./path/to/module --output=json --strict
 `,
 	RunE: runVetModCmd,
 }
@@ -52,9 +58,12 @@ var vetModCmd = &cobra.Command{
 type vetModFlags struct {
 	path        string
 	pkg         flags.Package
+	tags        flags.Tags
 	debug       bool
 	valuesFiles []string
 	name        string
+	output      string
+	strict      bool
 }
 
 var vetModArgs vetModFlags
@@ -62,13 +71,86 @@ var vetModArgs vetModFlags
 func init() {
 	vetModCmd.Flags().StringVar(&vetModArgs.name, "name", "default", "Name of the instance used to build the module")
 	vetModCmd.Flags().VarP(&vetModArgs.pkg, vetModArgs.pkg.Type(), vetModArgs.pkg.Shorthand(), vetModArgs.pkg.Description())
+	vetModCmd.Flags().Var(&vetModArgs.tags, vetModArgs.tags.Type(), vetModArgs.tags.Description())
 	vetModCmd.Flags().BoolVar(&vetModArgs.debug, "debug", false,
 		"Use debug_values.cue if found in the module root instead of the default values.")
 	vetModCmd.Flags().StringSliceVarP(&vetModArgs.valuesFiles, "values", "f", nil,
 		"The local path to values files (cue, yaml or json format).")
+	vetModCmd.Flags().StringVarP(&vetModArgs.output, "output", "o", "",
+		"The format in which the findings should be printed, can be 'json'.")
+	vetModCmd.Flags().BoolVar(&vetModArgs.strict, "strict", false,
+		"Fail the validation if any warnings are found.")
 	modCmd.AddCommand(vetModCmd)
 }
 
+const (
+	vetSeverityError   = "error"
+	vetSeverityWarning = "warning"
+	vetSeverityInfo    = "info"
+)
+
+// vetFinding holds the result of a single check performed by mod vet.
+type vetFinding struct {
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Object   string `json:"object,omitempty"`
+}
+
+// cueErrorFindings converts the CUE evaluation errors to findings,
+// with the file paths made relative to the module root.
+func cueErrorFindings(moduleRoot string, err error) []vetFinding {
+	var findings []vetFinding
+	for _, e := range cueerrors.Errors(err) {
+		f := vetFinding{
+			Severity: vetSeverityError,
+			Rule:     "cue-eval",
+			Message:  e.Error(),
+		}
+		for _, pos := range cueerrors.Positions(e) {
+			if !pos.IsValid() || pos.Filename() == "" {
+				continue
+			}
+			f.File = pos.Filename()
+			if rel, err := filepath.Rel(moduleRoot, f.File); err == nil {
+				f.File = rel
+			}
+			f.Line = pos.Line()
+			break
+		}
+		findings = append(findings, f)
+	}
+	if len(findings) == 0 {
+		findings = append(findings, vetFinding{
+			Severity: vetSeverityError,
+			Rule:     "cue-eval",
+			Message:  err.Error(),
+		})
+	}
+	return findings
+}
+
+// printVetFindings writes the findings to stdout in the format
+// specified with --output, it's a no-op for the default log output.
+func printVetFindings(cmd *cobra.Command, findings []vetFinding) error {
+	if vetModArgs.output != "json" {
+		return nil
+	}
+
+	if findings == nil {
+		findings = []vetFinding{}
+	}
+	marshalled, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("findings JSON conversion failed: %w", err)
+	}
+	marshalled = append(marshalled, "\n"...)
+	cmd.OutOrStdout().Write(marshalled)
+	return nil
+}
+
 func runVetModCmd(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		vetModArgs.path = "."
@@ -80,6 +162,12 @@ func runVetModCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("module not found at path %s", vetModArgs.path)
 	}
 
+	switch vetModArgs.output {
+	case "", "json":
+	default:
+		return fmt.Errorf("unsupported output format '%s'", vetModArgs.output)
+	}
+
 	log := LoggerFrom(cmd.Context())
 	cuectx := cuecontext.New()
 
@@ -101,12 +189,13 @@ func runVetModCmd(cmd *cobra.Command, args []string) error {
 		"",
 		rootArgs.registryInsecure,
 	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
 	mod, err := fetcher.Fetch()
 	if err != nil {
 		return err
 	}
 
-	var tags []string
+	tags := append([]string{}, vetModArgs.tags...)
 	if vetModArgs.debug {
 		dv := path.Join(vetModArgs.path, "debug_values.cue")
 		if _, err := os.Stat(dv); err == nil {
@@ -150,8 +239,14 @@ func runVetModCmd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	var findings []vetFinding
+
 	buildResult, err := builder.Build(tags...)
 	if err != nil {
+		findings = append(findings, cueErrorFindings(fetcher.GetModuleRoot(), err)...)
+		if pErr := printVetFindings(cmd, findings); pErr != nil {
+			return pErr
+		}
 		return describeErr(fetcher.GetModuleRoot(), "validation failed", err)
 	}
 
@@ -170,12 +265,26 @@ func runVetModCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	if len(objects) == 0 {
+		findings = append(findings, vetFinding{
+			Severity: vetSeverityError,
+			Rule:     "no-objects",
+			Message:  "no objects to apply",
+		})
+		if err := printVetFindings(cmd, findings); err != nil {
+			return err
+		}
 		return fmt.Errorf("build failed, no objects to apply")
 	}
 
 	for _, object := range objects {
 		log.Info(fmt.Sprintf("%s %s",
 			colorizeSubject(ssa.FmtUnstructured(object)), colorizeInfo("valid resource")))
+		findings = append(findings, vetFinding{
+			Severity: vetSeverityInfo,
+			Rule:     "valid-resource",
+			Message:  "valid resource",
+			Object:   ssa.FmtUnstructured(object),
+		})
 	}
 
 	images, err := builder.GetContainerImages(buildResult)
@@ -186,18 +295,58 @@ func runVetModCmd(cmd *cobra.Command, args []string) error {
 	for _, image := range images {
 		if _, err := name.ParseReference(image); err != nil {
 			log.Error(err, "invalid image")
+			findings = append(findings, vetFinding{
+				Severity: vetSeverityError,
+				Rule:     "invalid-image",
+				Message:  err.Error(),
+				Object:   image,
+			})
 			continue
 		}
 
 		if !strings.Contains(image, "@sha") {
 			log.Info(fmt.Sprintf("%s %s",
 				colorizeSubject(image), colorizeWarning("valid image (digest missing)")))
+			findings = append(findings, vetFinding{
+				Severity: vetSeverityWarning,
+				Rule:     "image-digest-missing",
+				Message:  "valid image (digest missing)",
+				Object:   image,
+			})
 		} else {
 			log.Info(fmt.Sprintf("%s %s",
 				colorizeSubject(image), colorizeInfo("valid image")))
+			findings = append(findings, vetFinding{
+				Severity: vetSeverityInfo,
+				Rule:     "valid-image",
+				Message:  "valid image",
+				Object:   image,
+			})
+		}
+	}
+
+	if err := printVetFindings(cmd, findings); err != nil {
+		return err
+	}
+
+	var errCount, warnCount int
+	for _, f := range findings {
+		switch f.Severity {
+		case vetSeverityError:
+			errCount++
+		case vetSeverityWarning:
+			warnCount++
 		}
 	}
 
+	if errCount > 0 {
+		return fmt.Errorf("validation failed with %d error(s)", errCount)
+	}
+
+	if vetModArgs.strict && warnCount > 0 {
+		return fmt.Errorf("validation failed with %d warning(s) in strict mode", warnCount)
+	}
+
 	log.Info(fmt.Sprintf("%s %s",
 		colorizeSubject(mod.Name), colorizeInfo("valid module")))
 
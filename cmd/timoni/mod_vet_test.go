@@ -159,3 +159,45 @@ func TestModVetSetNamespaceName(t *testing.T) {
 		g.Expect(err.Error()).To(ContainSubstring("cannot find package"))
 	})
 }
+
+func TestModVetOutputJSON(t *testing.T) {
+	modPath := "testdata/module"
+	valuesPath := "testdata/module-values"
+
+	t.Run("prints findings for valid module", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"mod vet %s -p main --output=json --strict",
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(output).To(ContainSubstring(`"rule": "valid-resource"`))
+		g.Expect(output).To(ContainSubstring(`"rule": "valid-image"`))
+		g.Expect(output).ToNot(ContainSubstring(`"severity": "error"`))
+	})
+
+	t.Run("prints findings with file and line for invalid values", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"mod vet %s -p main --output=json --values %s",
+			modPath, valuesPath+"/invalid.cue",
+		))
+		g.Expect(err).To(HaveOccurred())
+
+		g.Expect(output).To(ContainSubstring(`"severity": "error"`))
+		g.Expect(output).To(ContainSubstring(`"rule": "cue-eval"`))
+		g.Expect(output).To(ContainSubstring(`"line":`))
+		g.Expect(output).To(ContainSubstring("mismatched types string and bool"))
+	})
+
+	t.Run("fails with unsupported output format", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"mod vet %s -p main --output=xml",
+			modPath,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unsupported output format"))
+	})
+}
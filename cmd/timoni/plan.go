@@ -0,0 +1,369 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/engine"
+	"github.com/stefanprodan/timoni/internal/flags"
+	"github.com/stefanprodan/timoni/internal/runtime"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [INSTANCE NAME] [MODULE URL]",
+	Short: "Compute the changes of an install or upgrade and save them to a plan file",
+	Long: `The plan command builds the module, performs a server-side apply dry run
+and saves the resulting changes to a plan file. The plan can be reviewed and later
+applied with 'timoni apply --plan', which fails if the cluster state changed since
+the plan was computed.`,
+	Example: `  # Compute the upgrade plan of an instance and save it to a file
+  timoni plan -n apps app oci://docker.io/org/module -v 2.0.0 \
+  --values ./values.cue \
+  --output ./plan.json
+
+  # Apply the plan
+  timoni apply --plan ./plan.json
+`,
+	RunE: runPlanCmd,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeInstanceList(cmd, args, toComplete)
+		case 1:
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	},
+}
+
+type planFlags struct {
+	name        string
+	module      string
+	version     flags.Version
+	pkg         flags.Package
+	tags        flags.Tags
+	valuesFiles []string
+	namePrefix  string
+	output      string
+	creds       flags.Credentials
+}
+
+var planArgs planFlags
+
+func init() {
+	planCmd.Flags().VarP(&planArgs.version, planArgs.version.Type(), planArgs.version.Shorthand(), planArgs.version.Description())
+	planCmd.Flags().VarP(&planArgs.pkg, planArgs.pkg.Type(), planArgs.pkg.Shorthand(), planArgs.pkg.Description())
+	planCmd.Flags().Var(&planArgs.tags, planArgs.tags.Type(), planArgs.tags.Description())
+	planCmd.Flags().StringSliceVarP(&planArgs.valuesFiles, "values", "f", nil,
+		"The local path to values files (cue, yaml or json format).")
+	planCmd.Flags().StringVar(&planArgs.namePrefix, "name-prefix", "",
+		"Prefix the names of the generated resources, their references and their app.kubernetes.io/name and instance labels and selectors, when set without a value the instance name is used as prefix.")
+	planCmd.Flags().Lookup("name-prefix").NoOptDefVal = namePrefixInstance
+	planCmd.Flags().StringVarP(&planArgs.output, "output", "o", "",
+		"The path to the file where the plan is saved, when not specified the plan is printed to stdout.")
+	planCmd.Flags().Var(&planArgs.creds, planArgs.creds.Type(), planArgs.creds.Description())
+	rootCmd.AddCommand(planCmd)
+}
+
+const planKind = "Plan"
+
+// applyPlan holds the changes computed by the plan command,
+// to be applied with 'timoni apply --plan'.
+type applyPlan struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// Instance holds the module reference, values and inventory
+	// to be stored in the cluster after the plan is applied.
+	Instance apiv1.Instance `json:"instance"`
+
+	// ApplySets holds the objects to be created or configured, in the apply order.
+	ApplySets []engine.ResourceSet `json:"applySets"`
+
+	// Changes holds the action planned for each object, along with the
+	// resourceVersion of the live object at the time the plan was computed.
+	Changes []planChange `json:"changes"`
+}
+
+// planChange holds the planned action for an object.
+type planChange struct {
+	APIVersion      string `json:"apiVersion"`
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	Action          string `json:"action"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// object returns the metadata of the changed object as an unstructured object.
+func (c planChange) object() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(c.APIVersion)
+	u.SetKind(c.Kind)
+	u.SetNamespace(c.Namespace)
+	u.SetName(c.Name)
+	return u
+}
+
+func newPlanChange(obj *unstructured.Unstructured, action ssa.Action, resourceVersion string) planChange {
+	return planChange{
+		APIVersion:      obj.GetAPIVersion(),
+		Kind:            obj.GetKind(),
+		Namespace:       obj.GetNamespace(),
+		Name:            obj.GetName(),
+		Action:          action.String(),
+		ResourceVersion: resourceVersion,
+	}
+}
+
+func runPlanCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		return errors.New("name and module are required")
+	}
+
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	planArgs.name = name
+	planArgs.module = args[1]
+
+	log := LoggerInstance(cmd.Context(), planArgs.name)
+
+	version := planArgs.version.String()
+	if version == "" {
+		version = apiv1.LatestVersion
+	}
+
+	if strings.HasPrefix(planArgs.module, apiv1.ArtifactPrefix) {
+		log.Info(fmt.Sprintf("pulling %s:%s", planArgs.module, version))
+	} else {
+		log.Info(fmt.Sprintf("building %s", planArgs.module))
+	}
+
+	tmpDir, err := os.MkdirTemp("", apiv1.FieldManager)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctxPull, cancel := context.WithTimeout(context.Background(), rootArgs.timeout)
+	defer cancel()
+
+	fetcher := engine.NewFetcher(
+		ctxPull,
+		planArgs.module,
+		version,
+		tmpDir,
+		rootArgs.cacheDir,
+		planArgs.creds.String(),
+		rootArgs.registryInsecure,
+	)
+	fetcher.SetRetries(rootArgs.pullRetries, rootArgs.pullBackoff)
+	mod, err := fetcher.Fetch()
+	if err != nil {
+		return err
+	}
+
+	builder := engine.NewModuleBuilder(
+		cuecontext.New(),
+		planArgs.name,
+		*kubeconfigArgs.Namespace,
+		fetcher.GetModuleRoot(),
+		planArgs.pkg.String(),
+	)
+
+	if err := builder.WriteSchemaFile(); err != nil {
+		return err
+	}
+
+	mod.Name, err = builder.GetModuleName()
+	if err != nil {
+		return err
+	}
+
+	log.Info(fmt.Sprintf("using module %s version %s", mod.Name, mod.Version))
+
+	if len(planArgs.valuesFiles) > 0 {
+		valuesCue, err := convertToCue(cmd, planArgs.valuesFiles)
+		if err != nil {
+			return err
+		}
+		if err := builder.MergeValuesFile(valuesCue); err != nil {
+			return err
+		}
+	}
+
+	kubeVersion, err := runtime.ServerVersion(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	builder.SetVersionInfo(mod.Version, kubeVersion)
+
+	buildResult, err := builder.Build(planArgs.tags...)
+	if err != nil {
+		return describeErr(fetcher.GetModuleRoot(), "build failed", err)
+	}
+
+	finalValues, err := builder.GetDefaultValues()
+	if err != nil {
+		return fmt.Errorf("failed to extract values: %w", err)
+	}
+
+	applySets, err := builder.GetApplySets(buildResult)
+	if err != nil {
+		return fmt.Errorf("failed to extract objects: %w", err)
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, set := range applySets {
+		objects = append(objects, set.Objects...)
+	}
+
+	if planArgs.namePrefix != "" {
+		runtime.PrefixNames(objects, namePrefix(planArgs.namePrefix, planArgs.name))
+	}
+
+	rm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	rm.SetOwnerLabels(objects, planArgs.name, *kubeconfigArgs.Namespace)
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), rootArgs.timeout)
+	defer cancel()
+
+	sm := runtime.NewStorageManager(rm)
+	nsExists, err := sm.NamespaceExists(ctx, *kubeconfigArgs.Namespace)
+	if err != nil {
+		return fmt.Errorf("instance init failed: %w", err)
+	}
+
+	im := runtime.NewInstanceManager(planArgs.name, *kubeconfigArgs.Namespace, finalValues, *mod)
+	if kubeContext, err := runtime.KubeContext(kubeconfigArgs); err == nil {
+		im.Instance.ClusterContext = kubeContext
+	}
+	if images, err := builder.GetContainerImages(buildResult); err == nil {
+		im.Instance.Images = images
+	}
+
+	// AddObjects sorts the given slice, the apply order is kept by the apply sets.
+	if err := im.AddObjects(append([]*unstructured.Unstructured{}, objects...)); err != nil {
+		return fmt.Errorf("adding objects to instance failed: %w", err)
+	}
+
+	staleObjects, err := sm.GetStaleObjects(ctx, &im.Instance)
+	if err != nil {
+		return fmt.Errorf("getting stale objects failed: %w", err)
+	}
+
+	plan := applyPlan{
+		APIVersion: apiv1.GroupVersion.String(),
+		Kind:       planKind,
+		Instance:   im.Instance,
+	}
+
+	for _, set := range applySets {
+		planSet := engine.ResourceSet{Name: set.Name}
+		for _, obj := range set.Objects {
+			change, err := planObject(ctx, rm, obj, nsExists)
+			if err != nil {
+				return err
+			}
+			logJoin(log, obj, change.Action)
+			plan.Changes = append(plan.Changes, change)
+			if change.Action != ssa.UnchangedAction.String() {
+				planSet.Objects = append(planSet.Objects, obj)
+			}
+		}
+		plan.ApplySets = append(plan.ApplySets, planSet)
+	}
+
+	for _, obj := range staleObjects {
+		live, err := liveMetadata(ctx, rm, obj)
+		if err != nil {
+			return err
+		}
+		if live == nil {
+			continue
+		}
+		logJoin(log, obj, ssa.DeletedAction)
+		plan.Changes = append(plan.Changes, newPlanChange(obj, ssa.DeletedAction, live.GetResourceVersion()))
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("converting plan failed: %w", err)
+	}
+	data = append(data, '\n')
+
+	if planArgs.output == "" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+
+	if err := os.WriteFile(planArgs.output, data, 0600); err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("plan saved to %s", colorizeSubject(planArgs.output)))
+
+	return nil
+}
+
+// planObject performs a server-side apply dry run of the given object and
+// returns the planned action along with the live object resourceVersion.
+func planObject(ctx context.Context, rm *ssa.ResourceManager, obj *unstructured.Unstructured, nsExists bool) (planChange, error) {
+	if !nsExists {
+		return newPlanChange(obj, ssa.CreatedAction, ""), nil
+	}
+
+	live, err := liveMetadata(ctx, rm, obj)
+	if err != nil {
+		return planChange{}, err
+	}
+	if live == nil {
+		return newPlanChange(obj, ssa.CreatedAction, ""), nil
+	}
+
+	change, _, _, err := rm.Diff(ctx, obj, ssa.DefaultDiffOptions())
+	if err != nil {
+		// The objects with immutable field changes are recreated at apply if force is enabled.
+		if ssa.IsImmutableError(err) && ssa.AnyInMetadata(obj, map[string]string{
+			apiv1.ForceAction: apiv1.EnabledValue,
+		}) {
+			return newPlanChange(obj, ssa.ConfiguredAction, live.GetResourceVersion()), nil
+		}
+		return planChange{}, fmt.Errorf("%s dry run failed: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	return newPlanChange(obj, change.Action, live.GetResourceVersion()), nil
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestPlan(t *testing.T) {
+	modPath := "testdata/module"
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+
+	serverCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
+		},
+	}
+
+	t.Run("plans and applies install", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"plan -n %s %s %s -p main -o %s",
+			namespace,
+			name,
+			modPath,
+			planFile,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server created", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).To(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf("apply --plan %s", planFile))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		output, err = executeCommand(fmt.Sprintf("inspect values -n %s %s", namespace, name))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring("example.internal"))
+	})
+
+	t.Run("plans and applies upgrade", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"plan -n %s %s %s -p main -f testdata/module-values/example.com.cue -o %s",
+			namespace,
+			name,
+			modPath,
+			planFile,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server configured", namespace, name)))
+
+		_, err = executeCommand(fmt.Sprintf("apply --plan %s", planFile))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(serverCM.Data["hostname"]).To(Equal("example.com"))
+	})
+
+	t.Run("fails if the cluster state changed", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := executeCommand(fmt.Sprintf(
+			"plan -n %s %s %s -p main -o %s",
+			namespace,
+			name,
+			modPath,
+			planFile,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+		serverCM.Data["port"] = "8080"
+		g.Expect(envTestClient.Update(context.Background(), serverCM)).To(Succeed())
+
+		_, err = executeCommand(fmt.Sprintf("apply --plan %s", planFile))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("the cluster state changed since the plan was computed"))
+		g.Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server was modified", namespace, name)))
+	})
+}
+
+func TestApplyPlan_Invalid(t *testing.T) {
+	g := NewWithT(t)
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	g.Expect(os.WriteFile(planFile, []byte(`{"apiVersion": "v1", "kind": "ConfigMap"}`), 0644)).To(Succeed())
+
+	_, err := executeCommand(fmt.Sprintf("apply --plan %s", planFile))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("expected kind Plan"))
+
+	_, err = executeCommand(fmt.Sprintf("apply app ./module --plan %s", planFile))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("read from the plan file"))
+}
+
+func TestVerifyPlan(t *testing.T) {
+	live := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		change  planChange
+		wantErr string
+	}{
+		{
+			name:    "fails if a planned create exists",
+			change:  planChange{Action: ssa.CreatedAction.String()},
+			wantErr: "ConfigMap/default/app planned for creation already exists",
+		},
+		{
+			name:   "passes if the resourceVersion matches",
+			change: planChange{Action: ssa.ConfiguredAction.String(), ResourceVersion: "999"},
+		},
+		{
+			name:    "fails if the resourceVersion differs",
+			change:  planChange{Action: ssa.ConfiguredAction.String(), ResourceVersion: "1"},
+			wantErr: "ConfigMap/default/app was modified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := fake.NewClientBuilder().WithObjects(live.DeepCopy()).Build()
+			rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+			change := tt.change
+			change.APIVersion = "v1"
+			change.Kind = "ConfigMap"
+			change.Namespace = "default"
+			change.Name = "app"
+
+			err := verifyPlan(context.Background(), rm, &applyPlan{Changes: []planChange{change}})
+			if tt.wantErr == "" {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+
+	t.Run("passes if a planned create is still absent", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().Build()
+		rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+		change := planChange{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "app", Action: ssa.CreatedAction.String()}
+		g.Expect(verifyPlan(context.Background(), rm, &applyPlan{Changes: []planChange{change}})).To(Succeed())
+	})
+}
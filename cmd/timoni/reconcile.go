@@ -0,0 +1,165 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+	"github.com/stefanprodan/timoni/internal/flags"
+	"github.com/stefanprodan/timoni/internal/runtime"
+)
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile [INSTANCE NAME]",
+	Short: "Rebuild a module instance from its stored source and reapply it",
+	Long: `The reconcile command rebuilds a module instance using the module reference and
+the values stored in the instance inventory, then reapplies it on the Kubernetes cluster.
+
+The reconcile command performs the following steps:
+
+- Reads the module repository, digest, values and annotations from the instance inventory.
+- Pulls the module at the last applied digest from the container registry.
+- Builds the module with the stored values and applies all the resulting resources on the cluster,
+  correcting any drift from the last applied state.
+- Waits for the applied resources to become ready and prunes the stale ones, same as the apply command.
+`,
+	Example: `  # Reconcile an instance to correct the drift from the last applied state
+  timoni -n apps reconcile app
+
+  # Do a dry-run reconcile and print the diff
+  timoni -n apps reconcile app --dry-run --diff
+`,
+	RunE: runReconcileCmd,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		switch len(args) {
+		case 0:
+			return completeInstanceList(cmd, args, toComplete)
+		default:
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	},
+}
+
+type reconcileFlags struct {
+	pkg          flags.Package
+	tags         flags.Tags
+	dryrun       bool
+	diff         bool
+	wait         bool
+	waitInterval time.Duration
+	force        bool
+	creds        flags.Credentials
+}
+
+var reconcileArgs reconcileFlags
+
+func init() {
+	reconcileCmd.Flags().VarP(&reconcileArgs.pkg, reconcileArgs.pkg.Type(), reconcileArgs.pkg.Shorthand(), reconcileArgs.pkg.Description())
+	reconcileCmd.Flags().Var(&reconcileArgs.tags, reconcileArgs.tags.Type(), reconcileArgs.tags.Description())
+	reconcileCmd.Flags().BoolVar(&reconcileArgs.force, "force", false,
+		"Recreate immutable Kubernetes resources.")
+	reconcileCmd.Flags().BoolVar(&reconcileArgs.dryrun, "dry-run", false,
+		"Perform a server-side apply dry run.")
+	reconcileCmd.Flags().BoolVar(&reconcileArgs.diff, "diff", false,
+		"Perform a server-side apply dry run and prints the diff.")
+	reconcileCmd.Flags().BoolVar(&reconcileArgs.wait, "wait", true,
+		"Wait for the applied Kubernetes objects to become ready.")
+	reconcileCmd.Flags().DurationVar(&reconcileArgs.waitInterval, "wait-interval", 5*time.Second,
+		"The interval at which the readiness of the applied Kubernetes objects is polled.")
+	reconcileCmd.Flags().Var(&reconcileArgs.creds, reconcileArgs.creds.Type(), reconcileArgs.creds.Description())
+	rootCmd.AddCommand(reconcileCmd)
+}
+
+func runReconcileCmd(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("name is required")
+	}
+
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	rm, err := runtime.NewResourceManager(kubeconfigArgs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), rootArgs.timeout)
+	defer cancel()
+
+	sm := runtime.NewStorageManager(rm)
+	instance, err := sm.Get(ctx, name, *kubeconfigArgs.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(instance.Module.Repository, apiv1.ArtifactPrefix) {
+		return fmt.Errorf("instance %s can't be reconciled, the module %s was not pulled from a container registry",
+			name, instance.Module.Repository)
+	}
+
+	if instance.Module.Digest == "" {
+		return fmt.Errorf("instance %s can't be reconciled, the module digest is missing from the inventory", name)
+	}
+
+	tmpDir, err := os.MkdirTemp("", apiv1.FieldManager)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	valuesFile := filepath.Join(tmpDir, "values.cue")
+	values := fmt.Sprintf("%s: %s\n", apiv1.ValuesSelector, instance.Values)
+	if err := os.WriteFile(valuesFile, []byte(values), os.ModePerm); err != nil {
+		return err
+	}
+
+	var annotations []string
+	for k, v := range instance.Annotations {
+		annotations = append(annotations, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(annotations)
+
+	// Drive the apply command with the stored source, pinned to the last applied digest.
+	// All objects are reapplied to correct the drift of the ones unchanged since the last apply.
+	applyArgs = applyFlags{
+		version:      flags.Version("@" + instance.Module.Digest),
+		pkg:          reconcileArgs.pkg,
+		tags:         reconcileArgs.tags,
+		valuesFiles:  []string{valuesFile},
+		dryrun:       reconcileArgs.dryrun,
+		diff:         reconcileArgs.diff,
+		wait:         reconcileArgs.wait,
+		waitInterval: reconcileArgs.waitInterval,
+		force:        reconcileArgs.force,
+		forceReapply: true,
+		annotations:  annotations,
+		creds:        reconcileArgs.creds,
+	}
+
+	return runApplyCmd(cmd, []string{name, instance.Module.Repository})
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReconcile(t *testing.T) {
+	g := NewWithT(t)
+	modPath := "testdata/module"
+	modURL := fmt.Sprintf("oci://%s/%s", dockerRegistry, rnd("my-mod", 5))
+	name := rnd("my-instance", 5)
+	namespace := rnd("my-namespace", 5)
+
+	_, err := executeCommand(fmt.Sprintf(
+		"mod push %s %s -v 1.0.0",
+		modPath,
+		modURL,
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = executeCommand(fmt.Sprintf(
+		"apply -n %s %s %s -v 1.0.0 -p main -f %s --wait",
+		namespace,
+		name,
+		modURL,
+		modPath+"-values/example.com.cue",
+	))
+	g.Expect(err).ToNot(HaveOccurred())
+
+	serverCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-server", name),
+			Namespace: namespace,
+		},
+	}
+	err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+	g.Expect(err).ToNot(HaveOccurred())
+	hostname := serverCM.Data["hostname"]
+
+	t.Run("corrects drift with the stored values", func(t *testing.T) {
+		g := NewWithT(t)
+		serverCM.Data["hostname"] = "drift.local"
+		err := envTestClient.Update(context.Background(), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		output, err := executeCommand(fmt.Sprintf(
+			"reconcile -n %s %s -p main --wait",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		t.Log("\n", output)
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server configured", namespace, name)))
+
+		err = envTestClient.Get(context.Background(), client.ObjectKeyFromObject(serverCM), serverCM)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(serverCM.Data["hostname"]).To(BeEquivalentTo(hostname))
+	})
+
+	t.Run("fails for local modules", func(t *testing.T) {
+		g := NewWithT(t)
+		localName := rnd("my-instance", 5)
+		_, err := executeCommand(fmt.Sprintf(
+			"apply -n %s %s %s -p main --wait",
+			namespace,
+			localName,
+			modPath,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = executeCommand(fmt.Sprintf(
+			"reconcile -n %s %s",
+			namespace,
+			localName,
+		))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("was not pulled from a container registry"))
+	})
+}
@@ -18,10 +18,12 @@ package main
 
 import (
 	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
 
 	"github.com/fluxcd/cli-utils/pkg/kstatus/status"
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/spf13/cobra"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,6 +38,12 @@ var statusCmd = &cobra.Command{
 	Short: "Displays the current status of Kubernetes resources managed by an instance",
 	Example: `  # Show the current status of the managed resources
   timoni -n apps status app
+
+  # Show the current status of an instance by specifying its namespace in the name
+  timoni status apps/app
+
+  # Write the readiness of the managed resources as a JUnit XML report
+  timoni -n apps status app --output=junit > status-report.xml
 `,
 	RunE: runStatusCmd,
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -49,21 +57,45 @@ var statusCmd = &cobra.Command{
 }
 
 type statusFlags struct {
-	name string
+	name   string
+	output string
 }
 
 var statusArgs statusFlags
 
 func init() {
+	statusCmd.Flags().StringVarP(&statusArgs.output, "output", "o", "",
+		"The format in which the status should be printed, can be 'junit'.")
 	rootCmd.AddCommand(statusCmd)
 }
 
+// objectStatus holds the readiness of a managed object.
+type objectStatus struct {
+	object  string
+	status  string
+	message string
+}
+
+func (s objectStatus) ready() bool {
+	return s.status == status.CurrentStatus.String()
+}
+
 func runStatusCmd(cmd *cobra.Command, args []string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("instance name is required")
 	}
 
-	statusArgs.name = args[0]
+	name, err := instanceNameFromArg(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	statusArgs.name = name
+
+	switch statusArgs.output {
+	case "", "junit":
+	default:
+		return fmt.Errorf("unsupported output format '%s'", statusArgs.output)
+	}
 
 	log := LoggerInstance(cmd.Context(), statusArgs.name)
 	rm, err := runtime.NewResourceManager(kubeconfigArgs)
@@ -87,6 +119,9 @@ func runStatusCmd(cmd *cobra.Command, args []string) error {
 	log.Info(fmt.Sprintf("digest %s",
 		colorizeSubject(instance.Module.Digest)))
 
+	log.Info(fmt.Sprintf("instance digest %s",
+		colorizeSubject((&runtime.InstanceManager{Instance: *instance}).Digest())))
+
 	for _, image := range instance.Images {
 		log.Info(fmt.Sprintf("container image %s",
 			colorizeSubject(image)))
@@ -99,24 +134,94 @@ func runStatusCmd(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	var results []objectStatus
 	for _, obj := range objects {
 		err = rm.Client().Get(ctx, client.ObjectKeyFromObject(obj), obj)
 		if err != nil {
 			if apierrors.IsNotFound(err) {
 				log.Error(err, colorizeJoin(obj, errors.New("NotFound")))
+				results = append(results, objectStatus{ssa.FmtUnstructured(obj), "NotFound", err.Error()})
 				continue
 			}
 			log.Error(err, colorizeJoin(obj, errors.New("Unknown")))
+			results = append(results, objectStatus{ssa.FmtUnstructured(obj), "Unknown", err.Error()})
 			continue
 		}
 
 		res, err := status.Compute(obj)
 		if err != nil {
 			log.Error(err, colorizeJoin(obj, errors.New("Failed")))
+			results = append(results, objectStatus{ssa.FmtUnstructured(obj), "Failed", err.Error()})
 			continue
 		}
-		log.Info(colorizeJoin(obj, res.Status, "-", res.Message))
+		logJoin(log, obj, res.Status, "-", res.Message)
+		results = append(results, objectStatus{ssa.FmtUnstructured(obj), res.Status.String(), res.Message})
+	}
+
+	if statusArgs.output == "junit" {
+		suite := fmt.Sprintf("%s/%s", *kubeconfigArgs.Namespace, statusArgs.name)
+		report, err := junitStatusReport(suite, results)
+		if err != nil {
+			return err
+		}
+		cmd.OutOrStdout().Write(report)
 	}
 
 	return nil
 }
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitStatusReport encodes the objects readiness as a JUnit XML report,
+// with one test case per object that fails if the object is not ready.
+func junitStatusReport(suiteName string, results []objectStatus) ([]byte, error) {
+	suite := junitTestSuite{
+		Name:  suiteName,
+		Tests: len(results),
+	}
+
+	for _, res := range results {
+		tc := junitTestCase{
+			Name:      res.object,
+			Classname: suiteName,
+		}
+		if !res.ready() {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: res.message,
+				Type:    res.status,
+				Text:    fmt.Sprintf("%s %s - %s", res.object, res.status, res.message),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	report, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("status JUnit conversion failed: %w", err)
+	}
+
+	return append([]byte(xml.Header), append(report, "\n"...)...), nil
+}
@@ -68,6 +68,30 @@ func TestInstanceStatus(t *testing.T) {
 		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server Current", namespace, name)))
 	})
 
+	t.Run("ready status with namespace in instance name", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"status %s/%s",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-client Current", namespace, name)))
+	})
+
+	t.Run("junit report", func(t *testing.T) {
+		g := NewWithT(t)
+		output, err := executeCommand(fmt.Sprintf(
+			"status -n %s %s -o junit",
+			namespace,
+			name,
+		))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf(`<testsuite name="%s/%s" tests="2" failures="0">`, namespace, name)))
+		g.Expect(output).To(ContainSubstring(fmt.Sprintf(`<testcase name="ConfigMap/%s/%s-client"`, namespace, name)))
+	})
+
 	t.Run("not found status", func(t *testing.T) {
 		g := NewWithT(t)
 
@@ -96,3 +120,37 @@ func TestInstanceStatus(t *testing.T) {
 		g.Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/%s-server NotFound", namespace, name)))
 	})
 }
+
+func Test_junitStatusReport(t *testing.T) {
+	g := NewWithT(t)
+
+	report, err := junitStatusReport("apps/app", []objectStatus{
+		{object: "ConfigMap/apps/app-client", status: "Current", message: "Resource is always ready"},
+		{object: "ConfigMap/apps/app-server", status: "NotFound", message: "configmaps \"app-server\" not found"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(string(report)).To(HavePrefix(`<?xml version="1.0" encoding="UTF-8"?>`))
+	g.Expect(string(report)).To(ContainSubstring(`<testsuite name="apps/app" tests="2" failures="1">`))
+	g.Expect(string(report)).To(ContainSubstring(`<testcase name="ConfigMap/apps/app-client" classname="apps/app"></testcase>`))
+	g.Expect(string(report)).To(ContainSubstring(`<failure message="configmaps &#34;app-server&#34; not found" type="NotFound">`))
+}
+
+func Test_instanceNameFromArg(t *testing.T) {
+	g := NewWithT(t)
+	defer func(ns string) { *kubeconfigArgs.Namespace = ns }(*kubeconfigArgs.Namespace)
+
+	name, err := instanceNameFromArg(statusCmd, "app")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(name).To(Equal("app"))
+
+	name, err = instanceNameFromArg(statusCmd, "apps/app")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(name).To(Equal("app"))
+	g.Expect(*kubeconfigArgs.Namespace).To(Equal("apps"))
+
+	for _, arg := range []string{"/app", "apps/", "apps/app/test"} {
+		_, err = instanceNameFromArg(statusCmd, arg)
+		g.Expect(err).To(HaveOccurred(), arg)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/fluxcd/pkg/ssa"
+	goyaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// FieldComments holds the CUE doc comments of a Kubernetes object indexed by field path.
+type FieldComments map[string]string
+
+// GetResourcesComments extracts the doc comments of the Kubernetes objects
+// found in the given resource sets. The result is indexed by the object
+// identifier in the format '<kind>/<namespace>/<name>'.
+// Comments originating from the vendored schemas in 'cue.mod' are excluded.
+func GetResourcesComments(value cue.Value) (map[string]FieldComments, error) {
+	result := make(map[string]FieldComments)
+	iter, err := value.Fields(cue.Concrete(true), cue.Final())
+	if err != nil {
+		return nil, fmt.Errorf("getting resources failed: %w", err)
+	}
+
+	for iter.Next() {
+		items, err := iter.Value().List()
+		if err != nil {
+			return nil, fmt.Errorf("listing objects in resource list %q failed: %w", iter.Selector().String(), err)
+		}
+		for items.Next() {
+			var obj map[string]interface{}
+			if err := items.Value().Decode(&obj); err != nil {
+				return nil, fmt.Errorf("decoding object failed: %w", err)
+			}
+			comments := make(FieldComments)
+			walkComments(items.Value(), "", comments)
+			if len(comments) > 0 {
+				result[ssa.FmtUnstructured(&unstructured.Unstructured{Object: obj})] = comments
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// EncodeYAMLWithComments marshals the given object to YAML
+// and sets the comments as head comments on the matching fields.
+func EncodeYAMLWithComments(obj *unstructured.Unstructured, comments FieldComments) ([]byte, error) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(comments) == 0 {
+		return data, nil
+	}
+
+	var doc goyaml.Node
+	if err := goyaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	for _, n := range doc.Content {
+		setComments(n, "", comments)
+	}
+
+	var buf bytes.Buffer
+	enc := goyaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func walkComments(v cue.Value, path string, comments FieldComments) {
+	var lines []string
+	for _, d := range v.Doc() {
+		if strings.Contains(filepath.ToSlash(d.Pos().Filename()), "cue.mod/") {
+			continue
+		}
+		// Skip the fields marked as undocumented and strip the doc directives.
+		if strings.Contains(d.Text(), "+nodoc") {
+			lines = nil
+			break
+		}
+		text := strings.ReplaceAll(d.Text(), "+required", "")
+		text = strings.ReplaceAll(text, "+optional", "")
+		if text = strings.TrimSpace(text); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	if len(lines) > 0 && path != "" {
+		comments[path] = strings.Join(lines, "\n")
+	}
+
+	switch v.IncompleteKind() {
+	case cue.StructKind:
+		iter, err := v.Fields()
+		if err != nil {
+			return
+		}
+		for iter.Next() {
+			walkComments(iter.Value(), commentPath(path, iter.Selector().Unquoted()), comments)
+		}
+	case cue.ListKind:
+		iter, err := v.List()
+		if err != nil {
+			return
+		}
+		for i := 0; iter.Next(); i++ {
+			walkComments(iter.Value(), commentPath(path, fmt.Sprintf("[%d]", i)), comments)
+		}
+	}
+}
+
+func setComments(n *goyaml.Node, path string, comments FieldComments) {
+	switch n.Kind {
+	case goyaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			p := commentPath(path, key.Value)
+			if c, ok := comments[p]; ok {
+				key.HeadComment = c
+			}
+			setComments(val, p, comments)
+		}
+	case goyaml.SequenceNode:
+		for i, item := range n.Content {
+			setComments(item, commentPath(path, fmt.Sprintf("[%d]", i)), comments)
+		}
+	}
+}
+
+func commentPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "\x00" + key
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestEncodeYAMLWithComments(t *testing.T) {
+	g := NewWithT(t)
+	ctx := cuecontext.New()
+
+	value := ctx.CompileString(`
+#Config: {
+	// The number of pods.
+	replicas: int
+}
+app: [{
+	apiVersion: "v1"
+	kind:       "ConfigMap"
+	metadata: {
+		name:      "test"
+		namespace: "default"
+	}
+	// Holds the app settings.
+	data: #Config & {
+		replicas: 2
+	}
+}]
+`)
+	g.Expect(value.Err()).ToNot(HaveOccurred())
+
+	comments, err := GetResourcesComments(value)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(comments).To(HaveKey("ConfigMap/default/test"))
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName("test")
+	obj.SetNamespace("default")
+	_ = unstructured.SetNestedField(obj.Object, int64(2), "data", "replicas")
+
+	data, err := EncodeYAMLWithComments(obj, comments["ConfigMap/default/test"])
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring("# Holds the app settings.\ndata:"))
+	g.Expect(string(data)).To(ContainSubstring("  # The number of pods.\n  replicas: 2"))
+}
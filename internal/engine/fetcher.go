@@ -23,6 +23,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 	"github.com/stefanprodan/timoni/internal/oci"
@@ -37,6 +38,7 @@ type Fetcher struct {
 	version  string
 	creds    string
 	insecure bool
+	retry    oci.RetryOptions
 }
 
 // NewFetcher creates a Fetcher for the given module.
@@ -52,6 +54,18 @@ func NewFetcher(ctx context.Context, src, version, dst, cacheDir, creds string,
 	}
 }
 
+// SetRetries configures the number of retries and the initial backoff
+// for pulling the module when the registry returns transient errors.
+func (f *Fetcher) SetRetries(retries int, backoff time.Duration) {
+	f.retry = oci.RetryOptions{Retries: retries, Backoff: backoff, Jitter: f.retry.Jitter}
+}
+
+// SetRetryJitter enables the randomization of the retry backoff,
+// to spread the registry requests of concurrent clients.
+func (f *Fetcher) SetRetryJitter(jitter bool) {
+	f.retry.Jitter = jitter
+}
+
 func (f *Fetcher) GetModuleRoot() string {
 	return filepath.Join(f.dst, "module")
 }
@@ -62,6 +76,8 @@ func (f *Fetcher) GetModuleRoot() string {
 // If the module source is a local directory, the module required
 // files are validated and the module contents is copied to the
 // destination dir while excluding files based on the timoni.ignore patters.
+// If the module source is a local file, it's expected to be an archive
+// created with 'timoni mod save' and the module contents is extracted from it.
 func (f *Fetcher) Fetch() (*apiv1.ModuleReference, error) {
 	dstDir := f.GetModuleRoot()
 
@@ -69,6 +85,10 @@ func (f *Fetcher) Fetch() (*apiv1.ModuleReference, error) {
 		return f.fetchRemoteModule(dstDir)
 	}
 
+	if fs, err := os.Stat(f.src); err == nil && fs.Mode().IsRegular() {
+		return f.fetchArchivedModule(dstDir)
+	}
+
 	return f.fetchLocalModule(dstDir)
 }
 
@@ -96,6 +116,19 @@ func (f *Fetcher) fetchLocalModule(dstDir string) (*apiv1.ModuleReference, error
 	return &mr, CopyModule(f.src, dstDir)
 }
 
+func (f *Fetcher) fetchArchivedModule(dstDir string) (*apiv1.ModuleReference, error) {
+	mr, err := oci.LoadModule(f.src, dstDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if mr.Repository == "" {
+		mr.Repository = f.src
+	}
+
+	return mr, nil
+}
+
 func (f *Fetcher) fetchRemoteModule(dstDir string) (*apiv1.ModuleReference, error) {
 	ociURL := fmt.Sprintf("%s:%s", f.src, f.version)
 	if strings.HasPrefix(f.version, "@") {
@@ -107,5 +140,12 @@ func (f *Fetcher) fetchRemoteModule(dstDir string) (*apiv1.ModuleReference, erro
 	}
 
 	opts := oci.Options(f.ctx, f.creds, f.insecure)
-	return oci.PullModule(ociURL, dstDir, f.cacheDir, opts)
+
+	var mr *apiv1.ModuleReference
+	err := oci.Retry(f.ctx, f.retry, func() error {
+		var err error
+		mr, err = oci.PullModule(ociURL, dstDir, f.cacheDir, opts)
+		return err
+	})
+	return mr, err
 }
@@ -105,6 +105,14 @@ func (b *ModuleBuilder) MergeValuesFile(overlays [][]byte) error {
 	return os.WriteFile(defaultFile, []byte(cueGen), 0644)
 }
 
+// IneffectiveValuesFiles returns the indexes of the overlays that have no effect
+// when merged on top of the module's root values.cue.
+func (b *ModuleBuilder) IneffectiveValuesFiles(overlays [][]byte) ([]int, error) {
+	vb := NewValuesBuilder(b.ctx)
+	defaultFile := filepath.Join(b.pkgPath, defaultValuesFile)
+	return vb.IneffectiveOverlays(overlays, defaultFile)
+}
+
 // WriteValuesFileWithDefaults merges the module's root values.cue with the supplied value.
 func (b *ModuleBuilder) WriteValuesFileWithDefaults(val cue.Value) error {
 	valData := []byte(fmt.Sprintf("%s: %v", apiv1.ValuesSelector.String(), val))
@@ -195,6 +203,10 @@ func (b *ModuleBuilder) Build(tags ...string) (cue.Value, error) {
 		return value, fmt.Errorf("instance error: %w", modInstance.Err)
 	}
 
+	if err := b.verifyLock(modInstance); err != nil {
+		return value, err
+	}
+
 	modValue := b.ctx.BuildInstance(modInstance)
 	if modValue.Err() != nil {
 		return value, modValue.Err()
@@ -232,6 +244,15 @@ func (b *ModuleBuilder) GetApplySets(value cue.Value) ([]ResourceSet, error) {
 	return GetResources(steps)
 }
 
+// GetApplySetsComments returns the doc comments of the Kubernetes objects to be applied.
+func (b *ModuleBuilder) GetApplySetsComments(value cue.Value) (map[string]FieldComments, error) {
+	steps := value.LookupPath(cue.ParsePath(apiv1.ApplySelector.String()))
+	if steps.Err() != nil {
+		return nil, fmt.Errorf("lookup %s failed: %w", apiv1.ApplySelector, steps.Err())
+	}
+	return GetResourcesComments(steps)
+}
+
 // GetDefaultValues extracts the default values from the module.
 func (b *ModuleBuilder) GetDefaultValues() (string, error) {
 	filePath := filepath.Join(b.pkgPath, defaultValuesFile)
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// ModuleDependency holds the details of a CUE package imported by a module.
+type ModuleDependency struct {
+	// Path is the import path of the CUE package, or the module path
+	// for the dependencies declared in 'cue.mod/module.cue' which are
+	// not vendored.
+	Path string `json:"path"`
+	// Version is the version declared for the dependency in 'cue.mod/module.cue',
+	// empty for the packages vendored in 'cue.mod' without a declared version.
+	Version string `json:"version,omitempty"`
+	// Digest is the content hash of the vendored package files.
+	Digest string `json:"digest,omitempty"`
+}
+
+// ListDependencies returns the CUE packages imported by the module from 'cue.mod'
+// and the module dependencies declared in 'cue.mod/module.cue', sorted by path.
+func (b *ModuleBuilder) ListDependencies() ([]ModuleDependency, error) {
+	resolved, err := b.ResolveDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	declared, err := b.declaredDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool, len(declared))
+	deps := make([]ModuleDependency, 0, len(resolved))
+	for _, dep := range resolved {
+		md := ModuleDependency{Path: dep.Path, Digest: dep.Digest}
+		for modPath, version := range declared {
+			if dep.Path == modPath || strings.HasPrefix(dep.Path, modPath+"/") {
+				md.Version = version
+				used[modPath] = true
+				break
+			}
+		}
+		deps = append(deps, md)
+	}
+
+	for modPath, version := range declared {
+		if !used[modPath] {
+			deps = append(deps, ModuleDependency{Path: modPath, Version: version})
+		}
+	}
+
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Path < deps[j].Path
+	})
+	return deps, nil
+}
+
+// declaredDependencies reads the 'deps' field of 'cue.mod/module.cue' and returns
+// the module paths, without the major version suffix, mapped to their versions.
+func (b *ModuleBuilder) declaredDependencies() (map[string]string, error) {
+	modFile := filepath.Join(b.moduleRoot, "cue.mod", "module.cue")
+	data, err := os.ReadFile(modFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	v := b.ctx.CompileBytes(data, cue.Filename(modFile))
+	if v.Err() != nil {
+		return nil, fmt.Errorf("failed to parse cue.mod/module.cue: %w", v.Err())
+	}
+
+	depsValue := v.LookupPath(cue.ParsePath("deps"))
+	if !depsValue.Exists() {
+		return nil, nil
+	}
+
+	iter, err := depsValue.Fields()
+	if err != nil {
+		return nil, fmt.Errorf("invalid deps in cue.mod/module.cue: %w", err)
+	}
+
+	deps := make(map[string]string)
+	for iter.Next() {
+		modPath, _, _ := strings.Cut(iter.Selector().Unquoted(), "@")
+		version, err := iter.Value().LookupPath(cue.ParsePath("v")).String()
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of %s in cue.mod/module.cue: %w", modPath, err)
+		}
+		deps[modPath] = version
+	}
+	return deps, nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	. "github.com/onsi/gomega"
+)
+
+func TestModuleBuilder_ListDependencies(t *testing.T) {
+	g := NewWithT(t)
+	moduleRoot := filepath.Join(t.TempDir(), "module")
+
+	err := CopyModule("testdata/module", moduleRoot)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	depDir := filepath.Join(moduleRoot, "cue.mod", "pkg", "example.com", "dep", "schema")
+	g.Expect(os.MkdirAll(depDir, os.ModePerm)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(depDir, "dep.cue"), []byte("package schema\n\n#Port: 8080\n"), os.ModePerm)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(moduleRoot, "dep.cue"),
+		[]byte("package main\n\nimport \"example.com/dep/schema\"\n\n_port: schema.#Port\n"), os.ModePerm)).To(Succeed())
+
+	modFile := filepath.Join(moduleRoot, "cue.mod", "module.cue")
+	modData, err := os.ReadFile(modFile)
+	g.Expect(err).ToNot(HaveOccurred())
+	modData = append(modData, []byte(`
+deps: {
+	"example.com/dep@v0": v: "v0.1.0"
+	"example.com/other@v1": v: "v1.2.3"
+}
+`)...)
+	g.Expect(os.WriteFile(modFile, modData, os.ModePerm)).To(Succeed())
+
+	mb := NewModuleBuilder(cuecontext.New(), "test-name", "test-namespace", moduleRoot, "main")
+
+	deps, err := mb.ListDependencies()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deps).To(HaveLen(2))
+
+	g.Expect(deps[0].Path).To(Equal("example.com/dep/schema"))
+	g.Expect(deps[0].Version).To(Equal("v0.1.0"))
+	g.Expect(deps[0].Digest).To(HavePrefix("sha256:"))
+
+	g.Expect(deps[1].Path).To(Equal("example.com/other"))
+	g.Expect(deps[1].Version).To(Equal("v1.2.3"))
+	g.Expect(deps[1].Digest).To(BeEmpty())
+}
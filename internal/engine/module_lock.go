@@ -0,0 +1,196 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/load"
+)
+
+// LockFile is the path relative to the module root of the file
+// that pins the CUE dependencies resolved from 'cue.mod'.
+const LockFile = "cue.mod/timoni.lock"
+
+// ModuleLock holds the CUE dependencies of a module and their content digests.
+type ModuleLock struct {
+	Dependencies []LockedDependency `json:"dependencies"`
+}
+
+// LockedDependency holds the import path of a CUE package
+// resolved from 'cue.mod' and the digest of its files.
+type LockedDependency struct {
+	Path   string `json:"path"`
+	Digest string `json:"digest"`
+}
+
+// ResolveDependencies loads the module and returns its CUE dependencies
+// sorted by import path.
+func (b *ModuleBuilder) ResolveDependencies() ([]LockedDependency, error) {
+	cfg := &load.Config{
+		ModuleRoot: b.moduleRoot,
+		Package:    b.pkgName,
+		Dir:        b.pkgPath,
+		DataFiles:  true,
+	}
+
+	modInstances := load.Instances([]string{}, cfg)
+	if len(modInstances) == 0 {
+		return nil, errors.New("no instances found")
+	}
+
+	modInstance := modInstances[0]
+	if modInstance.Err != nil {
+		return nil, fmt.Errorf("instance error: %w", modInstance.Err)
+	}
+
+	return b.dependencies(modInstance)
+}
+
+// WriteLockFile resolves the module dependencies and writes them to the lock file.
+func (b *ModuleBuilder) WriteLockFile() ([]LockedDependency, error) {
+	deps, err := b.ResolveDependencies()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(ModuleLock{Dependencies: deps}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+
+	if err := os.WriteFile(filepath.Join(b.moduleRoot, LockFile), data, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", LockFile, err)
+	}
+
+	return deps, nil
+}
+
+// verifyLock checks that the dependencies of the given instance match the
+// ones pinned in the lock file. It's a no-op if the module has no lock file.
+func (b *ModuleBuilder) verifyLock(inst *build.Instance) error {
+	data, err := os.ReadFile(filepath.Join(b.moduleRoot, LockFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", LockFile, err)
+	}
+
+	var lock ModuleLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", LockFile, err)
+	}
+
+	locked := make(map[string]string, len(lock.Dependencies))
+	for _, dep := range lock.Dependencies {
+		locked[dep.Path] = dep.Digest
+	}
+
+	deps, err := b.dependencies(inst)
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range deps {
+		digest, ok := locked[dep.Path]
+		if !ok {
+			return fmt.Errorf("dependency %s is missing from %s, run 'timoni mod lock' to update it", dep.Path, LockFile)
+		}
+		if digest != dep.Digest {
+			return fmt.Errorf("dependency %s resolved to %s but %s pins %s", dep.Path, dep.Digest, LockFile, digest)
+		}
+	}
+
+	return nil
+}
+
+// dependencies walks the imports of the given instance and returns the
+// packages resolved from 'cue.mod' with the digest of their files.
+func (b *ModuleBuilder) dependencies(inst *build.Instance) ([]LockedDependency, error) {
+	cueModDir, err := filepath.Abs(filepath.Join(b.moduleRoot, "cue.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var deps []LockedDependency
+	var walk func(imports []*build.Instance) error
+	walk = func(imports []*build.Instance) error {
+		for _, imp := range imports {
+			if seen[imp.ImportPath] {
+				continue
+			}
+			seen[imp.ImportPath] = true
+
+			h := sha256.New()
+			vendored := false
+			files := make([]string, 0, len(imp.BuildFiles))
+			for _, f := range imp.BuildFiles {
+				files = append(files, f.Filename)
+			}
+			sort.Strings(files)
+			for _, file := range files {
+				abs, err := filepath.Abs(file)
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(cueModDir, abs)
+				if err != nil || strings.HasPrefix(rel, "..") {
+					continue
+				}
+				data, err := os.ReadFile(abs)
+				if err != nil {
+					return err
+				}
+				vendored = true
+				fmt.Fprintf(h, "%s\n%d\n", filepath.ToSlash(rel), len(data))
+				h.Write(data)
+			}
+
+			if vendored {
+				deps = append(deps, LockedDependency{
+					Path:   imp.ImportPath,
+					Digest: fmt.Sprintf("sha256:%x", h.Sum(nil)),
+				})
+			}
+
+			if err := walk(imp.Imports); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(inst.Imports); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Path < deps[j].Path
+	})
+	return deps, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	. "github.com/onsi/gomega"
+)
+
+func TestModuleBuilder_Lock(t *testing.T) {
+	g := NewWithT(t)
+	moduleRoot := filepath.Join(t.TempDir(), "module")
+
+	err := CopyModule("testdata/module", moduleRoot)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	depDir := filepath.Join(moduleRoot, "cue.mod", "pkg", "example.com", "dep")
+	g.Expect(os.MkdirAll(depDir, os.ModePerm)).To(Succeed())
+	depFile := filepath.Join(depDir, "dep.cue")
+	g.Expect(os.WriteFile(depFile, []byte("package dep\n\n#Port: 8080\n"), os.ModePerm)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(moduleRoot, "dep.cue"),
+		[]byte("package main\n\nimport \"example.com/dep\"\n\n_port: dep.#Port\n"), os.ModePerm)).To(Succeed())
+
+	mb := NewModuleBuilder(cuecontext.New(), "test-name", "test-namespace", moduleRoot, "main")
+
+	deps, err := mb.ResolveDependencies()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deps).To(HaveLen(1))
+	g.Expect(deps[0].Path).To(Equal("example.com/dep"))
+	g.Expect(deps[0].Digest).To(HavePrefix("sha256:"))
+
+	t.Run("builds without lock file", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := mb.Build()
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("builds with matching lock file", func(t *testing.T) {
+		g := NewWithT(t)
+		locked, err := mb.WriteLockFile()
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(locked).To(Equal(deps))
+		g.Expect(filepath.Join(moduleRoot, LockFile)).To(BeAnExistingFile())
+
+		_, err = mb.Build()
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("fails when a dependency changes", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(os.WriteFile(depFile, []byte("package dep\n\n#Port: 9090\n"), os.ModePerm)).To(Succeed())
+
+		_, err := mb.Build()
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("dependency example.com/dep resolved to"))
+	})
+}
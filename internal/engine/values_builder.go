@@ -58,3 +58,33 @@ func (b *ValuesBuilder) MergeValues(overlays [][]byte, base string) (cue.Value,
 
 	return baseVal, nil
 }
+
+// IneffectiveOverlays returns the indexes of the overlays that have no effect
+// on the merged values. An overlay has no effect if it doesn't change the values
+// set by the base and the previous overlays, or if it's fully overridden by the
+// next overlays.
+func (b *ValuesBuilder) IneffectiveOverlays(overlays [][]byte, base string) ([]int, error) {
+	finalVal, err := b.MergeValues(overlays, base)
+	if err != nil {
+		return nil, err
+	}
+	final := fmt.Sprintf("%v", finalVal)
+
+	var res []int
+	for i := range overlays {
+		others := make([][]byte, 0, len(overlays)-1)
+		others = append(others, overlays[:i]...)
+		others = append(others, overlays[i+1:]...)
+
+		val, err := b.MergeValues(others, base)
+		if err != nil {
+			return nil, err
+		}
+
+		if fmt.Sprintf("%v", val) == final {
+			res = append(res, i)
+		}
+	}
+
+	return res, nil
+}
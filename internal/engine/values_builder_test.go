@@ -53,3 +53,28 @@ func TestValuesBuilder(t *testing.T) {
 
 	g.Expect(fmt.Sprintf("%v", finalVal)).To(BeEquivalentTo(fmt.Sprintf("%v", goldVal)))
 }
+
+func TestValuesBuilder_IneffectiveOverlays(t *testing.T) {
+	g := NewWithT(t)
+	ctx := cuecontext.New()
+
+	vb := NewValuesBuilder(ctx)
+
+	base := "testdata/values/base.cue"
+	overlays := [][]byte{
+		[]byte(`values: securityContext: seccompProfile: type: "Localhost"`),
+		mustReadFile(g, "testdata/values/overlay-1.cue"),
+		mustReadFile(g, "testdata/values/overlay-2.cue"),
+		mustReadFile(g, "testdata/values/overlay-2.cue"),
+	}
+
+	res, err := vb.IneffectiveOverlays(overlays, base)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// The first overlay is overridden by the third, and the fourth repeats the third.
+	g.Expect(res).To(Equal([]int{0, 2, 3}))
+
+	res, err = vb.IneffectiveOverlays(overlays[1:3], base)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(res).To(BeEmpty())
+}
@@ -0,0 +1,43 @@
+package flags
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tagKeyRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedTags are injected by Timoni when building a module.
+var reservedTags = []string{"name", "namespace"}
+
+type Tags []string
+
+func (f *Tags) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *Tags) Set(str string) error {
+	key, _, _ := strings.Cut(str, "=")
+	if !tagKeyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid tag '%s', must be in the format 'key=value' or 'key' where key is a CUE identifier", str)
+	}
+	for _, reserved := range reservedTags {
+		if key == reserved {
+			return fmt.Errorf("invalid tag '%s', the '%s' tag is reserved", str, key)
+		}
+	}
+	*f = append(*f, str)
+	return nil
+}
+
+func (f *Tags) Type() string {
+	return "tag"
+}
+
+func (f *Tags) Description() string {
+	return "Inject a CUE build tag in the format 'key=value' for @tag attributes, or 'key' for @if attributes. This flag can be repeated."
+}
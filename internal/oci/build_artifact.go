@@ -37,6 +37,9 @@ https://github.com/fluxcd/pkg/blob/4f69e7842ce20a6432a412a03a0cf7ff5d8b87f5/oci/
 
 // BuildArtifact creates the destination file and packages
 // the given content (excluding symlinks) using tar+gzip compression.
+// The archive is reproducible: the entries are written in lexical order,
+// the timestamps and ownership are zeroed and the file modes are normalized,
+// so that identical content results in an identical digest.
 func BuildArtifact(dstFile, contentPath string, ignorePaths []string) error {
 	absDir, err := filepath.Abs(contentPath)
 	if err != nil {
@@ -106,6 +109,7 @@ func BuildArtifact(dstFile, contentPath string, ignorePaths []string) error {
 		header.ModTime = time.Time{}
 		header.AccessTime = time.Time{}
 		header.ChangeTime = time.Time{}
+		header.Mode = normalizedMode(fi)
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
@@ -156,3 +160,12 @@ func (wc *writeCounter) Write(p []byte) (int, error) {
 	wc.written += int64(n)
 	return n, nil
 }
+
+// normalizedMode returns 0755 for directories and executable files,
+// and 0644 for all other files, to avoid the digest varying with the umask.
+func normalizedMode(fi os.FileInfo) int64 {
+	if fi.IsDir() || fi.Mode().Perm()&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
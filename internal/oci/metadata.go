@@ -19,6 +19,7 @@ package oci
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -43,22 +44,31 @@ func ParseAnnotations(args []string) (map[string]string, error) {
 }
 
 // AppendGitMetadata sets the OpenContainers source, revision and created annotations
-// from the Git metadata. If the git binary or the .git dir are missing, the created
-// date is set to the current UTC date, and the source and revision are not appended.
+// from the Git metadata. If the SOURCE_DATE_EPOCH env var is set, it takes priority
+// over the Git commit date, to allow reproducible artifacts. If the git binary or
+// the .git dir are missing, the created date is set to the current UTC date,
+// and the source and revision are not appended.
 func AppendGitMetadata(repoPath string, annotations map[string]string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	epoch, hasEpoch := sourceDateEpoch()
+	if hasEpoch {
+		annotations[apiv1.CreatedAnnotation] = epoch.Format(time.RFC3339)
+	}
+
 	tsCmd := exec.CommandContext(ctx, "git", "--no-pager", "log", "-1", `--format=%ct`)
 	tsCmd.Dir = repoPath
 	if ts, err := tsCmd.Output(); err == nil && len(ts) > 1 {
-		if i, err := strconv.ParseInt(strings.TrimSuffix(string(ts), "\n"), 10, 64); err == nil {
+		if i, err := strconv.ParseInt(strings.TrimSuffix(string(ts), "\n"), 10, 64); err == nil && !hasEpoch {
 			d := time.Unix(i, 0)
 			annotations[apiv1.CreatedAnnotation] = d.Format(time.RFC3339)
 		}
 	} else {
-		ct := time.Now().UTC()
-		annotations[apiv1.CreatedAnnotation] = ct.Format(time.RFC3339)
+		if !hasEpoch {
+			ct := time.Now().UTC()
+			annotations[apiv1.CreatedAnnotation] = ct.Format(time.RFC3339)
+		}
 		return
 	}
 
@@ -78,3 +88,17 @@ func AppendGitMetadata(repoPath string, annotations map[string]string) {
 		}
 	}
 }
+
+// sourceDateEpoch returns the UTC time set in the SOURCE_DATE_EPOCH env var.
+// Ref: https://reproducible-builds.org/specs/source-date-epoch/
+func sourceDateEpoch() (time.Time, bool) {
+	v := os.Getenv("SOURCE_DATE_EPOCH")
+	if v == "" {
+		return time.Time{}, false
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(i, 0).UTC(), true
+}
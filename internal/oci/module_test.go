@@ -22,8 +22,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/crane"
 	. "github.com/onsi/gomega"
+	cp "github.com/otiai10/copy"
 
 	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
 )
@@ -112,3 +115,97 @@ func TestModuleOperations(t *testing.T) {
 	g.Expect(err).ToNot(HaveOccurred())
 	g.Expect(len(cachedLayers)).To(BeEquivalentTo(2))
 }
+
+func TestInspectModule(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	srcPath := "testdata/module/"
+	imgURL := fmt.Sprintf("oci://%s/%s:1.0.0", dockerRegistry, rnd("my-module", 5))
+	annotations := map[string]string{apiv1.VersionAnnotation: "1.0.0"}
+
+	artifact, err := InspectModule(imgURL, srcPath, []string{"timoni.ignore"}, annotations)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(artifact.Layers).To(HaveLen(2))
+	g.Expect(artifact.Layers[0].ContentType).To(BeEquivalentTo(apiv1.TimoniModVendorContentType))
+	g.Expect(artifact.Layers[0].Files).To(ContainElement("cue.mod/module.cue"))
+	g.Expect(artifact.Layers[1].ContentType).To(BeEquivalentTo(apiv1.TimoniModContentType))
+	g.Expect(artifact.Layers[1].Files).To(ContainElements("timoni.cue", "templates/cm.cue"))
+	g.Expect(artifact.Layers[1].Files).ToNot(ContainElement("timoni.ignore"))
+	g.Expect(artifact.Size).To(BeEquivalentTo(artifact.Layers[0].Size + artifact.Layers[1].Size))
+
+	_, err = crane.Head(artifact.URL[len(apiv1.ArtifactPrefix):], Options(ctx, "", false)...)
+	g.Expect(err).To(HaveOccurred())
+
+	digestURL, err := PushModule(imgURL, srcPath, []string{"timoni.ignore"}, annotations, Options(ctx, "", false))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(digestURL).To(BeEquivalentTo(artifact.URL))
+}
+
+func TestInspectModule_Reproducible(t *testing.T) {
+	g := NewWithT(t)
+	imgURL := fmt.Sprintf("oci://%s/%s:1.0.0", dockerRegistry, rnd("my-module", 5))
+	annotations := map[string]string{apiv1.VersionAnnotation: "1.0.0"}
+
+	// Copy the module twice with different timestamps and file modes
+	var digests []string
+	for i, mode := range []os.FileMode{0o644, 0o664} {
+		srcPath := filepath.Join(t.TempDir(), "module")
+		g.Expect(cp.Copy("testdata/module", srcPath)).To(Succeed())
+
+		mtime := time.Now().Add(time.Duration(i) * time.Hour)
+		err := filepath.Walk(srcPath, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.Mode().IsRegular() {
+				if err := os.Chmod(p, mode); err != nil {
+					return err
+				}
+			}
+			return os.Chtimes(p, mtime, mtime)
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		artifact, err := InspectModule(imgURL, srcPath, []string{"timoni.ignore"}, annotations)
+		g.Expect(err).ToNot(HaveOccurred())
+		digests = append(digests, artifact.Digest)
+	}
+
+	g.Expect(digests[0]).To(Equal(digests[1]))
+}
+
+func TestSaveLoadModule(t *testing.T) {
+	g := NewWithT(t)
+	tmpDir := t.TempDir()
+	ctx := context.Background()
+	opts := Options(ctx, "", false)
+
+	srcPath := "testdata/module/"
+	imgURL := fmt.Sprintf("oci://%s/%s", dockerRegistry, rnd("my-module", 5))
+	annotations := map[string]string{apiv1.VersionAnnotation: "1.0.0"}
+
+	digestURL, err := PushModule(imgURL+":1.0.0", srcPath, []string{"timoni.ignore"}, annotations, opts)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	archive := filepath.Join(tmpDir, "module.tar")
+	savedRef, err := SaveModule(imgURL+":1.0.0", archive, opts)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(savedRef.Repository).To(BeEquivalentTo(imgURL))
+	g.Expect(savedRef.Version).To(BeEquivalentTo("1.0.0"))
+	g.Expect(fmt.Sprintf("%s@%s", savedRef.Repository, savedRef.Digest)).To(BeEquivalentTo(digestURL))
+
+	dstPath := filepath.Join(tmpDir, "module")
+	loadedRef, err := LoadModule(archive, dstPath)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(loadedRef.Repository).To(BeEquivalentTo(savedRef.Repository))
+	g.Expect(loadedRef.Version).To(BeEquivalentTo(savedRef.Version))
+	g.Expect(loadedRef.Digest).To(BeEquivalentTo(savedRef.Digest))
+
+	g.Expect(filepath.Join(dstPath, "timoni.cue")).To(BeAnExistingFile())
+	g.Expect(filepath.Join(dstPath, "cue.mod", "module.cue")).To(BeAnExistingFile())
+	g.Expect(filepath.Join(dstPath, "timoni.ignore")).ToNot(BeAnExistingFile())
+
+	_, err = LoadModule(filepath.Join(srcPath, "timoni.cue"), filepath.Join(tmpDir, "invalid"))
+	g.Expect(err).To(HaveOccurred())
+}
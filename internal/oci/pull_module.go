@@ -65,18 +65,9 @@ func PullModule(ociURL, dstPath, cacheDir string, opts []crane.Option) (*apiv1.M
 			manifest.Config.MediaType, apiv1.ConfigMediaType)
 	}
 
-	version := ""
-	if rev, ok := manifest.Annotations[apiv1.RevisionAnnotation]; ok {
-		// For backwards compatibility with Timoni v0.13
-		version = rev
-	}
-	if ver, ok := manifest.Annotations[apiv1.VersionAnnotation]; ok {
-		version = ver
-	}
-
 	moduleRef := &apiv1.ModuleReference{
 		Repository:  fmt.Sprintf("%s%s", apiv1.ArtifactPrefix, repoURL),
-		Version:     version,
+		Version:     moduleVersion(manifest.Annotations),
 		Digest:      digest,
 		Annotations: manifest.Annotations,
 	}
@@ -156,3 +147,16 @@ func PullModule(ociURL, dstPath, cacheDir string, opts []crane.Option) (*apiv1.M
 
 	return moduleRef, nil
 }
+
+// moduleVersion returns the module version from the artifact annotations.
+func moduleVersion(annotations map[string]string) string {
+	version := ""
+	if rev, ok := annotations[apiv1.RevisionAnnotation]; ok {
+		// For backwards compatibility with Timoni v0.13
+		version = rev
+	}
+	if ver, ok := annotations[apiv1.VersionAnnotation]; ok {
+		version = ver
+	}
+	return version
+}
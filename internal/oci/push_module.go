@@ -17,7 +17,9 @@ limitations under the License.
 package oci
 
 import (
+	"archive/tar"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -51,6 +53,113 @@ func PushModule(ociURL, contentPath string, ignorePaths []string, annotations ma
 	}
 	defer os.RemoveAll(tmpDir)
 
+	img, err := buildModuleImage(tmpDir, contentPath, ignorePaths, annotations)
+	if err != nil {
+		return "", err
+	}
+
+	if err := crane.Push(img, ref.String(), opts...); err != nil {
+		return "", fmt.Errorf("pushing artifact failed: %w", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("parsing artifact digest failed: %w", err)
+	}
+
+	digestURL := ref.Context().Digest(digest.String()).String()
+	return fmt.Sprintf("%s%s", apiv1.ArtifactPrefix, digestURL), nil
+}
+
+// ModuleArtifact holds the details of a module artifact packaged locally.
+type ModuleArtifact struct {
+	// URL is the digest URL the artifact would have in the container registry.
+	URL string `json:"url"`
+	// Digest of the artifact manifest.
+	Digest string `json:"digest"`
+	// Size is the sum of the compressed layers size in bytes.
+	Size int64 `json:"size"`
+	// Layers holds the details of each layer.
+	Layers []ModuleArtifactLayer `json:"layers"`
+	// Manifest is the OpenContainers manifest of the artifact.
+	Manifest *gcrv1.Manifest `json:"manifest"`
+}
+
+// ModuleArtifactLayer holds the details of a module artifact layer.
+type ModuleArtifactLayer struct {
+	// ContentType is the Timoni content type of the layer.
+	ContentType string `json:"contentType"`
+	// Digest of the compressed layer.
+	Digest string `json:"digest"`
+	// Size of the compressed layer in bytes.
+	Size int64 `json:"size"`
+	// Files is the list of files included in the layer.
+	Files []string `json:"files"`
+}
+
+// InspectModule packages the Timoni module in the same way as PushModule,
+// without uploading it to the container registry, and returns
+// the details of the resulting artifact.
+func InspectModule(ociURL, contentPath string, ignorePaths []string, annotations map[string]string) (*ModuleArtifact, error) {
+	ref, err := parseArtifactRef(ociURL)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", apiv1.FieldManager)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	img, err := buildModuleImage(tmpDir, contentPath, ignorePaths, annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing artifact manifest failed: %w", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing artifact digest failed: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("parsing artifact layers failed: %w", err)
+	}
+
+	artifact := &ModuleArtifact{
+		URL:      fmt.Sprintf("%s%s", apiv1.ArtifactPrefix, ref.Context().Digest(digest.String()).String()),
+		Digest:   digest.String(),
+		Manifest: manifest,
+	}
+
+	for i, layer := range layers {
+		files, err := listLayerFiles(layer)
+		if err != nil {
+			return nil, fmt.Errorf("listing layer files failed: %w", err)
+		}
+
+		desc := manifest.Layers[i]
+		artifact.Size += desc.Size
+		artifact.Layers = append(artifact.Layers, ModuleArtifactLayer{
+			ContentType: desc.Annotations[apiv1.ContentTypeAnnotation],
+			Digest:      desc.Digest.String(),
+			Size:        desc.Size,
+			Files:       files,
+		})
+	}
+
+	return artifact, nil
+}
+
+// buildModuleImage packages the module's vendored schemas and the module's
+// content in two tar+gzip layers stored in tmpDir, and returns the annotated artifact.
+func buildModuleImage(tmpDir, contentPath string, ignorePaths []string, annotations map[string]string) (gcrv1.Image, error) {
 	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
 	img = mutate.ConfigMediaType(img, apiv1.ConfigMediaType)
 	img = mutate.Annotations(img, annotations).(gcrv1.Image)
@@ -58,7 +167,7 @@ func PushModule(ociURL, contentPath string, ignorePaths []string, annotations ma
 	tgzVendor := filepath.Join(tmpDir, "vendor.tgz")
 	vendorIgnorePaths := []string{"/*", "!/cue.mod"}
 	if err := BuildArtifact(tgzVendor, contentPath, vendorIgnorePaths); err != nil {
-		return "", fmt.Errorf("packging vendor layer failed: %w", err)
+		return nil, fmt.Errorf("packging vendor layer failed: %w", err)
 	}
 
 	layerVendor, err := tarball.LayerFromFile(tgzVendor,
@@ -67,7 +176,7 @@ func PushModule(ociURL, contentPath string, ignorePaths []string, annotations ma
 		tarball.WithCompressedCaching,
 	)
 	if err != nil {
-		return "", fmt.Errorf("creating vendor layer failed: %w", err)
+		return nil, fmt.Errorf("creating vendor layer failed: %w", err)
 	}
 
 	img, err = mutate.Append(img, mutate.Addendum{
@@ -77,13 +186,13 @@ func PushModule(ociURL, contentPath string, ignorePaths []string, annotations ma
 		},
 	})
 	if err != nil {
-		return "", fmt.Errorf("appending vendor layer to artifact failed: %w", err)
+		return nil, fmt.Errorf("appending vendor layer to artifact failed: %w", err)
 	}
 
 	tgzModule := filepath.Join(tmpDir, "module.tgz")
 	ignorePaths = append(ignorePaths, "cue.mod/")
 	if err := BuildArtifact(tgzModule, contentPath, ignorePaths); err != nil {
-		return "", fmt.Errorf("packging module layer failed: %w", err)
+		return nil, fmt.Errorf("packging module layer failed: %w", err)
 	}
 
 	layerModule, err := tarball.LayerFromFile(tgzModule,
@@ -92,7 +201,7 @@ func PushModule(ociURL, contentPath string, ignorePaths []string, annotations ma
 		tarball.WithCompressedCaching,
 	)
 	if err != nil {
-		return "", fmt.Errorf("creating module layer failed: %w", err)
+		return nil, fmt.Errorf("creating module layer failed: %w", err)
 	}
 
 	img, err = mutate.Append(img, mutate.Addendum{
@@ -102,18 +211,33 @@ func PushModule(ociURL, contentPath string, ignorePaths []string, annotations ma
 		},
 	})
 	if err != nil {
-		return "", fmt.Errorf("appending module layer to artifact failed: %w", err)
+		return nil, fmt.Errorf("appending module layer to artifact failed: %w", err)
 	}
 
-	if err := crane.Push(img, ref.String(), opts...); err != nil {
-		return "", fmt.Errorf("pushing artifact failed: %w", err)
-	}
+	return img, nil
+}
 
-	digest, err := img.Digest()
+// listLayerFiles returns the path of the regular files found in the layer tarball.
+func listLayerFiles(layer gcrv1.Layer) ([]string, error) {
+	rc, err := layer.Uncompressed()
 	if err != nil {
-		return "", fmt.Errorf("parsing artifact digest failed: %w", err)
+		return nil, err
 	}
-
-	digestURL := ref.Context().Digest(digest.String()).String()
-	return fmt.Sprintf("%s%s", apiv1.ArtifactPrefix, digestURL), nil
+	defer rc.Close()
+
+	var files []string
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg {
+			files = append(files, header.Name)
+		}
+	}
+	return files, nil
 }
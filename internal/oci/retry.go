@@ -0,0 +1,98 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// RetryOptions holds the settings for retrying registry operations.
+type RetryOptions struct {
+	// Retries is the number of attempts made after the first failure.
+	Retries int
+	// Backoff is the delay before the first retry, doubled after each attempt.
+	Backoff time.Duration
+	// Jitter randomizes each delay between half and the full backoff,
+	// to spread the retries of concurrent clients.
+	Jitter bool
+}
+
+// delay returns the time to wait before the next attempt.
+func (o RetryOptions) delay(backoff time.Duration) time.Duration {
+	if !o.Jitter || backoff < 2 {
+		return backoff
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)))
+}
+
+// Retry calls fn until it succeeds, the error is not retryable,
+// the retries are exhausted or the context is cancelled.
+func Retry(ctx context.Context, opts RetryOptions, fn func() error) error {
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= opts.Retries || !IsRetryableError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(opts.delay(backoff)):
+		}
+		backoff *= 2
+	}
+}
+
+// IsRetryableError returns true if the error is caused by a transient
+// network or registry failure e.g. timeouts, connection resets, 5xx responses.
+// Errors such as not found or unauthorized are not retryable.
+func IsRetryableError(err error) bool {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch terr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		default:
+			return terr.StatusCode >= http.StatusInternalServerError
+		}
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	. "github.com/onsi/gomega"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "service unavailable", err: &transport.Error{StatusCode: http.StatusServiceUnavailable}, retryable: true},
+		{name: "too many requests", err: &transport.Error{StatusCode: http.StatusTooManyRequests}, retryable: true},
+		{name: "unexpected EOF", err: fmt.Errorf("pulling layer failed: %w", io.ErrUnexpectedEOF), retryable: true},
+		{name: "not found", err: &transport.Error{StatusCode: http.StatusNotFound}, retryable: false},
+		{name: "unauthorized", err: &transport.Error{StatusCode: http.StatusUnauthorized}, retryable: false},
+		{name: "context deadline", err: context.DeadlineExceeded, retryable: false},
+		{name: "generic", err: errors.New("unsupported artifact type"), retryable: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(IsRetryableError(tt.err)).To(Equal(tt.retryable))
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	opts := RetryOptions{Retries: 2, Backoff: time.Millisecond}
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		g := NewWithT(t)
+		attempts := 0
+		err := Retry(ctx, opts, func() error {
+			attempts++
+			if attempts < 3 {
+				return &transport.Error{StatusCode: http.StatusBadGateway}
+			}
+			return nil
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(attempts).To(Equal(3))
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		g := NewWithT(t)
+		attempts := 0
+		err := Retry(ctx, opts, func() error {
+			attempts++
+			return &transport.Error{StatusCode: http.StatusBadGateway}
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(attempts).To(Equal(3))
+	})
+
+	t.Run("fails fast on non-retryable errors", func(t *testing.T) {
+		g := NewWithT(t)
+		attempts := 0
+		err := Retry(ctx, opts, func() error {
+			attempts++
+			return &transport.Error{StatusCode: http.StatusNotFound}
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(attempts).To(Equal(1))
+	})
+}
+
+func TestRetryOptions_delay(t *testing.T) {
+	g := NewWithT(t)
+	backoff := 100 * time.Millisecond
+
+	g.Expect(RetryOptions{Backoff: backoff}.delay(backoff)).To(Equal(backoff))
+
+	opts := RetryOptions{Backoff: backoff, Jitter: true}
+	for i := 0; i < 100; i++ {
+		d := opts.delay(backoff)
+		g.Expect(d).To(BeNumerically(">=", backoff/2))
+		g.Expect(d).To(BeNumerically("<", backoff))
+	}
+}
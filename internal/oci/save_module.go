@@ -0,0 +1,210 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fluxcd/pkg/tar"
+	"github.com/google/go-containerregistry/pkg/crane"
+	gcrv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+// refNameAnnotation is the OCI image layout annotation used to record
+// the original reference of the module artifact.
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// SaveModule performs the following operations:
+// - fetches the remote artifact from the registry
+// - verifies that artifact config matches Timoni's media type
+// - writes the artifact manifest, config and layers in the OCI image layout format
+// - packages the image layout to the destination file using tar+gzip compression
+func SaveModule(ociURL, dstFile string, opts []crane.Option) (*apiv1.ModuleReference, error) {
+	ref, err := parseArtifactRef(ociURL)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := crane.Pull(ref.String(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("pulling artifact failed: %w", err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing artifact manifest failed: %w", err)
+	}
+
+	if manifest.Config.MediaType != apiv1.ConfigMediaType {
+		return nil, fmt.Errorf("unsupported artifact type '%s', must be '%s'",
+			manifest.Config.MediaType, apiv1.ConfigMediaType)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("resolving digest of '%s' failed: %w", ociURL, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", apiv1.FieldManager)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	p, err := layout.Write(tmpDir, empty.Index)
+	if err != nil {
+		return nil, fmt.Errorf("writing image layout failed: %w", err)
+	}
+
+	err = p.AppendImage(img, layout.WithAnnotations(map[string]string{
+		refNameAnnotation: ref.String(),
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("writing image layout failed: %w", err)
+	}
+
+	if err := BuildArtifact(dstFile, tmpDir, nil); err != nil {
+		return nil, fmt.Errorf("packaging image layout failed: %w", err)
+	}
+
+	return &apiv1.ModuleReference{
+		Repository:  fmt.Sprintf("%s%s", apiv1.ArtifactPrefix, ref.Context().Name()),
+		Version:     moduleVersion(manifest.Annotations),
+		Digest:      digest.String(),
+		Annotations: manifest.Annotations,
+	}, nil
+}
+
+// LoadModule performs the following operations:
+// - extracts the OCI image layout from the tar+gzip source file
+// - verifies that artifact config matches Timoni's media type
+// - extracts the module contents to the destination directory
+// The returned module reference points to the repository and digest
+// of the artifact that was saved, to preserve the module provenance.
+func LoadModule(srcFile, dstPath string) (*apiv1.ModuleReference, error) {
+	reader, err := os.Open(srcFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading module archive failed: %w", err)
+	}
+	defer reader.Close()
+
+	tmpDir, err := os.MkdirTemp("", apiv1.FieldManager)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	layoutDir := filepath.Join(tmpDir, "layout")
+	if err = tar.Untar(reader, layoutDir, tar.WithMaxUntarSize(-1)); err != nil {
+		return nil, fmt.Errorf("extracting module archive failed: %w", err)
+	}
+
+	p, err := layout.FromPath(layoutDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading image layout failed: %w", err)
+	}
+
+	index, err := p.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("reading image index failed: %w", err)
+	}
+
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("reading image index failed: %w", err)
+	}
+
+	if len(indexManifest.Manifests) != 1 {
+		return nil, fmt.Errorf("module archive must contain one artifact, found %d", len(indexManifest.Manifests))
+	}
+	desc := indexManifest.Manifests[0]
+
+	img, err := index.Image(desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("reading artifact %s failed: %w", desc.Digest, err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("parsing artifact manifest failed: %w", err)
+	}
+
+	if manifest.Config.MediaType != apiv1.ConfigMediaType {
+		return nil, fmt.Errorf("unsupported artifact type '%s', must be '%s'",
+			manifest.Config.MediaType, apiv1.ConfigMediaType)
+	}
+
+	repository := ""
+	if refName, ok := desc.Annotations[refNameAnnotation]; ok {
+		ref, err := parseArtifactRef(apiv1.ArtifactPrefix + refName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact reference '%s': %w", refName, err)
+		}
+		repository = fmt.Sprintf("%s%s", apiv1.ArtifactPrefix, ref.Context().Name())
+	}
+
+	if err := os.MkdirAll(dstPath, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	var foundLayer bool
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != apiv1.ContentMediaType {
+			continue
+		}
+		foundLayer = true
+		if err := extractLayer(img, layer.Digest, dstPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if !foundLayer {
+		return nil, fmt.Errorf("no layer found in artifact with media type '%s'", apiv1.ContentMediaType)
+	}
+
+	return &apiv1.ModuleReference{
+		Repository:  repository,
+		Version:     moduleVersion(manifest.Annotations),
+		Digest:      desc.Digest.String(),
+		Annotations: manifest.Annotations,
+	}, nil
+}
+
+func extractLayer(img gcrv1.Image, digest gcrv1.Hash, dstPath string) error {
+	layer, err := img.LayerByDigest(digest)
+	if err != nil {
+		return fmt.Errorf("reading layer %s failed: %w", digest, err)
+	}
+
+	blob, err := layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("reading layer %s failed: %w", digest, err)
+	}
+	defer blob.Close()
+
+	if err = tar.Untar(blob, dstPath, tar.WithMaxUntarSize(-1)); err != nil {
+		return fmt.Errorf("extracting layer %s failed: %w", digest, err)
+	}
+
+	return nil
+}
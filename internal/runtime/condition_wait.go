@@ -0,0 +1,161 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WaitCondition defines the status condition that objects of a certain kind must reach.
+type WaitCondition struct {
+	// Kind of the Kubernetes objects subject to waiting.
+	Kind string
+	// Type of the status condition e.g. 'Ready'.
+	Type string
+	// Status of the condition e.g. 'True'.
+	Status string
+	// Timeout of the wait for this condition, when zero the default timeout is used.
+	Timeout time.Duration
+}
+
+// String returns the condition in the format '<kind>:<type>=<status>'.
+func (c WaitCondition) String() string {
+	return fmt.Sprintf("%s:%s=%s", c.Kind, c.Type, c.Status)
+}
+
+// ParseWaitCondition parses a wait condition in the format '<kind>:<type>=<status>[:<timeout>]'.
+func ParseWaitCondition(s string) (WaitCondition, error) {
+	var c WaitCondition
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return c, fmt.Errorf("invalid wait condition '%s', must be in the format '<kind>:<type>=<status>[:<timeout>]'", s)
+	}
+
+	cond := strings.SplitN(parts[1], "=", 2)
+	if len(cond) != 2 || parts[0] == "" || cond[0] == "" || cond[1] == "" {
+		return c, fmt.Errorf("invalid wait condition '%s', must be in the format '<kind>:<type>=<status>[:<timeout>]'", s)
+	}
+
+	c.Kind = parts[0]
+	c.Type = cond[0]
+	c.Status = cond[1]
+
+	if len(parts) == 3 {
+		timeout, err := time.ParseDuration(parts[2])
+		if err != nil {
+			return c, fmt.Errorf("invalid timeout in wait condition '%s': %w", s, err)
+		}
+		c.Timeout = timeout
+	}
+
+	return c, nil
+}
+
+// ValidateWaitConditions returns an error if the kind of any of the
+// conditions doesn't match any of the given objects.
+func ValidateWaitConditions(objects []*unstructured.Unstructured, conditions []WaitCondition) error {
+	for _, cond := range conditions {
+		found := false
+		for _, object := range objects {
+			if strings.EqualFold(object.GetKind(), cond.Kind) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("wait condition %s doesn't match any object, no object of kind %s found", cond, cond.Kind)
+		}
+	}
+	return nil
+}
+
+// WaitForConditions polls the objects matching the kind of each condition,
+// until all conditions are met. Each condition is waited for its own timeout,
+// falling back to the default timeout, the deadline is shared by all the
+// objects matching the condition.
+func WaitForConditions(ctx context.Context,
+	rm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	conditions []WaitCondition,
+	interval time.Duration,
+	timeout time.Duration) error {
+	for _, cond := range conditions {
+		condTimeout := timeout
+		if cond.Timeout > 0 {
+			condTimeout = cond.Timeout
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, condTimeout)
+		err := waitForCondition(waitCtx, rm, objects, cond, interval)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitForCondition(ctx context.Context,
+	rm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	cond WaitCondition,
+	interval time.Duration) error {
+	for _, object := range objects {
+		if !strings.EqualFold(object.GetKind(), cond.Kind) {
+			continue
+		}
+
+		if err := wait.PollUntilContextCancel(ctx, interval, true, hasCondition(rm, object, cond)); err != nil {
+			return fmt.Errorf("%s timeout waiting for condition %s: %w", ssa.FmtUnstructured(object), cond, err)
+		}
+	}
+	return nil
+}
+
+func hasCondition(rm *ssa.ResourceManager, object *unstructured.Unstructured, cond WaitCondition) wait.ConditionWithContextFunc {
+	return func(ctx context.Context) (bool, error) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(object.GroupVersionKind())
+		if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(object), obj); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		conditions, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		if err != nil || !found {
+			return false, nil
+		}
+
+		for _, c := range conditions {
+			cm, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if cm["type"] == cond.Type {
+				return strings.EqualFold(fmt.Sprint(cm["status"]), cond.Status), nil
+			}
+		}
+		return false, nil
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestParseWaitCondition(t *testing.T) {
+	g := NewWithT(t)
+
+	cond, err := ParseWaitCondition("Certificate:Ready=True")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cond).To(Equal(WaitCondition{Kind: "Certificate", Type: "Ready", Status: "True"}))
+	g.Expect(cond.String()).To(Equal("Certificate:Ready=True"))
+
+	cond, err = ParseWaitCondition("Job:Complete=True:2m")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cond.Timeout).To(Equal(2 * time.Minute))
+
+	for _, s := range []string{"Certificate", "Certificate:Ready", ":Ready=True", "Certificate:=True", "Certificate:Ready=True:soon", "a:b=c:1m:x"} {
+		_, err = ParseWaitCondition(s)
+		g.Expect(err).To(HaveOccurred(), s)
+	}
+}
+
+func TestValidateWaitConditions(t *testing.T) {
+	g := NewWithT(t)
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("batch/v1")
+	obj.SetKind("Job")
+	obj.SetName("migrate")
+
+	conditions := []WaitCondition{{Kind: "job", Type: "Complete", Status: "True"}}
+	g.Expect(ValidateWaitConditions([]*unstructured.Unstructured{obj}, conditions)).To(Succeed())
+
+	conditions = append(conditions, WaitCondition{Kind: "Jobs", Type: "Complete", Status: "True"})
+	err := ValidateWaitConditions([]*unstructured.Unstructured{obj}, conditions)
+	g.Expect(err).To(MatchError(ContainSubstring("wait condition Jobs:Complete=True doesn't match any object")))
+}
+
+func TestWaitForConditions(t *testing.T) {
+	newCertificate := func(name, status string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("cert-manager.io/v1")
+		obj.SetKind("Certificate")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		obj.Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": status},
+			},
+		}
+		return obj
+	}
+
+	t.Run("returns when the conditions are met", func(t *testing.T) {
+		g := NewWithT(t)
+		ready := newCertificate("ready", "True")
+		c := fake.NewClientBuilder().WithObjects(ready).Build()
+		rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+		err := WaitForConditions(context.Background(), rm, []*unstructured.Unstructured{ready},
+			[]WaitCondition{{Kind: "Certificate", Type: "Ready", Status: "True"}}, 10*time.Millisecond, time.Second)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("shares the condition timeout between objects", func(t *testing.T) {
+		g := NewWithT(t)
+		a := newCertificate("a", "False")
+		b := newCertificate("b", "False")
+		c := fake.NewClientBuilder().WithObjects(a, b).Build()
+		rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+		start := time.Now()
+		err := WaitForConditions(context.Background(), rm, []*unstructured.Unstructured{a, b},
+			[]WaitCondition{{Kind: "Certificate", Type: "Ready", Status: "True", Timeout: 200 * time.Millisecond}},
+			10*time.Millisecond, time.Hour)
+		g.Expect(err).To(MatchError(ContainSubstring("Certificate/default/a timeout waiting for condition")))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 350*time.Millisecond))
+	})
+}
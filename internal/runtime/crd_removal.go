@@ -0,0 +1,104 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+)
+
+// SelectCRDs returns the CustomResourceDefinitions found in the given objects.
+func SelectCRDs(objects []*unstructured.Unstructured) []*unstructured.Unstructured {
+	var crds []*unstructured.Unstructured
+	for _, obj := range objects {
+		if obj.GroupVersionKind().GroupKind() == apiextensionsv1.Kind("CustomResourceDefinition") {
+			crds = append(crds, obj)
+		}
+	}
+	return crds
+}
+
+// WaitForCRDRemoval polls the discovery API until the resources defined by the given
+// CustomResourceDefinitions are no longer served. A CRD object can be finalized while
+// its API is still being torn down, which makes the custom resources fail to apply.
+func WaitForCRDRemoval(ctx context.Context,
+	dc discovery.DiscoveryInterface,
+	crds []*unstructured.Unstructured,
+	interval time.Duration) error {
+	var served []string
+	err := wait.PollUntilContextCancel(ctx, interval, true, func(ctx context.Context) (bool, error) {
+		served = nil
+		for _, crd := range crds {
+			ok, err := crdServed(dc, crd)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				served = append(served, crd.GetName())
+			}
+		}
+		return len(served) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("timeout waiting for the API removal of %v: %w", served, err)
+	}
+	return nil
+}
+
+// crdServed returns true if any version of the resource defined by the given CRD
+// is listed by the discovery API. The group and the plural name of the resource
+// are read from the CRD name, as the inventory holds only the objects metadata.
+func crdServed(dc discovery.DiscoveryInterface, crd *unstructured.Unstructured) (bool, error) {
+	plural, group, found := strings.Cut(crd.GetName(), ".")
+	if !found {
+		return false, nil
+	}
+
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return false, fmt.Errorf("discovery of the API groups failed: %w", err)
+	}
+
+	for _, g := range groups.Groups {
+		if g.Name != group {
+			continue
+		}
+		for _, version := range g.Versions {
+			resources, err := dc.ServerResourcesForGroupVersion(version.GroupVersion)
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return false, fmt.Errorf("discovery of %s failed: %w", version.GroupVersion, err)
+			}
+			for _, resource := range resources.APIResources {
+				if resource.Name == plural {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
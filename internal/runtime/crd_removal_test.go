@@ -0,0 +1,74 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestWaitForCRDRemoval(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		return obj
+	}
+
+	crd := newObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "widgets.example.com")
+	objects := []*unstructured.Unstructured{
+		newObject("v1", "ConfigMap", "test"),
+		crd,
+	}
+	g.Expect(SelectCRDs(objects)).To(ConsistOf(crd))
+
+	dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	dc.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{{Name: "gadgets", Kind: "Gadget"}},
+		},
+	}
+
+	t.Run("returns when the API is not served", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		g.Expect(WaitForCRDRemoval(ctx, dc, []*unstructured.Unstructured{crd}, 10*time.Millisecond)).To(Succeed())
+	})
+
+	t.Run("times out while the API is served", func(t *testing.T) {
+		g := NewWithT(t)
+		dc.Resources[0].APIResources = append(dc.Resources[0].APIResources,
+			metav1.APIResource{Name: "widgets", Kind: "Widget"})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err := WaitForCRDRemoval(ctx, dc, []*unstructured.Unstructured{crd}, 10*time.Millisecond)
+		g.Expect(err).To(MatchError(ContainSubstring("widgets.example.com")))
+	})
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/fluxcd/cli-utils/pkg/object"
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+// AlreadyDeletedAction marks the objects which are listed in the instance
+// inventory but are no longer present in the cluster.
+const AlreadyDeletedAction ssa.Action = "already deleted"
+
+// DeleteWithGracePeriod deletes the given object like ssa.ResourceManager.Delete,
+// passing the grace period to the delete call. The objects which are not found are
+// reported with AlreadyDeletedAction. The grace period applies to the objects that
+// support graceful termination such as Pods, a zero value means immediate deletion
+// and a negative value uses the default grace period of the object.
+func DeleteWithGracePeriod(ctx context.Context,
+	rm *ssa.ResourceManager,
+	obj *unstructured.Unstructured,
+	opts ssa.DeleteOptions,
+	gracePeriodSeconds int64) (*ssa.ChangeSetEntry, error) {
+	changeSetEntry := func(action ssa.Action) *ssa.ChangeSetEntry {
+		return NewChangeSetEntry(obj, action)
+	}
+
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(obj.GroupVersionKind())
+	if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(obj), existingObject); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return changeSetEntry(ssa.UnknownAction),
+				fmt.Errorf("%s query failed: %w", ssa.FmtUnstructured(obj), err)
+		}
+		return changeSetEntry(AlreadyDeletedAction), nil
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchLabels: opts.Inclusions})
+	if err != nil {
+		return changeSetEntry(ssa.UnknownAction),
+			fmt.Errorf("%s label selector failed: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	if !sel.Matches(labels.Set(existingObject.GetLabels())) {
+		return changeSetEntry(ssa.SkippedAction), nil
+	}
+
+	if ssa.AnyInMetadata(existingObject, opts.Exclusions) {
+		return changeSetEntry(ssa.SkippedAction), nil
+	}
+
+	deleteOpts := []client.DeleteOption{client.PropagationPolicy(opts.PropagationPolicy)}
+	if gracePeriodSeconds >= 0 {
+		deleteOpts = append(deleteOpts, client.GracePeriodSeconds(gracePeriodSeconds))
+	}
+	if err := rm.Client().Delete(ctx, existingObject, deleteOpts...); err != nil {
+		return changeSetEntry(ssa.UnknownAction),
+			fmt.Errorf("%s delete failed: %w", ssa.FmtUnstructured(obj), err)
+	}
+
+	return changeSetEntry(ssa.DeletedAction), nil
+}
+
+// DeleteStages splits the given objects, sorted in deletion order, into
+// consecutive stages of objects which can be deleted concurrently. The objects
+// of the kinds listed in ssa.ReconcileOrder are grouped by kind, while the
+// other kinds e.g. custom resources, are grouped together. The objects with
+// different delete order weights are placed in separate stages.
+func DeleteStages(objects []*unstructured.Unstructured) [][]*unstructured.Unstructured {
+	rank := func(obj *unstructured.Unstructured) int {
+		kind := obj.GetKind()
+		for i, k := range ssa.ReconcileOrder.First {
+			if k == kind {
+				return -len(ssa.ReconcileOrder.First) + i
+			}
+		}
+		for i, k := range ssa.ReconcileOrder.Last {
+			if k == kind {
+				return 1 + i
+			}
+		}
+		return 0
+	}
+
+	weight := func(obj *unstructured.Unstructured) string {
+		return obj.GetAnnotations()[apiv1.DeleteOrderAction]
+	}
+
+	var stages [][]*unstructured.Unstructured
+	for i, obj := range objects {
+		if i == 0 || rank(obj) != rank(objects[i-1]) || weight(obj) != weight(objects[i-1]) {
+			stages = append(stages, nil)
+		}
+		stages[len(stages)-1] = append(stages[len(stages)-1], obj)
+	}
+	return stages
+}
+
+// SortForDeletion sorts the given objects in deletion order. The objects annotated with
+// apiv1.DeleteOrderAction are deleted first, in descending order of their weight, followed
+// by the objects without the annotation in reverse apply order. The objects with equal
+// weights are deleted in reverse apply order.
+// The annotation is read from the live objects and copied to the given objects,
+// the objects not found in the cluster are considered without weight.
+func SortForDeletion(ctx context.Context,
+	c client.Client,
+	objects []*unstructured.Unstructured) error {
+	for _, obj := range objects {
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		if v, ok := live.GetAnnotations()[apiv1.DeleteOrderAction]; ok {
+			annotations := obj.GetAnnotations()
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[apiv1.DeleteOrderAction] = v
+			obj.SetAnnotations(annotations)
+		}
+	}
+
+	return sortByDeleteOrder(objects)
+}
+
+// sortByDeleteOrder sorts the objects by the weight set with apiv1.DeleteOrderAction
+// in descending order, falling back to the reverse apply order.
+func sortByDeleteOrder(objects []*unstructured.Unstructured) error {
+	weights := make(map[*unstructured.Unstructured]int, len(objects))
+	for _, obj := range objects {
+		v, ok := obj.GetAnnotations()[apiv1.DeleteOrderAction]
+		if !ok {
+			continue
+		}
+		weight, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s invalid %s annotation '%s', must be an integer",
+				ssa.FmtUnstructured(obj), apiv1.DeleteOrderAction, v)
+		}
+		weights[obj] = weight
+	}
+
+	sort.Sort(sort.Reverse(ssa.SortableUnstructureds(objects)))
+	sort.SliceStable(objects, func(i, j int) bool {
+		wi, iok := weights[objects[i]]
+		wj, jok := weights[objects[j]]
+		if iok != jok {
+			return iok
+		}
+		return wi > wj
+	})
+	return nil
+}
+
+// RemoveFinalizers patches out the finalizers of the given objects, to unblock the
+// deletion of the objects stuck in terminating. It returns the objects that were
+// patched, the objects not found in the cluster or without finalizers are skipped.
+func RemoveFinalizers(ctx context.Context,
+	c client.Client,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	var patched []*unstructured.Unstructured
+	for _, obj := range objects {
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return patched, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		if len(live.GetFinalizers()) == 0 {
+			continue
+		}
+
+		patch := client.RawPatch(types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`))
+		if err := c.Patch(ctx, live, patch); err != nil && !apierrors.IsNotFound(err) {
+			return patched, fmt.Errorf("failed to remove the finalizers of %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+		patched = append(patched, obj)
+	}
+	return patched, nil
+}
+
+// NewChangeSetEntry returns the change set entry of the given object and action.
+func NewChangeSetEntry(obj *unstructured.Unstructured, action ssa.Action) *ssa.ChangeSetEntry {
+	return &ssa.ChangeSetEntry{
+		ObjMetadata:  object.UnstructuredToObjMetadata(obj),
+		GroupVersion: obj.GroupVersionKind().Version,
+		Subject:      ssa.FmtUnstructured(obj),
+		Action:       action,
+	}
+}
+
+// SelectExistingObjects returns the objects which are still present in the cluster,
+// including the ones in terminating.
+func SelectExistingObjects(ctx context.Context,
+	c client.Client,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	var existing []*unstructured.Unstructured
+	for _, obj := range objects {
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+		existing = append(existing, obj)
+	}
+	return existing, nil
+}
+
+// SelectObjectsByLabels splits the given objects in the ones whose live labels match
+// the selector and the ones that don't. The objects not found in the cluster are
+// considered matching, as deleting them is a no-op.
+func SelectObjectsByLabels(ctx context.Context,
+	rm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	selector labels.Selector) (selected, skipped []*unstructured.Unstructured, err error) {
+	for _, obj := range objects {
+		live := &metav1.PartialObjectMetadata{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				selected = append(selected, obj)
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		if selector.Matches(labels.Set(live.GetLabels())) {
+			selected = append(selected, obj)
+		} else {
+			skipped = append(skipped, obj)
+		}
+	}
+	return selected, skipped, nil
+}
+
+// DeleteImmutable deletes the objects which contain changes to immutable fields
+// and are selected for recreation by the apply options, then waits for them to be
+// finalised, so that the replacements can be created without name conflicts.
+// The objects are deleted with foreground propagation, to ensure that their
+// dependents are removed before the replacements are created.
+func DeleteImmutable(ctx context.Context,
+	rm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured,
+	opts ssa.ApplyOptions,
+	waitOpts ssa.WaitOptions) (*ssa.ChangeSet, error) {
+	changeSet := ssa.NewChangeSet()
+
+	var deleted []*unstructured.Unstructured
+	for _, obj := range objects {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		if _, _, _, err := rm.Diff(ctx, obj, ssa.DefaultDiffOptions()); err == nil || !ssa.IsImmutableError(err) {
+			continue
+		}
+
+		if !opts.Force && !ssa.AnyInMetadata(obj, opts.ForceSelector) && !ssa.AnyInMetadata(live, opts.ForceSelector) {
+			continue
+		}
+
+		if err := rm.Client().Delete(ctx, live, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%s immutable field detected, failed to delete object: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		deleted = append(deleted, obj)
+		changeSet.Add(ssa.ChangeSetEntry{
+			ObjMetadata:  object.UnstructuredToObjMetadata(obj),
+			GroupVersion: obj.GroupVersionKind().Version,
+			Subject:      ssa.FmtUnstructured(obj),
+			Action:       ssa.DeletedAction,
+		})
+	}
+
+	if len(deleted) > 0 {
+		if err := rm.WaitForTermination(deleted, waitOpts); err != nil {
+			return changeSet, fmt.Errorf("waiting for the deletion of the immutable objects failed: %w", err)
+		}
+	}
+
+	return changeSet, nil
+}
+
+// SplitByScope splits the given objects into namespaced and cluster-scoped objects,
+// using the REST mapper of the given client to determine the scope of each kind.
+func SplitByScope(c client.Client, objects []*unstructured.Unstructured) (namespaced, clusterScoped []*unstructured.Unstructured, err error) {
+	for _, obj := range objects {
+		isNamespaced, err := c.IsObjectNamespaced(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s scope lookup failed: %w", ssa.FmtUnstructured(obj), err)
+		}
+		if isNamespaced {
+			namespaced = append(namespaced, obj)
+		} else {
+			clusterScoped = append(clusterScoped, obj)
+		}
+	}
+	return namespaced, clusterScoped, nil
+}
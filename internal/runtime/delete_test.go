@@ -0,0 +1,312 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestDeleteStages(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		return obj
+	}
+
+	objects := []*unstructured.Unstructured{
+		newObject("v1", "Namespace", "apps"),
+		newObject("v1", "ConfigMap", "config-a"),
+		newObject("v1", "ConfigMap", "config-b"),
+		newObject("apps/v1", "Deployment", "app"),
+		newObject("v1", "Pod", "debug"),
+		newObject("example.com/v1", "Database", "db"),
+	}
+	sort.Sort(sort.Reverse(ssa.SortableUnstructureds(objects)))
+
+	var stages [][]string
+	for _, stage := range DeleteStages(objects) {
+		var names []string
+		for _, obj := range stage {
+			names = append(names, obj.GetName())
+		}
+		stages = append(stages, names)
+	}
+
+	g.Expect(stages).To(Equal([][]string{
+		{"db", "debug"},
+		{"app"},
+		{"config-b", "config-a"},
+		{"apps"},
+	}))
+	g.Expect(DeleteStages(nil)).To(BeEmpty())
+}
+
+func TestSortForDeletion(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+	withWeight := func(obj *unstructured.Unstructured, weight string) *unstructured.Unstructured {
+		obj = obj.DeepCopy()
+		obj.SetAnnotations(map[string]string{apiv1.DeleteOrderAction: weight})
+		return obj
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(defaultScheme()).
+		WithObjects(
+			withWeight(newObject("v1", "ConfigMap", "config"), "10"),
+			withWeight(newObject("batch/v1", "Job", "cleanup"), "10"),
+			withWeight(newObject("v1", "Secret", "creds"), "20"),
+			newObject("apps/v1", "Deployment", "app"),
+			newObject("v1", "Service", "app"),
+		).
+		Build()
+
+	objects := []*unstructured.Unstructured{
+		newObject("v1", "ConfigMap", "config"),
+		newObject("v1", "Secret", "creds"),
+		newObject("v1", "Service", "app"),
+		newObject("apps/v1", "Deployment", "app"),
+		newObject("batch/v1", "Job", "cleanup"),
+		newObject("v1", "ConfigMap", "missing"),
+	}
+	g.Expect(SortForDeletion(context.Background(), c, objects)).To(Succeed())
+
+	var order []string
+	for _, obj := range objects {
+		order = append(order, ssa.FmtUnstructured(obj))
+	}
+	g.Expect(order).To(Equal([]string{
+		"Secret/default/creds",
+		"Job/default/cleanup",
+		"ConfigMap/default/config",
+		"Deployment/default/app",
+		"Service/default/app",
+		"ConfigMap/default/missing",
+	}))
+
+	var stages []int
+	for _, stage := range DeleteStages(objects) {
+		stages = append(stages, len(stage))
+	}
+	g.Expect(stages).To(Equal([]int{1, 1, 1, 1, 1, 1}))
+
+	invalid := []*unstructured.Unstructured{withWeight(newObject("v1", "ConfigMap", "config"), "first")}
+	err := sortByDeleteOrder(invalid)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("must be an integer"))
+}
+
+func TestRemoveFinalizers(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(name string, finalizers ...string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "default",
+				Finalizers: finalizers,
+			},
+		}
+	}
+	newObject := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(defaultScheme()).
+		WithObjects(newConfigMap("stuck", "example.com/cleanup"), newConfigMap("free")).
+		Build()
+
+	patched, err := RemoveFinalizers(context.Background(), c, []*unstructured.Unstructured{
+		newObject("stuck"),
+		newObject("free"),
+		newObject("missing"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(patched).To(HaveLen(1))
+	g.Expect(patched[0].GetName()).To(Equal("stuck"))
+
+	live := &corev1.ConfigMap{}
+	g.Expect(c.Get(context.Background(), client.ObjectKey{Name: "stuck", Namespace: "default"}, live)).To(Succeed())
+	g.Expect(live.Finalizers).To(BeEmpty())
+}
+
+func TestSelectExistingObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(defaultScheme()).
+		WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "stuck", Namespace: "default"},
+		}).
+		Build()
+
+	existing, err := SelectExistingObjects(context.Background(), c, []*unstructured.Unstructured{
+		newObject("stuck"),
+		newObject("gone"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(existing).To(HaveLen(1))
+	g.Expect(existing[0].GetName()).To(Equal("stuck"))
+}
+
+func TestSplitByScope(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		return obj
+	}
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{
+		{Version: "v1"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1"},
+	})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	c := fake.NewClientBuilder().
+		WithScheme(defaultScheme()).
+		WithRESTMapper(mapper).
+		Build()
+
+	namespaced, clusterScoped, err := SplitByScope(c, []*unstructured.Unstructured{
+		newObject("v1", "Namespace", "apps"),
+		newObject("rbac.authorization.k8s.io/v1", "ClusterRole", "reader"),
+		newObject("v1", "ConfigMap", "config"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(namespaced).To(HaveLen(1))
+	g.Expect(namespaced[0].GetName()).To(Equal("config"))
+	g.Expect(clusterScoped).To(HaveLen(2))
+	g.Expect(clusterScoped[0].GetName()).To(Equal("apps"))
+	g.Expect(clusterScoped[1].GetName()).To(Equal("reader"))
+
+	_, _, err = SplitByScope(c, []*unstructured.Unstructured{
+		newObject("example.com/v1", "Database", "db"),
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("scope lookup failed"))
+}
+
+func TestDeleteWithGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	newObject := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	t.Run("deletes existing objects", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().
+			WithScheme(defaultScheme()).
+			WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			}).
+			Build()
+		rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+		change, err := DeleteWithGracePeriod(ctx, rm, newObject("app"), ssa.DefaultDeleteOptions(), -1)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(change.Action).To(Equal(ssa.DeletedAction))
+	})
+
+	t.Run("reports missing objects as already deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().WithScheme(defaultScheme()).Build()
+		rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+		change, err := DeleteWithGracePeriod(ctx, rm, newObject("app"), ssa.DefaultDeleteOptions(), 0)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(change.Action).To(Equal(AlreadyDeletedAction))
+	})
+
+	t.Run("reports objects removed concurrently as already deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().
+			WithScheme(defaultScheme()).
+			WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+					return apierrors.NewNotFound(gr, obj.GetName())
+				},
+			}).
+			Build()
+		rm := ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager})
+
+		obj := newObject("app")
+		change, err := DeleteWithRetry(ctx, obj, RetryOptions{Retries: 1, Backoff: time.Millisecond}, func() (*ssa.ChangeSetEntry, error) {
+			return DeleteWithGracePeriod(ctx, rm, obj, ssa.DefaultDeleteOptions(), -1)
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(change.Action).To(Equal(AlreadyDeletedAction))
+	})
+}
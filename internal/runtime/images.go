@@ -0,0 +1,72 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ContainerImage holds the image reference of a container
+// found in the Pod spec of a Kubernetes object.
+type ContainerImage struct {
+	// Container is the name of the container.
+	Container string `json:"container"`
+	// Image is the container image reference including the digest if present.
+	Image string `json:"image"`
+}
+
+// podSpecPaths maps the kinds of the well-known workloads to the Pod spec path.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// GetContainerImages returns the images of the containers, init containers
+// and ephemeral containers defined in the Pod spec of the given object.
+// If the object kind doesn't contain a Pod spec, an empty list is returned.
+func GetContainerImages(object *unstructured.Unstructured) []ContainerImage {
+	podSpecPath, ok := podSpecPaths[object.GetKind()]
+	if !ok {
+		return nil
+	}
+
+	var images []ContainerImage
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		containers, found, err := unstructured.NestedSlice(object.Object, append(podSpecPath, field)...)
+		if err != nil || !found {
+			continue
+		}
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(container, "name")
+			image, _, _ := unstructured.NestedString(container, "image")
+			if image != "" {
+				images = append(images, ContainerImage{Container: name, Image: image})
+			}
+		}
+	}
+	return images
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetContainerImages(t *testing.T) {
+	g := NewWithT(t)
+
+	podSpec := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Image: "busybox:1.36"}},
+		Containers: []corev1.Container{
+			{Name: "app", Image: "ghcr.io/org/app:1.0.0@sha256:b49fbaac0eedc22c1cfcd26684707179cccbed0df205171bae3e1bae61326a10"},
+		},
+	}
+
+	deploy := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{Spec: podSpec},
+		},
+	}
+	us, err := ToUnstructured(deploy)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(GetContainerImages(us)).To(Equal([]ContainerImage{
+		{Container: "init", Image: "busybox:1.36"},
+		{Container: "app", Image: podSpec.Containers[0].Image},
+	}))
+
+	cronJob := &batchv1.CronJob{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+		Spec: batchv1.CronJobSpec{
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: corev1.PodTemplateSpec{Spec: podSpec},
+				},
+			},
+		},
+	}
+	us, err = ToUnstructured(cronJob)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(GetContainerImages(us)).To(HaveLen(2))
+
+	cm := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+	}
+	us, err = ToUnstructured(cm)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(GetContainerImages(us)).To(BeEmpty())
+}
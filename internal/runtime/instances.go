@@ -17,6 +17,8 @@ limitations under the License.
 package runtime
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"sort"
 
@@ -62,9 +64,14 @@ func (m *InstanceManager) AddObjects(objects []*unstructured.Unstructured) error
 		if err != nil {
 			return err
 		}
+		digest, err := ObjectDigest(om)
+		if err != nil {
+			return err
+		}
 		entries = append(entries, apiv1.ResourceRef{
 			ID:      objMetadata.String(),
 			Version: gv.Version,
+			Digest:  digest,
 		})
 	}
 
@@ -77,6 +84,46 @@ func (m *InstanceManager) AddObjects(objects []*unstructured.Unstructured) error
 	return nil
 }
 
+// RetainObjects copies the inventory entries of the given objects from the existing
+// instance, so that the objects remain part of this instance's inventory.
+func (m *InstanceManager) RetainObjects(existing *apiv1.Instance, objects []*unstructured.Unstructured) {
+	if existing == nil || existing.Inventory == nil || len(objects) == 0 {
+		return
+	}
+	if m.Instance.Inventory == nil {
+		m.Instance.Inventory = &apiv1.ResourceInventory{}
+	}
+
+	ids := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		ids[object.UnstructuredToObjMetadata(obj).String()] = true
+	}
+	for _, entry := range existing.Inventory.Entries {
+		if ids[entry.ID] {
+			m.Instance.Inventory.Entries = append(m.Instance.Inventory.Entries, entry)
+		}
+	}
+}
+
+// RemoveObjects removes the inventory entries of the given objects.
+func (m *InstanceManager) RemoveObjects(objects []*unstructured.Unstructured) {
+	if m.Instance.Inventory == nil || len(objects) == 0 {
+		return
+	}
+
+	ids := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		ids[object.UnstructuredToObjMetadata(obj).String()] = true
+	}
+	var entries []apiv1.ResourceRef
+	for _, entry := range m.Instance.Inventory.Entries {
+		if !ids[entry.ID] {
+			entries = append(entries, entry)
+		}
+	}
+	m.Instance.Inventory.Entries = entries
+}
+
 // VersionOf returns the API version of the given object if found in this instance.
 func (m *InstanceManager) VersionOf(objMetadata object.ObjMetadata) string {
 	if inv := m.Instance.Inventory; inv != nil {
@@ -89,6 +136,47 @@ func (m *InstanceManager) VersionOf(objMetadata object.ObjMetadata) string {
 	return ""
 }
 
+// DigestOf returns the content hash of the given object if found in this instance.
+func (m *InstanceManager) DigestOf(objMetadata object.ObjMetadata) string {
+	if inv := m.Instance.Inventory; inv != nil {
+		for _, entry := range inv.Entries {
+			if entry.ID == objMetadata.String() {
+				return entry.Digest
+			}
+		}
+	}
+	return ""
+}
+
+// Digest returns the SHA256 hash of the instance's inventory, in the format 'sha256:<hex>'.
+// The hash is computed from the ID, version and digest of each entry, so that it changes
+// whenever the instance is applied with different rendered objects.
+func (m *InstanceManager) Digest() string {
+	var entries []apiv1.ResourceRef
+	if inv := m.Instance.Inventory; inv != nil {
+		entries = append(entries, inv.Entries...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	h := sha256.New()
+	for _, entry := range entries {
+		fmt.Fprintf(h, "%s %s %s\n", entry.ID, entry.Version, entry.Digest)
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
+
+// ObjectDigest returns the SHA256 hash of the given object's JSON representation,
+// in the format 'sha256:<hex>'.
+func ObjectDigest(obj *unstructured.Unstructured) (string, error) {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("%s hashing failed: %w", ssa.FmtUnstructured(obj), err)
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
 // ListObjects returns the inventory entries as unstructured.Unstructured objects.
 func (m *InstanceManager) ListObjects() ([]*unstructured.Unstructured, error) {
 	objects := make([]*unstructured.Unstructured, 0)
@@ -0,0 +1,122 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/fluxcd/cli-utils/pkg/object"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestInstanceManager_DigestOf(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(name, value string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+
+	client := newConfigMap("client", "a")
+	server := newConfigMap("server", "a")
+
+	im := NewInstanceManager("app", "default", "", apiv1.ModuleReference{})
+	g.Expect(im.AddObjects([]*unstructured.Unstructured{client, server})).To(Succeed())
+
+	clientDigest, err := ObjectDigest(client)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(clientDigest).To(HavePrefix("sha256:"))
+	g.Expect(im.DigestOf(object.UnstructuredToObjMetadata(client))).To(Equal(clientDigest))
+
+	serverDigest, err := ObjectDigest(server)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(serverDigest).ToNot(Equal(clientDigest))
+
+	changed, err := ObjectDigest(newConfigMap("client", "b"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).ToNot(Equal(clientDigest))
+
+	same, err := ObjectDigest(newConfigMap("client", "a"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(same).To(Equal(clientDigest))
+
+	g.Expect(im.DigestOf(object.UnstructuredToObjMetadata(newConfigMap("missing", "a")))).To(BeEmpty())
+}
+
+func TestInstanceManager_Digest(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(name, value string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		obj.Object["data"] = map[string]interface{}{"key": value}
+		return obj
+	}
+
+	newInstance := func(objects ...*unstructured.Unstructured) *InstanceManager {
+		im := NewInstanceManager("app", "default", "", apiv1.ModuleReference{})
+		g.Expect(im.AddObjects(objects)).To(Succeed())
+		return im
+	}
+
+	digest := newInstance(newConfigMap("client", "a"), newConfigMap("server", "a")).Digest()
+	g.Expect(digest).To(HavePrefix("sha256:"))
+	g.Expect(newInstance(newConfigMap("server", "a"), newConfigMap("client", "a")).Digest()).To(Equal(digest))
+	g.Expect(newInstance(newConfigMap("client", "b"), newConfigMap("server", "a")).Digest()).ToNot(Equal(digest))
+	g.Expect(newInstance(newConfigMap("client", "a")).Digest()).ToNot(Equal(digest))
+
+	empty := NewInstanceManager("app", "default", "", apiv1.ModuleReference{})
+	g.Expect(empty.Digest()).To(HavePrefix("sha256:"))
+	g.Expect(empty.Digest()).ToNot(Equal(digest))
+}
+
+func TestInstanceManager_RemoveObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	config := newObject("ConfigMap", "app")
+	service := newObject("Service", "app")
+
+	im := NewInstanceManager("app", "default", "", apiv1.ModuleReference{})
+	g.Expect(im.AddObjects([]*unstructured.Unstructured{config, service})).To(Succeed())
+
+	im.RemoveObjects([]*unstructured.Unstructured{newObject("Service", "app")})
+
+	objects, err := im.ListObjects()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(objects).To(HaveLen(1))
+	g.Expect(objects[0].GetKind()).To(Equal("ConfigMap"))
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// KubeContext returns the name of the kubeconfig context used by the given flags,
+// which is the context set with --kube-context or the current context of the kubeconfig.
+func KubeContext(flags *genericclioptions.ConfigFlags) (string, error) {
+	if flags.Context != nil && *flags.Context != "" {
+		return *flags.Context, nil
+	}
+
+	raw, err := flags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("loading kubeconfig failed: %w", err)
+	}
+	return raw.CurrentContext, nil
+}
+
+// KubeContextExists returns true if the specified context is defined in the kubeconfig
+// loaded with the given flags.
+func KubeContextExists(flags *genericclioptions.ConfigFlags, kubeContext string) (bool, error) {
+	raw, err := flags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return false, fmt.Errorf("loading kubeconfig failed: %w", err)
+	}
+	_, ok := raw.Contexts[kubeContext]
+	return ok, nil
+}
+
+// WithKubeContext returns a copy of the given flags which uses the specified kubeconfig context.
+// The context must exist in the kubeconfig, the cluster and user settings are read from it,
+// while the connection and authentication flags set on the command line are kept.
+func WithKubeContext(flags *genericclioptions.ConfigFlags, kubeContext string) (*genericclioptions.ConfigFlags, error) {
+	exists, err := KubeContextExists(flags, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("kubeconfig context '%s' not found", kubeContext)
+	}
+
+	result := genericclioptions.NewConfigFlags(false)
+	result.KubeConfig = flags.KubeConfig
+	result.Namespace = flags.Namespace
+	result.Timeout = flags.Timeout
+	result.Impersonate = flags.Impersonate
+	result.ImpersonateUID = flags.ImpersonateUID
+	result.ImpersonateGroup = flags.ImpersonateGroup
+	result.BearerToken = flags.BearerToken
+	result.APIServer = flags.APIServer
+	result.TLSServerName = flags.TLSServerName
+	result.CertFile = flags.CertFile
+	result.KeyFile = flags.KeyFile
+	result.CAFile = flags.CAFile
+	result.Insecure = flags.Insecure
+	result.Context = &kubeContext
+	return result, nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestKubeContext(t *testing.T) {
+	g := NewWithT(t)
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	g.Expect(os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+- name: production
+  cluster:
+    server: https://production.example.com
+users:
+- name: admin
+  user:
+    token: test
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: admin
+- name: production
+  context:
+    cluster: production
+    user: admin
+`), 0o600)).To(Succeed())
+
+	flags := genericclioptions.NewConfigFlags(false)
+	flags.KubeConfig = &kubeconfig
+
+	current, err := KubeContext(flags)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(current).To(Equal("staging"))
+
+	production, err := WithKubeContext(flags, "production")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	current, err = KubeContext(production)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(current).To(Equal("production"))
+
+	cfg, err := production.ToRESTConfig()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.Host).To(Equal("https://production.example.com"))
+
+	// The original flags are not modified
+	current, err = KubeContext(flags)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(current).To(Equal("staging"))
+
+	exists, err := KubeContextExists(flags, "production")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exists).To(BeTrue())
+
+	exists, err = KubeContextExists(flags, "dev")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(exists).To(BeFalse())
+
+	// The connection and authentication flags are kept
+	token := "override"
+	server := "https://override.example.com"
+	flags.BearerToken = &token
+	flags.APIServer = &server
+	withFlags, err := WithKubeContext(flags, "production")
+	g.Expect(err).ToNot(HaveOccurred())
+	cfg, err = withFlags.ToRESTConfig()
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cfg.Host).To(Equal(server))
+	g.Expect(cfg.BearerToken).To(Equal(token))
+
+	_, err = WithKubeContext(flags, "dev")
+	g.Expect(err).To(MatchError(ContainSubstring("kubeconfig context 'dev' not found")))
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// GetManagedFields returns the managed fields of the in-cluster object,
+// and the managed fields that would result from server-side applying the given object.
+func GetManagedFields(ctx context.Context,
+	rm *ssa.ResourceManager,
+	object *unstructured.Unstructured) ([]metav1.ManagedFieldsEntry, []metav1.ManagedFieldsEntry, error) {
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	dryRunObject := object.DeepCopy()
+	err := rm.Client().Patch(ctx, dryRunObject, client.Apply,
+		client.DryRunAll,
+		client.ForceOwnership,
+		client.FieldOwner(ownerRef.Field))
+	if err != nil {
+		return nil, nil, ssa.NewDryRunErr(err, dryRunObject)
+	}
+
+	return existingObject.GetManagedFields(), dryRunObject.GetManagedFields(), nil
+}
+
+// OwnedFieldSet returns the set of fields owned by the Timoni field manager
+// in the given managed fields entries.
+func OwnedFieldSet(entries []metav1.ManagedFieldsEntry) (*fieldpath.Set, error) {
+	owned := &fieldpath.Set{}
+	for _, entry := range entries {
+		if entry.Manager != ownerRef.Field || entry.FieldsV1 == nil {
+			continue
+		}
+		set := &fieldpath.Set{}
+		if err := set.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+			return nil, fmt.Errorf("failed to parse the managed fields of %s: %w", entry.Manager, err)
+		}
+		owned = owned.Union(set)
+	}
+	return owned, nil
+}
+
+// RemoveUnownedFields removes from the given object the fields which are not in the owned set,
+// e.g. the fields set by the API server or by other controllers. The apiVersion, kind,
+// name and namespace are kept to identify the object.
+func RemoveUnownedFields(obj *unstructured.Unstructured, owned *fieldpath.Set) {
+	if obj == nil {
+		return
+	}
+	gvk := obj.GroupVersionKind()
+	name, namespace := obj.GetName(), obj.GetNamespace()
+
+	obj.Object, _ = filterOwnedFields(obj.Object, owned).(map[string]interface{})
+	if obj.Object == nil {
+		obj.Object = map[string]interface{}{}
+	}
+
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+}
+
+// filterOwnedFields returns the parts of the given value which are in the owned set.
+// The fields which are members of the set without children are kept as a whole,
+// while the ones with children are filtered recursively.
+func filterOwnedFields(v interface{}, owned *fieldpath.Set) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, item := range v {
+			pe := fieldpath.PathElement{FieldName: &key}
+			if children, ok := owned.Children.Get(pe); ok {
+				if filtered := filterOwnedFields(item, children); filtered != nil {
+					result[key] = filtered
+				}
+			} else if owned.Members.Has(pe) {
+				result[key] = item
+			}
+		}
+		if len(result) == 0 {
+			return nil
+		}
+		return result
+	case []interface{}:
+		var result []interface{}
+		for i, item := range v {
+			var children *fieldpath.Set
+			owned.Children.Iterate(func(pe fieldpath.PathElement) {
+				if children == nil && matchListItem(pe, i, item) {
+					children, _ = owned.Children.Get(pe)
+				}
+			})
+			if children != nil {
+				if filtered := filterOwnedFields(item, children); filtered != nil {
+					result = append(result, filtered)
+				}
+				continue
+			}
+
+			member := false
+			owned.Members.Iterate(func(pe fieldpath.PathElement) {
+				member = member || matchListItem(pe, i, item)
+			})
+			if member {
+				result = append(result, item)
+			}
+		}
+		if len(result) == 0 {
+			return nil
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// matchListItem returns true if the given path element identifies
+// the list item at the given index, by its keys, value or index.
+func matchListItem(pe fieldpath.PathElement, index int, item interface{}) bool {
+	switch {
+	case pe.Key != nil:
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, key := range *pe.Key {
+			field, ok := fields[key.Name]
+			if !ok || !value.Equals(key.Value, value.NewValueInterface(field)) {
+				return false
+			}
+		}
+		return true
+	case pe.Value != nil:
+		return value.Equals(*pe.Value, value.NewValueInterface(item))
+	case pe.Index != nil:
+		return *pe.Index == index
+	default:
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRemoveUnownedFields(t *testing.T) {
+	g := NewWithT(t)
+
+	entries := []metav1.ManagedFieldsEntry{
+		{
+			Manager:   ownerRef.Field,
+			Operation: metav1.ManagedFieldsOperationApply,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{
+				"f:metadata": {"f:labels": {"f:app": {}}},
+				"f:spec": {
+					"f:ports": {"k:{\"port\":80,\"protocol\":\"TCP\"}": {".": {}, "f:port": {}, "f:targetPort": {}}},
+					"f:selector": {}
+				}
+			}`)},
+		},
+		{
+			Manager:   "kube-controller-manager",
+			Operation: metav1.ManagedFieldsOperationUpdate,
+			FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:metadata": {"f:labels": {"f:controller": {}}}}`)},
+		},
+	}
+
+	owned, err := OwnedFieldSet(entries)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "app",
+			"namespace": "default",
+			"uid":       "1234",
+			"labels": map[string]interface{}{
+				"app":        "test",
+				"controller": "other",
+			},
+		},
+		"spec": map[string]interface{}{
+			"clusterIP": "10.0.0.1",
+			"ports": []interface{}{
+				map[string]interface{}{"port": int64(80), "protocol": "TCP", "targetPort": int64(8080)},
+				map[string]interface{}{"port": int64(443), "protocol": "TCP", "targetPort": int64(8443)},
+			},
+			"selector": map[string]interface{}{"app": "test"},
+		},
+	}}
+
+	RemoveUnownedFields(obj, owned)
+
+	g.Expect(obj.Object).To(Equal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "app",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": "test"},
+		},
+		"spec": map[string]interface{}{
+			"ports": []interface{}{
+				map[string]interface{}{"port": int64(80), "targetPort": int64(8080)},
+			},
+			"selector": map[string]interface{}{"app": "test"},
+		},
+	}))
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PrefixNames prepends the prefix to the name of the given objects and rewrites
+// the references to the renamed objects found in well-known fields:
+// - Pod specs: service account, image pull secrets, volumes, env and envFrom
+// - StatefulSets: governing service name
+// - Ingresses: backend services and TLS secrets
+// - HorizontalPodAutoscalers: scale target
+// - RoleBindings and ClusterRoleBindings: role and service account subjects
+// The values of the instance-identifying labels (app.kubernetes.io/name and
+// app.kubernetes.io/instance) are prefixed in the labels, pod templates and
+// selectors, so that the Services and workloads of instances colocated in the
+// same namespace select only their own pods.
+// Namespaces and CustomResourceDefinitions are not renamed. The names that
+// already start with '<prefix>-' are left unchanged.
+func PrefixNames(objects []*unstructured.Unstructured, prefix string) {
+	if prefix == "" {
+		return
+	}
+
+	np := &namePrefixer{
+		prefix: prefix,
+		names:  make(map[string]string),
+	}
+
+	for _, obj := range objects {
+		switch obj.GetKind() {
+		case "Namespace", "CustomResourceDefinition":
+			continue
+		}
+		np.names[nameRefKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())] = np.prefixed(obj.GetName())
+	}
+
+	for _, obj := range objects {
+		np.rewriteRefs(obj)
+		switch obj.GetKind() {
+		case "Namespace", "CustomResourceDefinition":
+		default:
+			np.rewriteLabels(obj)
+		}
+	}
+
+	for _, obj := range objects {
+		if name, ok := np.lookup(obj.GetKind(), obj.GetNamespace(), obj.GetName()); ok {
+			obj.SetName(name)
+		}
+	}
+}
+
+// prefixedLabels are the keys of the instance-identifying labels whose values are prefixed.
+var prefixedLabels = []string{"app.kubernetes.io/name", "app.kubernetes.io/instance"}
+
+type namePrefixer struct {
+	prefix string
+	names  map[string]string
+}
+
+func nameRefKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+func (np *namePrefixer) prefixed(name string) string {
+	if strings.HasPrefix(name, np.prefix+"-") {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", np.prefix, name)
+}
+
+func (np *namePrefixer) lookup(kind, namespace, name string) (string, bool) {
+	newName, ok := np.names[nameRefKey(kind, namespace, name)]
+	return newName, ok
+}
+
+// rename replaces the name found at the given path, if the referenced object is part of the set.
+func (np *namePrefixer) rename(obj map[string]interface{}, kind, namespace string, fields ...string) {
+	name, found, err := unstructured.NestedString(obj, fields...)
+	if err != nil || !found {
+		return
+	}
+	if newName, ok := np.lookup(kind, namespace, name); ok {
+		_ = unstructured.SetNestedField(obj, newName, fields...)
+	}
+}
+
+// forEach calls fn for every map item of the list found at the given path.
+func forEach(obj map[string]interface{}, fn func(item map[string]interface{}), fields ...string) {
+	items, found, err := unstructured.NestedSlice(obj, fields...)
+	if err != nil || !found {
+		return
+	}
+	for _, i := range items {
+		if item, ok := i.(map[string]interface{}); ok {
+			fn(item)
+		}
+	}
+	_ = unstructured.SetNestedSlice(obj, items, fields...)
+}
+
+func (np *namePrefixer) rewriteRefs(object *unstructured.Unstructured) {
+	ns := object.GetNamespace()
+	obj := object.Object
+
+	if podSpecPath, ok := podSpecPaths[object.GetKind()]; ok {
+		np.rewritePodSpecRefs(obj, ns, podSpecPath)
+	}
+
+	switch object.GetKind() {
+	case "StatefulSet":
+		np.rename(obj, "Service", ns, "spec", "serviceName")
+	case "Ingress":
+		np.rename(obj, "Service", ns, "spec", "defaultBackend", "service", "name")
+		forEach(obj, func(rule map[string]interface{}) {
+			forEach(rule, func(path map[string]interface{}) {
+				np.rename(path, "Service", ns, "backend", "service", "name")
+			}, "http", "paths")
+		}, "spec", "rules")
+		forEach(obj, func(tls map[string]interface{}) {
+			np.rename(tls, "Secret", ns, "secretName")
+		}, "spec", "tls")
+	case "HorizontalPodAutoscaler":
+		if kind, _, _ := unstructured.NestedString(obj, "spec", "scaleTargetRef", "kind"); kind != "" {
+			np.rename(obj, kind, ns, "spec", "scaleTargetRef", "name")
+		}
+	case "RoleBinding", "ClusterRoleBinding":
+		if kind, _, _ := unstructured.NestedString(obj, "roleRef", "kind"); kind != "" {
+			roleNs := ns
+			if kind == "ClusterRole" {
+				roleNs = ""
+			}
+			np.rename(obj, kind, roleNs, "roleRef", "name")
+		}
+		forEach(obj, func(subject map[string]interface{}) {
+			if kind, _, _ := unstructured.NestedString(subject, "kind"); kind == "ServiceAccount" {
+				subjectNs, _, _ := unstructured.NestedString(subject, "namespace")
+				np.rename(subject, kind, subjectNs, "name")
+			}
+		}, "subjects")
+	}
+}
+
+func (np *namePrefixer) rewritePodSpecRefs(obj map[string]interface{}, ns string, podSpecPath []string) {
+	spec, found, err := unstructured.NestedMap(obj, podSpecPath...)
+	if err != nil || !found {
+		return
+	}
+
+	np.rename(spec, "ServiceAccount", ns, "serviceAccountName")
+	np.rename(spec, "ServiceAccount", ns, "serviceAccount")
+
+	forEach(spec, func(secret map[string]interface{}) {
+		np.rename(secret, "Secret", ns, "name")
+	}, "imagePullSecrets")
+
+	forEach(spec, func(volume map[string]interface{}) {
+		np.rename(volume, "ConfigMap", ns, "configMap", "name")
+		np.rename(volume, "Secret", ns, "secret", "secretName")
+		np.rename(volume, "PersistentVolumeClaim", ns, "persistentVolumeClaim", "claimName")
+		forEach(volume, func(source map[string]interface{}) {
+			np.rename(source, "ConfigMap", ns, "configMap", "name")
+			np.rename(source, "Secret", ns, "secret", "name")
+		}, "projected", "sources")
+	}, "volumes")
+
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		forEach(spec, func(container map[string]interface{}) {
+			forEach(container, func(envFrom map[string]interface{}) {
+				np.rename(envFrom, "ConfigMap", ns, "configMapRef", "name")
+				np.rename(envFrom, "Secret", ns, "secretRef", "name")
+			}, "envFrom")
+			forEach(container, func(env map[string]interface{}) {
+				np.rename(env, "ConfigMap", ns, "valueFrom", "configMapKeyRef", "name")
+				np.rename(env, "Secret", ns, "valueFrom", "secretKeyRef", "name")
+			}, "env")
+		}, field)
+	}
+
+	_ = unstructured.SetNestedMap(obj, spec, podSpecPath...)
+}
+
+// rewriteLabels prefixes the values of the instance-identifying labels found in the
+// labels and matchLabels maps of the object, e.g. the pod template labels and the
+// label selectors of workloads, and in the selector of Services.
+func (np *namePrefixer) rewriteLabels(object *unstructured.Unstructured) {
+	np.prefixLabels(object.Object)
+
+	switch object.GetKind() {
+	case "Service", "ReplicationController":
+		if selector, ok := nestedMap(object.Object, "spec", "selector"); ok {
+			np.prefixLabelValues(selector)
+		}
+	}
+}
+
+func (np *namePrefixer) prefixLabels(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if labels, ok := item.(map[string]interface{}); ok && (key == "labels" || key == "matchLabels") {
+				np.prefixLabelValues(labels)
+				continue
+			}
+			np.prefixLabels(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			np.prefixLabels(item)
+		}
+	}
+}
+
+func (np *namePrefixer) prefixLabelValues(labels map[string]interface{}) {
+	for _, key := range prefixedLabels {
+		if value, ok := labels[key].(string); ok && value != "" {
+			labels[key] = np.prefixed(value)
+		}
+	}
+}
+
+func nestedMap(obj map[string]interface{}, fields ...string) (map[string]interface{}, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fields...)
+	if err != nil || !found {
+		return nil, false
+	}
+	m, ok := value.(map[string]interface{})
+	return m, ok
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPrefixNames(t *testing.T) {
+	g := NewWithT(t)
+	ns := "apps"
+
+	deploy := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "server", Namespace: ns},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					ServiceAccountName: "server",
+					Volumes: []corev1.Volume{
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: "config"},
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  "server",
+							Image: "nginx",
+							Env: []corev1.EnvVar{
+								{
+									Name: "TOKEN",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "external"},
+											Key:                  "token",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	objects := []*unstructured.Unstructured{}
+	for _, obj := range []apiruntime.Object{
+		deploy,
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{Name: "server", Namespace: ns},
+		},
+		&corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: ns},
+		},
+		&corev1.Service{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: metav1.ObjectMeta{Name: "app-server", Namespace: ns},
+		},
+		&corev1.Namespace{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{Name: ns},
+		},
+		&rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: "server", Namespace: ns},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "server", Namespace: ns}},
+		},
+	} {
+		us, err := ToUnstructured(obj)
+		g.Expect(err).ToNot(HaveOccurred())
+		objects = append(objects, us)
+	}
+
+	PrefixNames(objects, "app")
+
+	g.Expect(objects[0].GetName()).To(Equal("app-server"))
+	g.Expect(objects[1].GetName()).To(Equal("app-server"))
+	g.Expect(objects[2].GetName()).To(Equal("app-config"))
+	g.Expect(objects[3].GetName()).To(Equal("app-server"))
+	g.Expect(objects[4].GetName()).To(Equal(ns))
+	g.Expect(objects[5].GetName()).To(Equal("app-server"))
+
+	podSpec, _, _ := unstructured.NestedMap(objects[0].Object, "spec", "template", "spec")
+	g.Expect(podSpec["serviceAccountName"]).To(Equal("app-server"))
+
+	volumes, _, _ := unstructured.NestedSlice(podSpec, "volumes")
+	cmName, _, _ := unstructured.NestedString(volumes[0].(map[string]interface{}), "configMap", "name")
+	g.Expect(cmName).To(Equal("app-config"))
+
+	containers, _, _ := unstructured.NestedSlice(podSpec, "containers")
+	env, _, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env")
+	secretName, _, _ := unstructured.NestedString(env[0].(map[string]interface{}), "valueFrom", "secretKeyRef", "name")
+	g.Expect(secretName).To(Equal("external"))
+
+	roleName, _, _ := unstructured.NestedString(objects[5].Object, "roleRef", "name")
+	g.Expect(roleName).To(Equal("view"))
+	subjects, _, _ := unstructured.NestedSlice(objects[5].Object, "subjects")
+	g.Expect(subjects[0].(map[string]interface{})["name"]).To(Equal("app-server"))
+}
+
+func TestPrefixNames_Selectors(t *testing.T) {
+	g := NewWithT(t)
+	ns := "apps"
+	labels := map[string]string{
+		"app.kubernetes.io/name":    "server",
+		"app.kubernetes.io/version": "1.0.0",
+	}
+
+	deploy := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "server", Namespace: ns, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/name": "server"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "server", Image: "nginx"}},
+					Affinity: &corev1.Affinity{
+						PodAntiAffinity: &corev1.PodAntiAffinity{
+							PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+								{
+									Weight: 1,
+									PodAffinityTerm: corev1.PodAffinityTerm{
+										TopologyKey:   "kubernetes.io/hostname",
+										LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/name": "server"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	svc := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Name: "server", Namespace: ns, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app.kubernetes.io/name": "server"},
+		},
+	}
+	namespace := &corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: ns, Labels: labels},
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, obj := range []apiruntime.Object{deploy, svc, namespace} {
+		us, err := ToUnstructured(obj)
+		g.Expect(err).ToNot(HaveOccurred())
+		objects = append(objects, us)
+	}
+
+	PrefixNames(objects, "app")
+
+	g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/name", "app-server"))
+	g.Expect(objects[0].GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/version", "1.0.0"))
+	matchLabels, _, _ := unstructured.NestedStringMap(objects[0].Object, "spec", "selector", "matchLabels")
+	g.Expect(matchLabels).To(HaveKeyWithValue("app.kubernetes.io/name", "app-server"))
+	podLabels, _, _ := unstructured.NestedStringMap(objects[0].Object, "spec", "template", "metadata", "labels")
+	g.Expect(podLabels).To(HaveKeyWithValue("app.kubernetes.io/name", "app-server"))
+	terms, _, _ := unstructured.NestedSlice(objects[0].Object,
+		"spec", "template", "spec", "affinity", "podAntiAffinity", "preferredDuringSchedulingIgnoredDuringExecution")
+	termLabels, _, _ := unstructured.NestedStringMap(terms[0].(map[string]interface{}),
+		"podAffinityTerm", "labelSelector", "matchLabels")
+	g.Expect(termLabels).To(HaveKeyWithValue("app.kubernetes.io/name", "app-server"))
+
+	selector, _, _ := unstructured.NestedStringMap(objects[1].Object, "spec", "selector")
+	g.Expect(selector).To(HaveKeyWithValue("app.kubernetes.io/name", "app-server"))
+	g.Expect(objects[1].GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/name", "app-server"))
+
+	g.Expect(objects[2].GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/name", "server"))
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+// podPlacementFields are the Pod spec fields which determine the node a Pod is scheduled on.
+var podPlacementFields = []string{"nodeName", "nodeSelector", "affinity", "tolerations"}
+
+// pvcSelectedNodeAnnotation is set by the scheduler on the claims bound with
+// the WaitForFirstConsumer volume binding mode.
+const pvcSelectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+
+// PreservePlacements copies the node placement fields from the live state to the
+// objects annotated with the preserve-placement action, to avoid rescheduling the
+// stateful workloads on upgrades. It returns the objects which exist in the cluster
+// and had their placement preserved.
+func PreservePlacements(ctx context.Context,
+	rm *ssa.ResourceManager,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	var preserved []*unstructured.Unstructured
+	for _, object := range objects {
+		if !ssa.AnyInMetadata(object, map[string]string{
+			apiv1.PreservePlacementAction: apiv1.EnabledValue,
+		}) {
+			continue
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(object.GroupVersionKind())
+		if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(object), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("%s query failed: %w", ssa.FmtUnstructured(object), err)
+		}
+
+		if PreservePlacement(object, live) {
+			preserved = append(preserved, object)
+		}
+	}
+	return preserved, nil
+}
+
+// PreservePlacement sets the node placement fields of the object to the values
+// found in the live state, the fields missing from the live state are removed.
+// For workloads, the fields are the Pod spec nodeName, nodeSelector, affinity
+// and tolerations. For PersistentVolumeClaims, the fields are the bound volume
+// name and the node selected by the scheduler. It returns false if the object
+// kind has no placement fields.
+func PreservePlacement(object, live *unstructured.Unstructured) bool {
+	if object.GetKind() == "PersistentVolumeClaim" {
+		copyNestedField(live, object, "spec", "volumeName")
+		if node, ok := live.GetAnnotations()[pvcSelectedNodeAnnotation]; ok {
+			annotations := object.GetAnnotations()
+			annotations[pvcSelectedNodeAnnotation] = node
+			object.SetAnnotations(annotations)
+		}
+		return true
+	}
+
+	podSpecPath, ok := podSpecPaths[object.GetKind()]
+	if !ok {
+		return false
+	}
+
+	for _, field := range podPlacementFields {
+		copyNestedField(live, object, append(append([]string{}, podSpecPath...), field)...)
+	}
+	return true
+}
+
+// copyNestedField sets the field at the given path in the destination object
+// to the value found in the source, or removes it if the source doesn't contain it.
+// The destination is left unchanged if the source field has an unexpected type.
+func copyNestedField(src, dst *unstructured.Unstructured, fields ...string) {
+	value, found, err := unstructured.NestedFieldCopy(src.Object, fields...)
+	if err != nil {
+		return
+	}
+	if !found {
+		unstructured.RemoveNestedField(dst.Object, fields...)
+		return
+	}
+	_ = unstructured.SetNestedField(dst.Object, value, fields...)
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestPreservePlacement(t *testing.T) {
+	newStatefulSet := func(podSpec corev1.PodSpec) *unstructured.Unstructured {
+		sts := &appsv1.StatefulSet{
+			TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "db",
+				Namespace:   "apps",
+				Annotations: map[string]string{apiv1.PreservePlacementAction: apiv1.EnabledValue},
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Template: corev1.PodTemplateSpec{Spec: podSpec},
+			},
+		}
+		data, err := apiruntime.DefaultUnstructuredConverter.ToUnstructured(sts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &unstructured.Unstructured{Object: data}
+	}
+
+	t.Run("keeps the live placement of workloads", func(t *testing.T) {
+		g := NewWithT(t)
+
+		object := newStatefulSet(corev1.PodSpec{
+			NodeSelector: map[string]string{"zone": "b"},
+			Tolerations:  []corev1.Toleration{{Key: "dedicated", Value: "db"}},
+		})
+		live := newStatefulSet(corev1.PodSpec{
+			NodeSelector: map[string]string{"zone": "a"},
+		})
+
+		g.Expect(PreservePlacement(object, live)).To(BeTrue())
+
+		nodeSelector, _, _ := unstructured.NestedStringMap(object.Object, "spec", "template", "spec", "nodeSelector")
+		g.Expect(nodeSelector).To(Equal(map[string]string{"zone": "a"}))
+
+		_, found, _ := unstructured.NestedFieldNoCopy(object.Object, "spec", "template", "spec", "tolerations")
+		g.Expect(found).To(BeFalse())
+	})
+
+	t.Run("keeps the live volume of claims", func(t *testing.T) {
+		g := NewWithT(t)
+
+		newClaim := func(volumeName string, annotations map[string]string) *unstructured.Unstructured {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolumeClaim",
+				"metadata":   map[string]interface{}{"name": "data", "namespace": "apps"},
+				"spec":       map[string]interface{}{},
+			}}
+			if volumeName != "" {
+				_ = unstructured.SetNestedField(obj.Object, volumeName, "spec", "volumeName")
+			}
+			obj.SetAnnotations(annotations)
+			return obj
+		}
+
+		object := newClaim("", map[string]string{apiv1.PreservePlacementAction: apiv1.EnabledValue})
+		live := newClaim("pv-1", map[string]string{pvcSelectedNodeAnnotation: "node-1"})
+
+		g.Expect(PreservePlacement(object, live)).To(BeTrue())
+
+		volumeName, _, _ := unstructured.NestedString(object.Object, "spec", "volumeName")
+		g.Expect(volumeName).To(Equal("pv-1"))
+		g.Expect(object.GetAnnotations()).To(HaveKeyWithValue(pvcSelectedNodeAnnotation, "node-1"))
+		g.Expect(object.GetAnnotations()).To(HaveKeyWithValue(apiv1.PreservePlacementAction, apiv1.EnabledValue))
+	})
+
+	t.Run("ignores kinds without placement", func(t *testing.T) {
+		g := NewWithT(t)
+
+		object := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "config", "namespace": "apps"},
+		}}
+
+		g.Expect(PreservePlacement(object, object.DeepCopy())).To(BeFalse())
+	})
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fluxcd/pkg/ssa"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// PodSecurityPrivileged is the unrestricted Pod Security Standard level.
+	PodSecurityPrivileged = "privileged"
+	// PodSecurityBaseline is the Pod Security Standard level which prevents known privilege escalations.
+	PodSecurityBaseline = "baseline"
+	// PodSecurityRestricted is the Pod Security Standard level which enforces the Pod hardening best practices.
+	PodSecurityRestricted = "restricted"
+)
+
+// PodSecurityViolation holds the details of a Pod Security Standard check failure.
+type PodSecurityViolation struct {
+	// Object is the ID of the object in the format 'kind/namespace/name'.
+	Object string `json:"object"`
+	// Rule is the ID of the Pod Security Standard check.
+	Rule string `json:"rule"`
+	// Message describes the fields that don't meet the check requirements.
+	Message string `json:"message"`
+}
+
+// baselineCapabilities are the capabilities allowed to be added at the baseline level.
+var baselineCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true,
+	"FSETID": true, "KILL": true, "MKNOD": true, "NET_BIND_SERVICE": true,
+	"SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// safeSysctls are the sysctls allowed at the baseline level.
+var safeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_local_reserved_ports":    true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.ping_group_range":           true,
+	"net.ipv4.tcp_keepalive_time":         true,
+	"net.ipv4.tcp_fin_timeout":            true,
+	"net.ipv4.tcp_keepalive_intvl":        true,
+	"net.ipv4.tcp_keepalive_probes":       true,
+}
+
+// seLinuxTypes are the SELinux types allowed at the baseline level.
+var seLinuxTypes = map[string]bool{
+	"": true, "container_t": true, "container_init_t": true, "container_kvm_t": true,
+}
+
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+// ValidatePodSecurityLevel returns an error if the given level is not a Pod Security Standard level.
+func ValidatePodSecurityLevel(level string) error {
+	switch level {
+	case PodSecurityPrivileged, PodSecurityBaseline, PodSecurityRestricted:
+		return nil
+	default:
+		return fmt.Errorf("unsupported Pod Security Standard level '%s', can be '%s', '%s' or '%s'",
+			level, PodSecurityPrivileged, PodSecurityBaseline, PodSecurityRestricted)
+	}
+}
+
+// CheckPodSecurity statically checks the Pod spec of the given object against the
+// Pod Security Standard level, and returns the violations. The objects that don't
+// contain a Pod spec, and all objects at the privileged level, have no violations.
+// Ref: https://kubernetes.io/docs/concepts/security/pod-security-standards/
+func CheckPodSecurity(object *unstructured.Unstructured, level string) ([]PodSecurityViolation, error) {
+	if err := ValidatePodSecurityLevel(level); err != nil {
+		return nil, err
+	}
+
+	podSpecPath, ok := podSpecPaths[object.GetKind()]
+	if !ok || level == PodSecurityPrivileged {
+		return nil, nil
+	}
+
+	podSpecData, found, err := unstructured.NestedMap(object.Object, podSpecPath...)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var podSpec corev1.PodSpec
+	if err := apiruntime.DefaultUnstructuredConverter.FromUnstructured(podSpecData, &podSpec); err != nil {
+		return nil, fmt.Errorf("%s invalid Pod spec: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	// The Pod metadata is a sibling of the Pod spec.
+	podMetaPath := append(append([]string{}, podSpecPath[:len(podSpecPath)-1]...), "metadata", "annotations")
+	podAnnotations, _, _ := unstructured.NestedStringMap(object.Object, podMetaPath...)
+
+	var violations []PodSecurityViolation
+	report := func(rule, format string, args ...any) {
+		violations = append(violations, PodSecurityViolation{
+			Object:  ssa.FmtUnstructured(object),
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	checkBaseline(&podSpec, podAnnotations, report)
+	if level == PodSecurityRestricted {
+		checkRestricted(&podSpec, report)
+	}
+
+	return violations, nil
+}
+
+// podContainer holds a container of a Pod spec and its kind e.g. 'initContainer'.
+type podContainer struct {
+	kind            string
+	name            string
+	securityContext *corev1.SecurityContext
+	ports           []corev1.ContainerPort
+}
+
+func (c podContainer) String() string {
+	return fmt.Sprintf("%s %q", c.kind, c.name)
+}
+
+func podContainers(spec *corev1.PodSpec) []podContainer {
+	var containers []podContainer
+	for _, c := range spec.InitContainers {
+		containers = append(containers, podContainer{"initContainer", c.Name, c.SecurityContext, c.Ports})
+	}
+	for _, c := range spec.Containers {
+		containers = append(containers, podContainer{"container", c.Name, c.SecurityContext, c.Ports})
+	}
+	for _, c := range spec.EphemeralContainers {
+		containers = append(containers, podContainer{"ephemeralContainer", c.Name, c.SecurityContext, c.Ports})
+	}
+	return containers
+}
+
+func checkBaseline(spec *corev1.PodSpec, annotations map[string]string, report func(rule, format string, args ...any)) {
+	psc := spec.SecurityContext
+	if psc == nil {
+		psc = &corev1.PodSecurityContext{}
+	}
+
+	if psc.WindowsOptions != nil && psc.WindowsOptions.HostProcess != nil && *psc.WindowsOptions.HostProcess {
+		report("hostProcess", "pod must not set securityContext.windowsOptions.hostProcess=true")
+	}
+
+	var hostNamespaces []string
+	if spec.HostNetwork {
+		hostNamespaces = append(hostNamespaces, "hostNetwork=true")
+	}
+	if spec.HostPID {
+		hostNamespaces = append(hostNamespaces, "hostPID=true")
+	}
+	if spec.HostIPC {
+		hostNamespaces = append(hostNamespaces, "hostIPC=true")
+	}
+	if len(hostNamespaces) > 0 {
+		report("hostNamespaces", "pod must not set %s", strings.Join(hostNamespaces, ", "))
+	}
+
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			report("hostPathVolumes", "volume %q must not use hostPath", v.Name)
+		}
+	}
+
+	if psc.SELinuxOptions != nil {
+		checkSELinux("pod", psc.SELinuxOptions, report)
+	}
+	if psc.SeccompProfile != nil && psc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		report("seccompProfile_baseline", "pod must not set securityContext.seccompProfile.type=Unconfined")
+	}
+	for _, s := range psc.Sysctls {
+		if !safeSysctls[s.Name] {
+			report("sysctls", "pod must not set the unsafe sysctl %s", s.Name)
+		}
+	}
+
+	for key, value := range annotations {
+		if strings.HasPrefix(key, appArmorAnnotationPrefix) &&
+			value != "runtime/default" && !strings.HasPrefix(value, "localhost/") {
+			report("appArmorProfile", "annotation %s must not set the AppArmor profile %q", key, value)
+		}
+	}
+
+	for _, c := range podContainers(spec) {
+		for _, p := range c.ports {
+			if p.HostPort != 0 {
+				report("hostPorts", "%s must not set hostPort=%d", c, p.HostPort)
+			}
+		}
+
+		sc := c.securityContext
+		if sc == nil {
+			continue
+		}
+		if sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+			report("hostProcess", "%s must not set securityContext.windowsOptions.hostProcess=true", c)
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			report("privileged", "%s must not set securityContext.privileged=true", c)
+		}
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Add {
+				if !baselineCapabilities[capability] {
+					report("capabilities_baseline", "%s must not add the capability %s", c, capability)
+				}
+			}
+		}
+		if sc.SELinuxOptions != nil {
+			checkSELinux(c.String(), sc.SELinuxOptions, report)
+		}
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			report("procMount", "%s must not set securityContext.procMount=%s", c, *sc.ProcMount)
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			report("seccompProfile_baseline", "%s must not set securityContext.seccompProfile.type=Unconfined", c)
+		}
+	}
+}
+
+func checkSELinux(subject string, opts *corev1.SELinuxOptions, report func(rule, format string, args ...any)) {
+	if !seLinuxTypes[opts.Type] {
+		report("seLinuxOptions", "%s must not set securityContext.seLinuxOptions.type=%s", subject, opts.Type)
+	}
+	if opts.User != "" || opts.Role != "" {
+		report("seLinuxOptions", "%s must not set securityContext.seLinuxOptions.user or role", subject)
+	}
+}
+
+func checkRestricted(spec *corev1.PodSpec, report func(rule, format string, args ...any)) {
+	psc := spec.SecurityContext
+	if psc == nil {
+		psc = &corev1.PodSecurityContext{}
+	}
+	windows := spec.OS != nil && spec.OS.Name == corev1.Windows
+
+	for _, v := range spec.Volumes {
+		switch {
+		case v.ConfigMap != nil, v.CSI != nil, v.DownwardAPI != nil, v.EmptyDir != nil,
+			v.Ephemeral != nil, v.PersistentVolumeClaim != nil, v.Projected != nil, v.Secret != nil:
+		case v.HostPath != nil:
+			// Reported by the baseline hostPathVolumes check.
+		default:
+			report("restrictedVolumes", "volume %q must use one of the allowed volume types", v.Name)
+		}
+	}
+
+	podRunAsNonRoot := psc.RunAsNonRoot != nil && *psc.RunAsNonRoot
+	if psc.RunAsNonRoot != nil && !*psc.RunAsNonRoot {
+		report("runAsNonRoot", "pod must not set securityContext.runAsNonRoot=false")
+	}
+	if psc.RunAsUser != nil && *psc.RunAsUser == 0 {
+		report("runAsUser", "pod must not set securityContext.runAsUser=0")
+	}
+	podSeccomp := psc.SeccompProfile != nil &&
+		(psc.SeccompProfile.Type == corev1.SeccompProfileTypeRuntimeDefault || psc.SeccompProfile.Type == corev1.SeccompProfileTypeLocalhost)
+
+	for _, c := range podContainers(spec) {
+		sc := c.securityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		if !windows && (sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation) {
+			report("allowPrivilegeEscalation", "%s must set securityContext.allowPrivilegeEscalation=false", c)
+		}
+
+		if sc.RunAsNonRoot != nil {
+			if !*sc.RunAsNonRoot {
+				report("runAsNonRoot", "%s must not set securityContext.runAsNonRoot=false", c)
+			}
+		} else if !podRunAsNonRoot {
+			report("runAsNonRoot", "%s or pod must set securityContext.runAsNonRoot=true", c)
+		}
+
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			report("runAsUser", "%s must not set securityContext.runAsUser=0", c)
+		}
+
+		if !windows {
+			if sc.SeccompProfile != nil {
+				if sc.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault && sc.SeccompProfile.Type != corev1.SeccompProfileTypeLocalhost {
+					report("seccompProfile_restricted", "%s must set securityContext.seccompProfile.type to RuntimeDefault or Localhost", c)
+				}
+			} else if !podSeccomp {
+				report("seccompProfile_restricted", "%s or pod must set securityContext.seccompProfile.type to RuntimeDefault or Localhost", c)
+			}
+
+			dropsAll := false
+			if sc.Capabilities != nil {
+				for _, capability := range sc.Capabilities.Drop {
+					if capability == "ALL" {
+						dropsAll = true
+					}
+				}
+				for _, capability := range sc.Capabilities.Add {
+					if capability != "NET_BIND_SERVICE" {
+						report("capabilities_restricted", "%s must not add the capability %s", c, capability)
+					}
+				}
+			}
+			if !dropsAll {
+				report("capabilities_restricted", "%s must set securityContext.capabilities.drop=[\"ALL\"]", c)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckPodSecurity(t *testing.T) {
+	newDeployment := func(podSpec corev1.PodSpec) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{Spec: podSpec},
+			},
+		}
+	}
+
+	rules := func(violations []PodSecurityViolation) []string {
+		var result []string
+		for _, v := range violations {
+			result = append(result, v.Rule)
+		}
+		return result
+	}
+
+	t.Run("rejects unknown level", func(t *testing.T) {
+		g := NewWithT(t)
+		obj, err := ToUnstructured(newDeployment(corev1.PodSpec{}))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = CheckPodSecurity(obj, "strict")
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("ignores objects without Pod spec", func(t *testing.T) {
+		g := NewWithT(t)
+		obj, err := ToUnstructured(&corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		violations, err := CheckPodSecurity(obj, PodSecurityRestricted)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(violations).To(BeEmpty())
+	})
+
+	privileged := true
+	insecure := newDeployment(corev1.PodSpec{
+		HostNetwork: true,
+		Volumes: []corev1.Volume{{
+			Name:         "host",
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}},
+		}},
+		Containers: []corev1.Container{{
+			Name:            "app",
+			Image:           "app:1.0.0",
+			SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+		}},
+	})
+
+	t.Run("reports baseline violations", func(t *testing.T) {
+		g := NewWithT(t)
+		obj, err := ToUnstructured(insecure)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		violations, err := CheckPodSecurity(obj, PodSecurityBaseline)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(rules(violations)).To(ConsistOf("hostNamespaces", "hostPathVolumes", "privileged"))
+		g.Expect(violations[0].Object).To(Equal("Deployment/default/app"))
+	})
+
+	t.Run("allows everything at the privileged level", func(t *testing.T) {
+		g := NewWithT(t)
+		obj, err := ToUnstructured(insecure)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		violations, err := CheckPodSecurity(obj, PodSecurityPrivileged)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(violations).To(BeEmpty())
+	})
+
+	t.Run("reports restricted violations", func(t *testing.T) {
+		g := NewWithT(t)
+		obj, err := ToUnstructured(newDeployment(corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "app:1.0.0"}},
+		}))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		violations, err := CheckPodSecurity(obj, PodSecurityRestricted)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(rules(violations)).To(ConsistOf(
+			"allowPrivilegeEscalation",
+			"runAsNonRoot",
+			"seccompProfile_restricted",
+			"capabilities_restricted",
+		))
+	})
+
+	t.Run("passes hardened Pod spec at the restricted level", func(t *testing.T) {
+		g := NewWithT(t)
+		nonRoot := true
+		escalation := false
+		obj, err := ToUnstructured(newDeployment(corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot:   &nonRoot,
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "app:1.0.0",
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &escalation,
+					Capabilities: &corev1.Capabilities{
+						Add:  []corev1.Capability{"NET_BIND_SERVICE"},
+						Drop: []corev1.Capability{"ALL"},
+					},
+				},
+			}},
+		}))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		violations, err := CheckPodSecurity(obj, PodSecurityRestricted)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(violations).To(BeEmpty())
+	})
+}
@@ -31,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -43,13 +44,46 @@ var ownerRef = ssa.Owner{
 	Group: fmt.Sprintf("%s.%s", strings.ToLower(apiv1.InstanceKind), apiv1.GroupVersion.Group),
 }
 
+// SetOwnership overrides the naming scheme used for the instance storage
+// and for the ownership metadata injected into the managed objects.
+// The storage prefix is prepended to the instance name to form the name of the
+// inventory Secret. The managed-by value is used as the server-side apply field manager
+// and as the created-by label value. When the managed-by value differs from the default,
+// the ownership labels group is scoped to '<managed-by>.instance.timoni.sh'.
+func SetOwnership(storagePrefixValue, managedBy string) error {
+	if errs := validation.IsDNS1123Label(storagePrefixValue); len(errs) > 0 {
+		return fmt.Errorf("invalid inventory prefix '%s': %s", storagePrefixValue, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Label(managedBy); len(errs) > 0 {
+		return fmt.Errorf("invalid managed-by value '%s': %s", managedBy, strings.Join(errs, ", "))
+	}
+
+	storagePrefix = fmt.Sprintf("%s.", storagePrefixValue)
+	ownerRef.Field = managedBy
+	ownerRef.Group = fmt.Sprintf("%s.%s", strings.ToLower(apiv1.InstanceKind), apiv1.GroupVersion.Group)
+	if managedBy != apiv1.FieldManager {
+		ownerRef.Group = fmt.Sprintf("%s.%s", managedBy, ownerRef.Group)
+	}
+	return nil
+}
+
 // NewResourceManager creates a ResourceManager for the given cluster.
 func NewResourceManager(rcg genericclioptions.RESTClientGetter) (*ssa.ResourceManager, error) {
+	return NewResourceManagerWithWarnings(rcg, nil)
+}
+
+// NewResourceManagerWithWarnings creates a ResourceManager for the given cluster,
+// which records the warnings returned by the API server with the given recorder.
+func NewResourceManagerWithWarnings(rcg genericclioptions.RESTClientGetter, warnings *WarningRecorder) (*ssa.ResourceManager, error) {
 	cfg, err := rcg.ToRESTConfig()
 	if err != nil {
 		return nil, fmt.Errorf("loading kubeconfig failed: %w", err)
 	}
 
+	if warnings != nil {
+		cfg.WarningHandler = warnings
+	}
+
 	// bump limits
 	cfg.QPS = 100.0
 	cfg.Burst = 300
@@ -98,6 +132,29 @@ func SelectObjectsFromSet(set *ssa.ChangeSet, action ssa.Action) []*unstructured
 	return objects
 }
 
+// CommonLabels returns the labels that are set with the same value on all the given objects,
+// excluding the Timoni ownership labels.
+func CommonLabels(objects []*unstructured.Unstructured) map[string]string {
+	labels := make(map[string]string)
+	for i, object := range objects {
+		if i == 0 {
+			for k, v := range object.GetLabels() {
+				if !strings.HasPrefix(k, ownerRef.Group+"/") {
+					labels[k] = v
+				}
+			}
+			continue
+		}
+		objLabels := object.GetLabels()
+		for k, v := range labels {
+			if val, ok := objLabels[k]; !ok || val != v {
+				delete(labels, k)
+			}
+		}
+	}
+	return labels
+}
+
 // ApplyOptions returns the default options for server-side apply operations.
 func ApplyOptions(force bool, wait time.Duration) ssa.ApplyOptions {
 	return ssa.ApplyOptions{
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestSetOwnership(t *testing.T) {
+	g := NewWithT(t)
+	defer SetOwnership(apiv1.FieldManager, apiv1.FieldManager)
+
+	err := SetOwnership(apiv1.FieldManager, apiv1.FieldManager)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(storagePrefix).To(Equal("timoni."))
+	g.Expect(ownerRef.Field).To(Equal("timoni"))
+	g.Expect(ownerRef.Group).To(Equal("instance.timoni.sh"))
+
+	err = SetOwnership("team-a", "team-a")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(storagePrefix).To(Equal("team-a."))
+	g.Expect(ownerRef.Field).To(Equal("team-a"))
+	g.Expect(ownerRef.Group).To(Equal("team-a.instance.timoni.sh"))
+
+	sm := NewStorageManager(nil)
+	secret := sm.newSecret("app", "default")
+	g.Expect(secret.GetName()).To(Equal("team-a.app"))
+	g.Expect(secret.GetLabels()).To(HaveKeyWithValue(createdByLabelKey, "team-a"))
+
+	err = SetOwnership("Team_A", "team-a")
+	g.Expect(err).To(HaveOccurred())
+
+	err = SetOwnership("team-a", "")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCommonLabels(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(labels map[string]string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetLabels(labels)
+		return obj
+	}
+
+	objects := []*unstructured.Unstructured{
+		newObject(map[string]string{
+			"app.kubernetes.io/name":    "app",
+			"app.kubernetes.io/version": "1.0.0",
+			"app.kubernetes.io/part-of": "frontend",
+			ownerRef.Group + "/name":    "app",
+		}),
+		newObject(map[string]string{
+			"app.kubernetes.io/name":    "app",
+			"app.kubernetes.io/version": "1.0.1",
+			ownerRef.Group + "/name":    "app",
+		}),
+	}
+
+	labels := CommonLabels(objects)
+	g.Expect(labels).To(Equal(map[string]string{"app.kubernetes.io/name": "app"}))
+	g.Expect(CommonLabels(nil)).To(BeEmpty())
+}
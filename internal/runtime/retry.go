@@ -0,0 +1,94 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RetryOptions holds the settings for retrying Kubernetes API operations.
+type RetryOptions struct {
+	// Retries is the number of attempts made after the first failure.
+	Retries int
+	// Backoff is the delay before the first retry, doubled after each attempt.
+	Backoff time.Duration
+}
+
+// Retry calls fn until it succeeds, the error is not transient,
+// the retries are exhausted or the context is cancelled.
+func Retry(ctx context.Context, opts RetryOptions, fn func() error) error {
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= opts.Retries || !IsTransientError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// IsTransientError returns true if the error is caused by a temporary
+// failure of the Kubernetes API server e.g. 5xx responses, throttling or timeouts.
+// Errors such as not found, forbidden or invalid are not transient.
+func IsTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if apierrors.IsInternalError(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// DeleteWithRetry calls the delete function until it succeeds or the retries for
+// transient errors are exhausted. The object not found errors are considered
+// successful deletions and are reported with AlreadyDeletedAction.
+func DeleteWithRetry(ctx context.Context,
+	obj *unstructured.Unstructured,
+	opts RetryOptions,
+	deleteFn func() (*ssa.ChangeSetEntry, error)) (*ssa.ChangeSetEntry, error) {
+	var change *ssa.ChangeSetEntry
+	err := Retry(ctx, opts, func() (err error) {
+		change, err = deleteFn()
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return NewChangeSetEntry(obj, AlreadyDeletedAction), nil
+	}
+	return change, err
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsTransientError(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "internal error", err: apierrors.NewInternalError(errors.New("etcd leader changed")), transient: true},
+		{name: "service unavailable", err: apierrors.NewServiceUnavailable("upgrading"), transient: true},
+		{name: "too many requests", err: apierrors.NewTooManyRequests("throttled", 1), transient: true},
+		{name: "wrapped timeout", err: fmt.Errorf("delete failed: %w", apierrors.NewTimeoutError("timeout", 1)), transient: true},
+		{name: "not found", err: apierrors.NewNotFound(gr, "test"), transient: false},
+		{name: "forbidden", err: apierrors.NewForbidden(gr, "test", errors.New("denied")), transient: false},
+		{name: "context deadline", err: context.DeadlineExceeded, transient: false},
+		{name: "generic", err: errors.New("invalid object"), transient: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(IsTransientError(tt.err)).To(Equal(tt.transient))
+		})
+	}
+}
+
+func TestDeleteWithRetry(t *testing.T) {
+	ctx := context.Background()
+	opts := RetryOptions{Retries: 2, Backoff: time.Millisecond}
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName("test")
+	obj.SetNamespace("default")
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		g := NewWithT(t)
+		attempts := 0
+		change, err := DeleteWithRetry(ctx, obj, opts, func() (*ssa.ChangeSetEntry, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, apierrors.NewInternalError(errors.New("etcd leader changed"))
+			}
+			return NewChangeSetEntry(obj, ssa.DeletedAction), nil
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(change.Action).To(Equal(ssa.DeletedAction))
+		g.Expect(attempts).To(Equal(3))
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		g := NewWithT(t)
+		attempts := 0
+		_, err := DeleteWithRetry(ctx, obj, opts, func() (*ssa.ChangeSetEntry, error) {
+			attempts++
+			return nil, apierrors.NewServiceUnavailable("upgrading")
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(attempts).To(Equal(3))
+	})
+
+	t.Run("fails fast on forbidden", func(t *testing.T) {
+		g := NewWithT(t)
+		attempts := 0
+		_, err := DeleteWithRetry(ctx, obj, opts, func() (*ssa.ChangeSetEntry, error) {
+			attempts++
+			return nil, apierrors.NewForbidden(gr, "test", errors.New("denied"))
+		})
+		g.Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		g.Expect(attempts).To(Equal(1))
+	})
+
+	t.Run("reports not found as already deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		change, err := DeleteWithRetry(ctx, obj, opts, func() (*ssa.ChangeSetEntry, error) {
+			return nil, fmt.Errorf("delete failed: %w", apierrors.NewNotFound(gr, "test"))
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(change.Subject).To(Equal("ConfigMap/default/test"))
+		g.Expect(change.Action).To(Equal(AlreadyDeletedAction))
+	})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RedactedValue replaces the Secret values which are the same in both objects.
+	RedactedValue = "<redacted>"
+
+	// RedactedChangedValue replaces the Secret values which differ between the objects.
+	RedactedChangedValue = "<redacted: changed>"
+)
+
+// RedactSecretData replaces the values of the data and stringData fields of the
+// given Secrets, so that their diff reports which keys were added, removed or
+// changed without printing the values. The objects which are not Secrets are
+// left untouched. Any of the objects can be nil e.g. when the Secret is created.
+func RedactSecretData(from, to *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		fromData := secretField(from, field)
+		toData := secretField(to, field)
+
+		for key, fromValue := range fromData {
+			toValue, ok := toData[key]
+			if ok && !equality.Semantic.DeepEqual(fromValue, toValue) {
+				toData[key] = RedactedChangedValue
+			} else if ok {
+				toData[key] = RedactedValue
+			}
+			fromData[key] = RedactedValue
+		}
+		for key := range toData {
+			if _, ok := fromData[key]; !ok {
+				toData[key] = RedactedValue
+			}
+		}
+
+		setSecretField(from, field, fromData)
+		setSecretField(to, field, toData)
+	}
+}
+
+func secretField(obj *unstructured.Unstructured, field string) map[string]interface{} {
+	if obj == nil || !ssa.IsSecret(obj) {
+		return nil
+	}
+	data, _, _ := unstructured.NestedMap(obj.Object, field)
+	return data
+}
+
+func setSecretField(obj *unstructured.Unstructured, field string, data map[string]interface{}) {
+	if data == nil {
+		return
+	}
+	_ = unstructured.SetNestedMap(obj.Object, data, field)
+}
+
+// DryRunDiffUnmasked returns the in-cluster object and the object that would result
+// from server-side applying the given object, like ssa.ResourceManager.Diff but without
+// masking the data of Secrets. The managed fields are removed from both objects.
+func DryRunDiffUnmasked(ctx context.Context,
+	rm *ssa.ResourceManager,
+	object *unstructured.Unstructured) (*unstructured.Unstructured, *unstructured.Unstructured, error) {
+	existingObject := &unstructured.Unstructured{}
+	existingObject.SetGroupVersionKind(object.GroupVersionKind())
+	if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(object), existingObject); err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s: %w", ssa.FmtUnstructured(object), err)
+	}
+
+	dryRunObject := object.DeepCopy()
+	err := rm.Client().Patch(ctx, dryRunObject, client.Apply,
+		client.DryRunAll,
+		client.ForceOwnership,
+		client.FieldOwner(ownerRef.Field))
+	if err != nil {
+		return nil, nil, ssa.NewDryRunErr(err, dryRunObject)
+	}
+
+	unstructured.RemoveNestedField(existingObject.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(dryRunObject.Object, "metadata", "managedFields")
+	return existingObject, dryRunObject, nil
+}
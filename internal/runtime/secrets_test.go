@@ -0,0 +1,97 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRedactSecretData(t *testing.T) {
+	newSecret := func(data, stringData map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+		}}
+		if data != nil {
+			obj.Object["data"] = data
+		}
+		if stringData != nil {
+			obj.Object["stringData"] = stringData
+		}
+		return obj
+	}
+
+	t.Run("redacts the values of secrets", func(t *testing.T) {
+		g := NewWithT(t)
+		from := newSecret(map[string]interface{}{
+			"same":    "c2FtZQ==",
+			"changed": "b2xk",
+			"removed": "cmVtb3ZlZA==",
+		}, nil)
+		to := newSecret(map[string]interface{}{
+			"same":    "c2FtZQ==",
+			"changed": "bmV3",
+			"added":   "YWRkZWQ=",
+		}, map[string]interface{}{
+			"token": "plain",
+		})
+
+		RedactSecretData(from, to)
+
+		g.Expect(from.Object["data"]).To(Equal(map[string]interface{}{
+			"same":    RedactedValue,
+			"changed": RedactedValue,
+			"removed": RedactedValue,
+		}))
+		g.Expect(to.Object["data"]).To(Equal(map[string]interface{}{
+			"same":    RedactedValue,
+			"changed": RedactedChangedValue,
+			"added":   RedactedValue,
+		}))
+		g.Expect(to.Object["stringData"]).To(Equal(map[string]interface{}{
+			"token": RedactedValue,
+		}))
+		g.Expect(from.Object).ToNot(HaveKey("stringData"))
+	})
+
+	t.Run("handles nil objects", func(t *testing.T) {
+		g := NewWithT(t)
+		to := newSecret(map[string]interface{}{"token": "dG9rZW4="}, nil)
+
+		RedactSecretData(nil, to)
+		g.Expect(to.Object["data"]).To(Equal(map[string]interface{}{"token": RedactedValue}))
+	})
+
+	t.Run("ignores other kinds", func(t *testing.T) {
+		g := NewWithT(t)
+		from := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"data":       map[string]interface{}{"port": "8080"},
+		}}
+		to := from.DeepCopy()
+		to.Object["data"] = map[string]interface{}{"port": "9090"}
+
+		RedactSecretData(from, to)
+		g.Expect(from.Object["data"]).To(Equal(map[string]interface{}{"port": "8080"}))
+		g.Expect(to.Object["data"]).To(Equal(map[string]interface{}{"port": "9090"}))
+	})
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Snapshot holds the live state of a group of objects captured before apply.
+type Snapshot struct {
+	// existing holds the fields owned by the Timoni field manager
+	// in the live state of the objects found in the cluster.
+	existing []*unstructured.Unstructured
+	// missing holds the objects not found in the cluster.
+	missing []*unstructured.Unstructured
+}
+
+// TakeSnapshot fetches the live state of the given objects from the cluster.
+// Only the fields owned by the Timoni field manager are captured, so that restoring
+// the snapshot doesn't take the ownership of the fields set by other managers.
+func TakeSnapshot(ctx context.Context, rm *ssa.ResourceManager, objects []*unstructured.Unstructured) (*Snapshot, error) {
+	s := &Snapshot{}
+	for _, object := range objects {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(object.GroupVersionKind())
+		if err := rm.Client().Get(ctx, client.ObjectKeyFromObject(object), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				s.missing = append(s.missing, object)
+				continue
+			}
+			return nil, fmt.Errorf("%s query failed: %w", ssa.FmtUnstructured(object), err)
+		}
+		owned, err := snapshotObject(live)
+		if err != nil {
+			return nil, err
+		}
+		s.existing = append(s.existing, owned)
+	}
+	return s, nil
+}
+
+// snapshotObject returns a copy of the given live object holding only
+// the fields owned by the Timoni field manager.
+func snapshotObject(live *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	owned, err := OwnedFieldSet(live.GetManagedFields())
+	if err != nil {
+		return nil, err
+	}
+
+	obj := live.DeepCopy()
+	RemoveUnownedFields(obj, owned)
+	return obj, nil
+}
+
+// Restore reverts the objects to the captured state, by reapplying the fields owned
+// by Timoni in the objects which existed before and deleting the objects which
+// were created since. The fields added by the failed apply are dropped by the server.
+func (s *Snapshot) Restore(ctx context.Context, rm *ssa.ResourceManager) (*ssa.ChangeSet, error) {
+	changeSet := ssa.NewChangeSet()
+
+	if len(s.existing) > 0 {
+		objects := make([]*unstructured.Unstructured, 0, len(s.existing))
+		for _, obj := range s.existing {
+			objects = append(objects, obj.DeepCopy())
+		}
+
+		cs, err := rm.ApplyAll(ctx, objects, ssa.ApplyOptions{Force: true})
+		if err != nil {
+			return changeSet, fmt.Errorf("restoring objects failed: %w", err)
+		}
+		changeSet.Append(cs.Entries)
+	}
+
+	if len(s.missing) > 0 {
+		cs, err := rm.DeleteAll(ctx, s.missing, ssa.DeleteOptions{
+			PropagationPolicy: metav1.DeletePropagationBackground,
+		})
+		if err != nil {
+			return changeSet, fmt.Errorf("deleting created objects failed: %w", err)
+		}
+		changeSet.Append(cs.Entries)
+	}
+
+	return changeSet, nil
+}
+
+// GroupByNamespace groups the given objects by namespace, the cluster-scoped
+// objects are grouped under the empty namespace. The returned namespaces are sorted.
+func GroupByNamespace(objects []*unstructured.Unstructured) ([]string, map[string][]*unstructured.Unstructured) {
+	groups := make(map[string][]*unstructured.Unstructured)
+	for _, object := range objects {
+		groups[object.GetNamespace()] = append(groups[object.GetNamespace()], object)
+	}
+
+	namespaces := make([]string, 0, len(groups))
+	for ns := range groups {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	return namespaces, groups
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGroupByNamespace(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(kind, namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+
+	objects := []*unstructured.Unstructured{
+		newObject("ConfigMap", "team-b", "b"),
+		newObject("ConfigMap", "team-a", "a1"),
+		newObject("ClusterRole", "", "role"),
+		newObject("ConfigMap", "team-a", "a2"),
+	}
+
+	namespaces, groups := GroupByNamespace(objects)
+	g.Expect(namespaces).To(Equal([]string{"", "team-a", "team-b"}))
+	g.Expect(groups[""]).To(HaveLen(1))
+	g.Expect(groups["team-a"]).To(HaveLen(2))
+	g.Expect(groups["team-a"][0].GetName()).To(Equal("a1"))
+	g.Expect(groups["team-b"]).To(HaveLen(1))
+}
+
+func TestSnapshotObject(t *testing.T) {
+	g := NewWithT(t)
+
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "app",
+			"namespace":       "default",
+			"resourceVersion": "42",
+			"labels":          map[string]interface{}{"app": "test"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(5),
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "test"}},
+			},
+		},
+		"status": map[string]interface{}{"readyReplicas": int64(5)},
+	}}
+	live.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			Manager:   ownerRef.Field,
+			Operation: metav1.ManagedFieldsOperationApply,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{
+				"f:metadata": {"f:labels": {"f:app": {}}},
+				"f:spec": {"f:template": {"f:metadata": {"f:labels": {"f:app": {}}}}}
+			}`)},
+		},
+		{
+			Manager:   "horizontal-pod-autoscaler",
+			Operation: metav1.ManagedFieldsOperationUpdate,
+			FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:spec": {"f:replicas": {}}}`)},
+		},
+	})
+
+	obj, err := snapshotObject(live)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(obj.Object).To(Equal(map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "app",
+			"namespace": "default",
+			"labels":    map[string]interface{}{"app": "test"},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "test"}},
+			},
+		},
+	}))
+
+	// The live object must not be modified
+	g.Expect(live.Object["status"]).ToNot(BeNil())
+}
@@ -28,6 +28,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/json"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -44,7 +45,8 @@ var (
 
 // StorageManager manages the inventory in-cluster storage.
 type StorageManager struct {
-	resManager *ssa.ResourceManager
+	resManager      *ssa.ResourceManager
+	namespaceLabels map[string]string
 }
 
 // NewStorageManager creates a storage manager for the given cluster.
@@ -54,13 +56,16 @@ func NewStorageManager(resManager *ssa.ResourceManager) *StorageManager {
 	}
 }
 
+// SetNamespaceLabels sets the labels to be added to the inventory namespace.
+// The labels are merged with the existing ones, keeping the current
+// values of the labels already present on the namespace.
+func (s *StorageManager) SetNamespaceLabels(labels map[string]string) {
+	s.namespaceLabels = labels
+}
+
 // Apply creates or updates the storage object for the given instance.
 func (s *StorageManager) Apply(ctx context.Context, instance *apiv1.Instance, createNamespace bool) error {
 	instance.LastTransitionTime = time.Now().UTC().Format(time.RFC3339)
-	instanceData, err := json.Marshal(instance)
-	if err != nil {
-		return err
-	}
 
 	if createNamespace {
 		if err := s.createNamespace(ctx, instance.Namespace); err != nil {
@@ -69,6 +74,31 @@ func (s *StorageManager) Apply(ctx context.Context, instance *apiv1.Instance, cr
 	}
 
 	secret := s.newSecret(instance.Name, instance.Namespace)
+
+	// Keep the annotations set by previous applies, as the server-side apply
+	// would remove the ones missing from the patch.
+	existing := s.newSecret(instance.Name, instance.Namespace)
+	existingKey := client.ObjectKeyFromObject(existing)
+	err := s.resManager.Client().Get(ctx, existingKey, existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get Secret/%s: %w", existingKey, err)
+	}
+	if len(existing.Annotations) > 0 {
+		annotations := make(map[string]string, len(existing.Annotations)+len(instance.Annotations))
+		for annotationKey, annotationValue := range existing.Annotations {
+			annotations[annotationKey] = annotationValue
+		}
+		for annotationKey, annotationValue := range instance.Annotations {
+			annotations[annotationKey] = annotationValue
+		}
+		instance.Annotations = annotations
+	}
+
+	instanceData, err := json.Marshal(instance)
+	if err != nil {
+		return err
+	}
+
 	secret.Data = map[string][]byte{
 		storageDataKey: instanceData,
 	}
@@ -77,6 +107,13 @@ func (s *StorageManager) Apply(ctx context.Context, instance *apiv1.Instance, cr
 		secret.Labels[labelKey] = labelValue
 	}
 
+	if len(instance.Annotations) > 0 {
+		secret.Annotations = make(map[string]string, len(instance.Annotations))
+		for annotationKey, annotationValue := range instance.Annotations {
+			secret.Annotations[annotationKey] = annotationValue
+		}
+	}
+
 	opts := []client.PatchOption{
 		client.ForceOwnership,
 		client.FieldOwner(ownerRef.Field),
@@ -122,12 +159,34 @@ func (s *StorageManager) Get(ctx context.Context, name, namespace string) (*apiv
 
 // List returns the instances found in the given namespace.
 func (s *StorageManager) List(ctx context.Context, namespace, bundle string) ([]*apiv1.Instance, error) {
-	var res []*apiv1.Instance
-	secretList := &corev1.SecretList{}
 	labels := s.getOwnerLabels()
 	if bundle != "" {
 		labels[apiv1.BundleNameLabelKey] = bundle
 	}
+	return s.list(ctx, namespace, labels)
+}
+
+// ListByName returns the instances with the given name found in all namespaces,
+// ordered by namespace.
+func (s *StorageManager) ListByName(ctx context.Context, name string) ([]*apiv1.Instance, error) {
+	labels := s.getOwnerLabels()
+	labels[nameLabelKey] = name
+	instances, err := s.list(ctx, "", labels)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].Namespace < instances[j].Namespace
+	})
+	return instances, nil
+}
+
+// list returns the instances found in the given namespace whose storage matches
+// the given labels, ordered by their installation date.
+func (s *StorageManager) list(ctx context.Context, namespace string, labels client.MatchingLabels) ([]*apiv1.Instance, error) {
+	var res []*apiv1.Instance
+	secretList := &corev1.SecretList{}
 	err := s.resManager.Client().List(ctx, secretList, client.InNamespace(namespace), labels)
 	if err != nil {
 		return res, err
@@ -161,6 +220,23 @@ func (s *StorageManager) List(ctx context.Context, namespace, bundle string) ([]
 	return res, nil
 }
 
+// ListBySelector returns the instances found in the given namespace
+// whose storage labels match the given selector.
+func (s *StorageManager) ListBySelector(ctx context.Context, namespace string, selector labels.Selector) ([]*apiv1.Instance, error) {
+	instances, err := s.List(ctx, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*apiv1.Instance
+	for _, instance := range instances {
+		if selector.Matches(labels.Set(instance.Labels)) {
+			res = append(res, instance)
+		}
+	}
+	return res, nil
+}
+
 // Delete removes the storage for the given instance name and namespace.
 func (s *StorageManager) Delete(ctx context.Context, name, namespace string) error {
 	secret := s.newSecret(name, namespace)
@@ -258,7 +334,8 @@ func (s *StorageManager) newSecret(name, namespace string) *corev1.Secret {
 	}
 }
 
-// createNamespace creates the inventory namespace if not present.
+// createNamespace creates the inventory namespace if not present,
+// or adds the missing namespace labels to the existing one.
 func (s *StorageManager) createNamespace(ctx context.Context, name string) error {
 	ns := &corev1.Namespace{
 		TypeMeta: metav1.TypeMeta{
@@ -275,6 +352,9 @@ func (s *StorageManager) createNamespace(ctx context.Context, name string) error
 
 	if err := s.resManager.Client().Get(ctx, client.ObjectKeyFromObject(ns), ns); err != nil {
 		if apierrors.IsNotFound(err) {
+			for k, v := range s.namespaceLabels {
+				ns.Labels[k] = v
+			}
 			opts := []client.PatchOption{
 				client.ForceOwnership,
 				client.FieldOwner(ownerRef.Field),
@@ -285,6 +365,22 @@ func (s *StorageManager) createNamespace(ctx context.Context, name string) error
 		}
 	}
 
+	patch := client.MergeFrom(ns.DeepCopy())
+	changed := false
+	for k, v := range s.namespaceLabels {
+		if _, ok := ns.Labels[k]; !ok {
+			if ns.Labels == nil {
+				ns.Labels = make(map[string]string)
+			}
+			ns.Labels[k] = v
+			changed = true
+		}
+	}
+
+	if changed {
+		return s.resManager.Client().Patch(ctx, ns, patch, client.FieldOwner(ownerRef.Field))
+	}
+
 	return nil
 }
 
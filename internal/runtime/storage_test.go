@@ -0,0 +1,69 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	apiv1 "github.com/stefanprodan/timoni/api/v1alpha1"
+)
+
+func TestStorageManager_ListByName(t *testing.T) {
+	g := NewWithT(t)
+	sm := NewStorageManager(nil)
+
+	var objects []client.Object
+	for _, inst := range []struct{ name, namespace string }{
+		{"app", "tenant-b"},
+		{"app", "tenant-a"},
+		{"db", "tenant-a"},
+	} {
+		data, err := json.Marshal(&apiv1.Instance{
+			TypeMeta:   metav1.TypeMeta{APIVersion: apiv1.GroupVersion.String(), Kind: apiv1.InstanceKind},
+			ObjectMeta: metav1.ObjectMeta{Name: inst.name, Namespace: inst.namespace},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		secret := sm.newSecret(inst.name, inst.namespace)
+		secret.Data = map[string][]byte{storageDataKey: data}
+		objects = append(objects, secret)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(defaultScheme()).WithObjects(objects...).Build()
+	sm = NewStorageManager(ssa.NewResourceManager(c, nil, ssa.Owner{Field: apiv1.FieldManager}))
+
+	instances, err := sm.ListByName(context.Background(), "app")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(instances).To(HaveLen(2))
+	g.Expect(instances[0].Namespace).To(Equal("tenant-a"))
+	g.Expect(instances[1].Namespace).To(Equal("tenant-b"))
+	for _, inst := range instances {
+		g.Expect(inst.Name).To(Equal("app"))
+	}
+
+	instances, err = sm.ListByName(context.Background(), "web")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(instances).To(BeEmpty())
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TerminationTimeoutError lists the objects which were not
+// deleted from the cluster within their wait deadline.
+type TerminationTimeoutError struct {
+	Objects []*unstructured.Unstructured
+}
+
+// Error returns the subjects of the objects still terminating.
+func (e *TerminationTimeoutError) Error() string {
+	subjects := make([]string, 0, len(e.Objects))
+	for _, obj := range e.Objects {
+		subjects = append(subjects, ssa.FmtUnstructured(obj))
+	}
+	return fmt.Sprintf("timeout waiting for the termination of %v resource(s): %s",
+		len(e.Objects), strings.Join(subjects, ", "))
+}
+
+// WaitForTermination polls the given objects concurrently until they are deleted
+// from the cluster. Each object is waited for at most the given timeout, when zero
+// only the context deadline applies, which acts as a ceiling for all objects.
+// The objects not deleted within their deadline are listed in a TerminationTimeoutError,
+// while the errors which are not transient stop the wait of the object right away.
+func WaitForTermination(ctx context.Context,
+	c client.Client,
+	objects []*unstructured.Unstructured,
+	interval time.Duration,
+	timeout time.Duration) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		timedOut []*unstructured.Unstructured
+		errs     []string
+	)
+
+	for _, object := range objects {
+		wg.Add(1)
+		go func(object *unstructured.Unstructured) {
+			defer wg.Done()
+
+			waitCtx, cancel := ctx, context.CancelFunc(func() {})
+			if timeout > 0 {
+				waitCtx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			defer cancel()
+
+			err := wait.PollUntilContextCancel(waitCtx, interval, true, isTerminated(c, object))
+			if err == nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if waitCtx.Err() != nil {
+				timedOut = append(timedOut, object)
+				return
+			}
+			errs = append(errs, fmt.Sprintf("%s query failed: %s", ssa.FmtUnstructured(object), err))
+		}(object)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("waiting for termination failed: %s", strings.Join(errs, "; "))
+	}
+	if len(timedOut) > 0 {
+		// Report the objects in the same order they were given.
+		remaining := make([]*unstructured.Unstructured, 0, len(timedOut))
+		for _, object := range objects {
+			for _, obj := range timedOut {
+				if obj == object {
+					remaining = append(remaining, object)
+					break
+				}
+			}
+		}
+		return &TerminationTimeoutError{Objects: remaining}
+	}
+	return nil
+}
+
+func isTerminated(c client.Client, object *unstructured.Unstructured) wait.ConditionWithContextFunc {
+	return func(ctx context.Context) (bool, error) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(object.GroupVersionKind())
+		err := c.Get(ctx, client.ObjectKeyFromObject(object), obj)
+		switch {
+		case apierrors.IsNotFound(err):
+			return true, nil
+		case err != nil && !IsTransientError(err) && ctx.Err() == nil:
+			return false, err
+		default:
+			return false, nil
+		}
+	}
+}
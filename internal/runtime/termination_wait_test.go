@@ -0,0 +1,123 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestWaitForTermination(t *testing.T) {
+	newObject := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+
+	t.Run("returns when all objects are deleted", func(t *testing.T) {
+		g := NewWithT(t)
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "terminating", Namespace: "default"}}
+		c := fake.NewClientBuilder().WithScheme(defaultScheme()).WithObjects(cm).Build()
+
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			_ = c.Delete(context.Background(), cm)
+		}()
+
+		err := WaitForTermination(context.Background(), c, []*unstructured.Unstructured{
+			newObject("gone"),
+			newObject("terminating"),
+		}, 10*time.Millisecond, time.Second)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("reports the objects exceeding their deadline", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().
+			WithScheme(defaultScheme()).
+			WithObjects(
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "stuck-a", Namespace: "default"}},
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "stuck-b", Namespace: "default"}},
+			).
+			Build()
+
+		start := time.Now()
+		err := WaitForTermination(context.Background(), c, []*unstructured.Unstructured{
+			newObject("stuck-b"),
+			newObject("gone"),
+			newObject("stuck-a"),
+		}, 10*time.Millisecond, 50*time.Millisecond)
+		g.Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+
+		var timeoutErr *TerminationTimeoutError
+		g.Expect(errors.As(err, &timeoutErr)).To(BeTrue())
+		g.Expect(timeoutErr.Objects).To(HaveLen(2))
+		g.Expect(timeoutErr.Objects[0].GetName()).To(Equal("stuck-b"))
+		g.Expect(timeoutErr.Objects[1].GetName()).To(Equal("stuck-a"))
+		g.Expect(err.Error()).To(ContainSubstring("ConfigMap/default/stuck-b, ConfigMap/default/stuck-a"))
+	})
+
+	t.Run("bounds the object timeout with the context deadline", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().
+			WithScheme(defaultScheme()).
+			WithObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "stuck", Namespace: "default"}}).
+			Build()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := WaitForTermination(ctx, c, []*unstructured.Unstructured{newObject("stuck")}, 10*time.Millisecond, time.Hour)
+		g.Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+
+		var timeoutErr *TerminationTimeoutError
+		g.Expect(errors.As(err, &timeoutErr)).To(BeTrue())
+		g.Expect(timeoutErr.Objects).To(HaveLen(1))
+	})
+
+	t.Run("fails fast on forbidden", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().
+			WithScheme(defaultScheme()).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, key.Name, errors.New("denied"))
+				},
+			}).
+			Build()
+
+		err := WaitForTermination(context.Background(), c, []*unstructured.Unstructured{newObject("app")}, 10*time.Millisecond, time.Hour)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("ConfigMap/default/app query failed"))
+	})
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"sync"
+)
+
+// WarningRecorder is a rest.WarningHandler which records the warnings returned by the
+// API server e.g. the deprecated API versions and the admission webhooks notices.
+// The duplicated warnings are recorded once.
+type WarningRecorder struct {
+	mu       sync.Mutex
+	warnings []string
+	seen     map[string]bool
+}
+
+// NewWarningRecorder returns an empty WarningRecorder.
+func NewWarningRecorder() *WarningRecorder {
+	return &WarningRecorder{
+		seen: make(map[string]bool),
+	}
+}
+
+// HandleWarningHeader records the warnings with the 299 code, the other codes are ignored.
+func (r *WarningRecorder) HandleWarningHeader(code int, agent string, text string) {
+	if code != 299 || len(text) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen[text] {
+		return
+	}
+	r.seen[text] = true
+	r.warnings = append(r.warnings, text)
+}
+
+// Flush returns the warnings recorded since the last flush.
+func (r *WarningRecorder) Flush() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	warnings := r.warnings
+	r.warnings = nil
+	return warnings
+}
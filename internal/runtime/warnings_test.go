@@ -0,0 +1,43 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestWarningRecorder(t *testing.T) {
+	g := NewWithT(t)
+
+	r := NewWarningRecorder()
+	r.HandleWarningHeader(299, "", "policy/v1beta1 PodDisruptionBudget is deprecated")
+	r.HandleWarningHeader(299, "", "policy/v1beta1 PodDisruptionBudget is deprecated")
+	r.HandleWarningHeader(199, "", "miscellaneous warning")
+	r.HandleWarningHeader(299, "", "")
+	r.HandleWarningHeader(299, "", "would violate PodSecurity \"restricted:latest\"")
+
+	g.Expect(r.Flush()).To(Equal([]string{
+		"policy/v1beta1 PodDisruptionBudget is deprecated",
+		"would violate PodSecurity \"restricted:latest\"",
+	}))
+	g.Expect(r.Flush()).To(BeEmpty())
+
+	r.HandleWarningHeader(299, "", "policy/v1beta1 PodDisruptionBudget is deprecated")
+	g.Expect(r.Flush()).To(BeEmpty())
+}
@@ -18,6 +18,10 @@ Action: {
 	Keep: {
 		"action.timoni.sh/prune": ActionStatus.Disabled
 	}
+	// Preserve placement annotation for keeping the node placement of stateful workloads on upgrades.
+	PreservePlacement: {
+		"action.timoni.sh/preserve-placement": ActionStatus.Enabled
+	}
 }
 
 ActionStatus: {